@@ -4,8 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -15,8 +13,14 @@ import (
 // AdapterStat has VirtualDriveStats and PhysicalDriveStats in itself.
 type AdapterStat struct {
 	AdapterId          int                 `json:"adapter_id"`
+	Backend            string              `json:"backend"`
 	VirtualDriveStats  []VirtualDriveStat  `json:"virtual_drive_stats"`
 	PhysicalDriveStats []PhysicalDriveStat `json:"physical_drive_stats"`
+	CcSchedule         *CcScheduleStat     `json:"cc_schedule,omitempty"`
+	PatrolRead         *PatrolReadStat     `json:"patrol_read,omitempty"`
+	ForeignConfigs     []ForeignConfig     `json:"foreign_configs,omitempty"`
+	// RAID卡的PCI路径，smartctl借用卡上的系统盘符时使用
+	pciPath string
 }
 
 // String() is used to get the print string.
@@ -37,7 +41,7 @@ func (a *AdapterStat) ToJson() (string, error) {
 	return string(data), nil
 }
 
-func (a *AdapterStat) parseMegaRaidVdInfo(info string, common string, adapterId string) error {
+func (a *AdapterStat) parseMegaRaidVdInfo(info string, common string, adapterId string, resolver *osDeviceResolver) error {
 	if info == "" {
 		return errors.New("mageRaid vd info nil")
 	}
@@ -60,7 +64,7 @@ func (a *AdapterStat) parseMegaRaidVdInfo(info string, common string, adapterId
 			vd.OsPath = "Unknown"
 			// 获取raid卡pcie地址
 			if pciPath, ok := getHBAPCIInfo(common, adapterId); ok {
-				osPath := getVdOsPath(pciPath, vd.VirtualDrive)
+				osPath := resolver.vdOsPath(pciPath, vd.VirtualDrive)
 				vd.OsPath = osPath
 			}
 			vds = append(vds, vd)
@@ -71,7 +75,7 @@ func (a *AdapterStat) parseMegaRaidVdInfo(info string, common string, adapterId
 	return nil
 }
 
-func (a *AdapterStat) getMegaRaidVdInfo(command string) error {
+func (a *AdapterStat) getMegaRaidVdInfo(command string, resolver *osDeviceResolver) error {
 	adapterId := strconv.Itoa(a.AdapterId)
 	args := "-ldinfo -lall -a" + adapterId + " -NoLog"
 
@@ -88,14 +92,14 @@ func (a *AdapterStat) getMegaRaidVdInfo(command string) error {
 		return errors.New("megaCli return error: " + result)
 	}
 
-	err = a.parseMegaRaidVdInfo(output, command, adapterId)
+	err = a.parseMegaRaidVdInfo(output, command, adapterId, resolver)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (a *AdapterStat) parseMegaRaidPdInfo(common string, adapterId string, info string) error {
+func (a *AdapterStat) parseMegaRaidPdInfo(common string, adapterId string, info string, resolver *osDeviceResolver) error {
 	if info == "" {
 		return errors.New("mageRaid pd info nil")
 	}
@@ -119,7 +123,7 @@ func (a *AdapterStat) parseMegaRaidPdInfo(common string, adapterId string, info
 			if pd.FirmwareState == "JBOD" {
 				// 获取raid卡pcie地址
 				if pciPath, ok := getHBAPCIInfo(common, adapterId); ok {
-					osPath := getPdOsPath(pciPath, pd.DeviceId)
+					osPath := resolver.pdOsPath(pciPath, pd.DeviceId, pd.SasAddress, pd.Wwn)
 					pd.OsPath = osPath
 				}
 			}
@@ -131,9 +135,9 @@ func (a *AdapterStat) parseMegaRaidPdInfo(common string, adapterId string, info
 	return nil
 }
 
-func (a *AdapterStat) getMegaRaidPdInfo(command string) error {
+func (a *AdapterStat) getMegaRaidPdInfo(command string, resolver *osDeviceResolver) error {
 	adapterId := strconv.Itoa(a.AdapterId)
-	// 已知bug：pd DiskGroup可能会和vd序号对不上，用 -LdPdInfo 按顺序解析就可以规避
+	// pd DiskGroup可能会和vd序号对不上，VD的disk group由 getMegaRaidVdDiskGroups() 从 -LdPdInfo 获取
 	args := "-pdlist -a" + strconv.Itoa(a.AdapterId) + " -NoLog"
 
 	output, err := execCmd(command, args)
@@ -149,13 +153,44 @@ func (a *AdapterStat) getMegaRaidPdInfo(command string) error {
 		return errors.New("megaCli return error: " + result)
 	}
 
-	err = a.parseMegaRaidPdInfo(command, adapterId, output)
+	err = a.parseMegaRaidPdInfo(command, adapterId, output, resolver)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// parseMegaRaidVdDiskGroups() 解析 -LdPdInfo，按每个VD下列出的成员盘记录VD所在的disk group
+func parseMegaRaidVdDiskGroups(output string) map[int]string {
+	diskGroups := make(map[int]string)
+	parts := strings.Split(output, keyVdVirtualDrive)
+	for _, vdinfo := range parts[1:] {
+		lines := strings.Split(vdinfo, "\n")
+		vd := VirtualDriveStat{}
+		if err := vd.parseLine(keyVdVirtualDrive + lines[0]); err != nil {
+			continue
+		}
+		for _, line := range lines[1:] {
+			pd := PhysicalDriveStat{}
+			if !strings.Contains(line, keyPdDiskGroup) || pd.parseLine(line) != nil || pd.PdDiskGroup == "" {
+				continue
+			}
+			diskGroups[vd.VirtualDrive] = pd.PdDiskGroup
+			break
+		}
+	}
+	return diskGroups
+}
+
+// getMegaRaidVdDiskGroups() 获取每个VD所在的disk group，查询失败时VD没有成员盘
+func (a *AdapterStat) getMegaRaidVdDiskGroups(command string) {
+	output := queryMegaCliIgnoreExit(command, "-LdPdInfo -a"+strconv.Itoa(a.AdapterId)+" -NoLog")
+	diskGroups := parseMegaRaidVdDiskGroups(output)
+	for i := range a.VirtualDriveStats {
+		a.VirtualDriveStats[i].diskGroup = diskGroups[a.VirtualDriveStats[i].VirtualDrive]
+	}
+}
+
 // 提取HBAPCIInfo
 func parseHBAPCIInfo(output string) string {
 	busprefix := "0000"
@@ -245,48 +280,3 @@ func getHBAPCIInfo(command string, adapterId string) (string, bool) {
 	pciPath := parseHBAPCIInfo(output)
 	return pciPath, true
 }
-
-// 获取vd对应的系统盘符
-func getVdOsPath(pciPath string, virtualDriveId int) string {
-	osPath := "Unknown"
-	if pciPath != "" {
-		diskPrefix := "/dev/disk/by-path/pci-" + pciPath + "-scsi-0:"
-
-		// RAID disks are usually with a channel of '2', JBOD disks with a channel of '0'
-		for j := 1; j < 8; j++ {
-			diskPath := diskPrefix + fmt.Sprintf("%d:%d:0", j, virtualDriveId)
-			//fmt.Println("Looking for DISKpath : " + diskpath)
-			if _, err := os.Stat(diskPath); err == nil {
-				if realpath, err := filepath.EvalSymlinks(diskPath); err == nil {
-					osPath = realpath
-					//fmt.Println("Found DISK match: " + diskpath + " -> " + tempResult)
-					break
-				}
-			}
-		}
-	}
-	//fmt.Println("got real os path: ", osPath)
-	return osPath
-}
-
-// 获取pd对应的系统盘符，只有JBOD会直接映射到系统
-func getPdOsPath(pciPath string, physicalDriveId int) string {
-	osPath := "Unknown"
-	if pciPath != "" {
-		diskPrefix := "/dev/disk/by-path/pci-" + pciPath + "-scsi-0:0:"
-
-		// RAID disks are usually with a channel of '2', JBOD disks with a channel of '0'
-		for j := 1; j < 14; j++ {
-			diskPath := diskPrefix + fmt.Sprintf("%d:0", physicalDriveId)
-			//fmt.Println("Looking for DISKpath : " + diskPath)
-			if _, err := os.Stat(diskPath); err == nil {
-				if realpath, err := filepath.EvalSymlinks(diskPath); err == nil {
-					osPath = realpath
-					break
-				}
-			}
-		}
-	}
-	//fmt.Println("got real os path: ", osPath)
-	return osPath
-}
@@ -0,0 +1,306 @@
+package diskutil
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	keyArcControllersFound string = "Controllers found:"
+	keyArcLogicalDevice    string = "logical device number"
+	keyArcPhysicalDevice   string = "Device #"
+	keyArcHardDrive        string = "Device is a Hard drive"
+	keyArcSectionLogical   string = "logical device information"
+	keyArcSectionPhysical  string = "physical device information"
+)
+
+var (
+	// Group 0, Segment 1 : Present (286102MB, SAS, HDD, Enclosure:0, Slot:1) 6SE2...
+	arcConfSegmentRegex = regexp.MustCompile(`Enclosure:\s*(\d+),\s*Slot:\s*(\d+)`)
+	// Reported Location : Enclosure 0, Slot 0(Connector 0:CN0)
+	arcConfLocationRegex = regexp.MustCompile(`Enclosure (\d+), Slot (\d+)`)
+	arcConfPdStates      = map[string]string{
+		"Online":              "Online, Spun Up",
+		"Ready":               "Unconfigured(good), Spun Up",
+		"Hot Spare":           "Hotspare, Spun Up",
+		"Dedicated Hot Spare": "Hotspare, Spun Up",
+		"Global Hot-Spare":    "Hotspare, Spun Up",
+		"Raw (Pass Through)":  "JBOD",
+		"Raw":                 "JBOD",
+		"Rebuilding":          "Rebuild",
+	}
+)
+
+// ArcConfBackend is a Backend which uses arcconf to get the stat of
+// Adaptec/Microsemi cards by parsing the output of "GETCONFIG <n> AL".
+type ArcConfBackend struct {
+	arcconfPath string
+}
+
+// NewArcConfBackend() use the arcConfPath to build an ArcConfBackend.
+func NewArcConfBackend(arcConfPath string) (*ArcConfBackend, error) {
+	arcConfPath = path.Clean(arcConfPath)
+	if !fileExist(arcConfPath) {
+		return nil, errors.New("arcconf not exist")
+	}
+	return &ArcConfBackend{
+		arcconfPath: arcConfPath,
+	}, nil
+}
+
+// Name() is used to get the name of the backend.
+func (a *ArcConfBackend) Name() string {
+	return backendArcConf
+}
+
+// Get() is used to get all the AdapterStats of the backend.
+func (a *ArcConfBackend) Get() ([]AdapterStat, error) {
+	ads := make([]AdapterStat, 0)
+
+	// arcconf的控制器从1开始编号，输出第一行给出控制器数量
+	count := 1
+	for i := 1; i <= count; i++ {
+		output, err := execCmd(a.arcconfPath, fmt.Sprintf("GETCONFIG %d AL", i))
+		if err != nil {
+			return nil, err
+		}
+		if i == 1 {
+			count = parseArcConfControllerCount(output)
+		}
+		ad, err := parseArcConfConfig(output, i)
+		if err != nil {
+			return nil, err
+		}
+		ads = append(ads, ad)
+	}
+	return ads, nil
+}
+
+// GetVirtualDrive() is used to get the AdapterStats with VirtualDriveStats only.
+func (a *ArcConfBackend) GetVirtualDrive() ([]AdapterStat, error) {
+	ads, err := a.Get()
+	if err != nil {
+		return nil, err
+	}
+	for i := range ads {
+		ads[i].PhysicalDriveStats = nil
+	}
+	return ads, nil
+}
+
+// GetPhysicalDrive() is used to get the AdapterStats with PhysicalDriveStats only.
+func (a *ArcConfBackend) GetPhysicalDrive() ([]AdapterStat, error) {
+	ads, err := a.Get()
+	if err != nil {
+		return nil, err
+	}
+	for i := range ads {
+		ads[i].VirtualDriveStats = nil
+	}
+	return ads, nil
+}
+
+func parseArcConfControllerCount(output string) int {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, keyArcControllersFound) {
+			count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, keyArcControllersFound)))
+			if err == nil {
+				return count
+			}
+		}
+	}
+	return 1
+}
+
+// 成员盘所在的LD和序号，key为 enclosure:slot
+type arcConfMember struct {
+	diskGroup string
+	arm       int
+}
+
+func parseArcConfConfig(output string, adapterId int) (AdapterStat, error) {
+	ad := AdapterStat{
+		AdapterId:          adapterId,
+		Backend:            backendArcConf,
+		VirtualDriveStats:  make([]VirtualDriveStat, 0),
+		PhysicalDriveStats: make([]PhysicalDriveStat, 0),
+	}
+	if strings.TrimSpace(output) == "" {
+		return ad, errors.New("arcconf config info nil")
+	}
+
+	var (
+		section string
+		vd      *VirtualDriveStat
+		pd      *PhysicalDriveStat
+		isDrive bool
+		members = make(map[string]arcConfMember)
+	)
+	flushVd := func() {
+		if vd != nil {
+			ad.VirtualDriveStats = append(ad.VirtualDriveStats, *vd)
+		}
+		vd = nil
+	}
+	flushPd := func() {
+		if pd != nil && isDrive {
+			ad.PhysicalDriveStats = append(ad.PhysicalDriveStats, *pd)
+		}
+		pd = nil
+		isDrive = false
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "---") {
+			continue
+		}
+		lower := strings.ToLower(trimmed)
+		// 顶层的section标题没有缩进，设备内部的 "Device Phy Information" 等需要排除
+		if line == trimmed && strings.HasSuffix(lower, " information") {
+			flushVd()
+			flushPd()
+			section = lower
+			continue
+		}
+
+		switch section {
+		case keyArcSectionLogical:
+			if strings.HasPrefix(lower, keyArcLogicalDevice) {
+				flushVd()
+				vdId, err := strconv.Atoi(strings.TrimSpace(trimmed[len(keyArcLogicalDevice):]))
+				if err != nil {
+					return ad, errors.New("format illegal: " + trimmed)
+				}
+				vd = &VirtualDriveStat{VirtualDrive: vdId, OsPath: "Unknown", Encryptiontype: "None", diskGroup: strconv.Itoa(vdId)}
+				continue
+			}
+			if vd == nil {
+				continue
+			}
+			if strings.Contains(trimmed, "Segment") {
+				// 成员盘：记录enclosure:slot到LD的映射，PD解析时使用
+				if matches := arcConfSegmentRegex.FindStringSubmatch(trimmed); matches != nil {
+					members[matches[1]+":"+matches[2]] = arcConfMember{
+						diskGroup: vd.diskGroup,
+						arm:       vd.NumberOfDrives,
+					}
+				}
+				vd.NumberOfDrives++
+				continue
+			}
+			vd.parseArcConfLine(trimmed)
+		case keyArcSectionPhysical:
+			if strings.HasPrefix(trimmed, keyArcPhysicalDevice) {
+				flushPd()
+				deviceId, err := strconv.Atoi(strings.TrimPrefix(trimmed, keyArcPhysicalDevice))
+				if err != nil {
+					return ad, errors.New("format illegal: " + trimmed)
+				}
+				pd = &PhysicalDriveStat{DeviceId: deviceId, EnclosureDeviceId: 999, OsPath: "Unknown"}
+				continue
+			}
+			if pd == nil {
+				continue
+			}
+			if strings.HasPrefix(trimmed, keyArcHardDrive) {
+				isDrive = true
+				continue
+			}
+			pd.parseArcConfLine(trimmed)
+		}
+	}
+	flushVd()
+	flushPd()
+
+	for i := range ad.PhysicalDriveStats {
+		pd := &ad.PhysicalDriveStats[i]
+		key := fmt.Sprintf("%d:%d", pd.EnclosureDeviceId, pd.SlotNumber)
+		if member, ok := members[key]; ok {
+			pd.PdDiskGroup = member.diskGroup
+			pd.PdArm = strconv.Itoa(member.arm)
+		}
+	}
+	return ad, nil
+}
+
+func (v *VirtualDriveStat) parseArcConfLine(line string) {
+	key, value, ok := splitSpacedField(line)
+	if !ok {
+		return
+	}
+	switch key {
+	case "Logical Device name", "Logical Device Name":
+		v.Name = value
+	case "Size":
+		v.Size = value
+	case "Status of Logical Device", "Status of Logical Drive":
+		v.State = value
+	case "RAID level":
+		v.RaidLevel = normalizeRaidLevel(value)
+	case "Encrypted":
+		if value == "Yes" {
+			v.Encryptiontype = "Encrypted"
+		}
+	case "Disk Name":
+		if fileds := strings.Fields(value); len(fileds) > 0 && strings.HasPrefix(fileds[0], "/dev/") {
+			v.OsPath = fileds[0]
+		}
+	}
+}
+
+func (p *PhysicalDriveStat) parseArcConfLine(line string) {
+	key, value, ok := splitSpacedField(line)
+	if !ok {
+		return
+	}
+	switch key {
+	case "State":
+		p.FirmwareState = value
+		if state, ok := arcConfPdStates[value]; ok {
+			p.FirmwareState = state
+		}
+	case "Reported Location":
+		if matches := arcConfLocationRegex.FindStringSubmatch(value); matches != nil {
+			p.EnclosureDeviceId, _ = strconv.Atoi(matches[1])
+			p.SlotNumber, _ = strconv.Atoi(matches[2])
+		}
+	case "Transfer Speed":
+		if fileds := strings.Fields(value); len(fileds) > 0 {
+			p.PdType = fileds[0]
+		}
+	case "SSD":
+		p.PdMediaType = "Hard Disk Device"
+		if value == "Yes" {
+			p.PdMediaType = "Solid State Device"
+		}
+	case "Vendor":
+		p.Brand = value
+	case "Model":
+		p.Model = value
+	case "Serial number", "Serial Number":
+		p.SerialNumber = value
+	case "Total Size":
+		p.RawSize = value
+	case "Temperature":
+		p.DriveTemperature = value
+	case "S.M.A.R.T. warnings":
+		if warnings, err := strconv.Atoi(value); err == nil && warnings > p.PredictiveFailureCount {
+			p.PredictiveFailureCount = warnings
+		}
+	case "S.M.A.R.T.":
+		// Yes表示硬盘已经报告了SMART预警
+		if value == "Yes" && p.PredictiveFailureCount == 0 {
+			p.PredictiveFailureCount = 1
+		}
+	case "Disk Name":
+		if fileds := strings.Fields(value); len(fileds) > 0 && strings.HasPrefix(fileds[0], "/dev/") {
+			p.OsPath = fileds[0]
+		}
+	}
+}
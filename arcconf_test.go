@@ -0,0 +1,97 @@
+package diskutil
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type arcConfWantPd struct {
+	deviceId        int
+	enclosure, slot int
+	state           string
+	diskGroup, arm  string
+	serial          string
+	mediaType       string
+	predictive      int
+}
+
+func TestParseArcConfConfig(t *testing.T) {
+	tests := []struct {
+		fixture string
+		vds     []VirtualDriveStat
+		pds     []arcConfWantPd
+	}{
+		{
+			fixture: "raid1.txt",
+			vds: []VirtualDriveStat{
+				{VirtualDrive: 0, Name: "system", Size: "285686 MB", State: "Optimal", RaidLevel: "RAID1", NumberOfDrives: 2, Encryptiontype: "None", OsPath: "/dev/sda", diskGroup: "0"},
+			},
+			pds: []arcConfWantPd{
+				{0, 0, 0, "Online, Spun Up", "0", "0", "6SE2ABCD0000B1234567", "Hard Disk Device", 0},
+				{1, 0, 1, "Online, Spun Up", "0", "1", "6SE2ABCE0000B1234567", "Hard Disk Device", 0},
+			},
+		},
+		{
+			fixture: "raid5_degraded.txt",
+			vds: []VirtualDriveStat{
+				{VirtualDrive: 0, Name: "data", Size: "953837 MB", State: "Degraded", RaidLevel: "RAID5", NumberOfDrives: 3, Encryptiontype: "None", OsPath: "/dev/sda", diskGroup: "0"},
+			},
+			pds: []arcConfWantPd{
+				{0, 0, 0, "Online, Spun Up", "0", "0", "S3F4NX0K100001", "Solid State Device", 0},
+				{2, 0, 2, "Online, Spun Up", "0", "2", "S3F4NX0K100003", "Solid State Device", 0},
+				{3, 0, 3, "Unconfigured(good), Spun Up", "", "", "S3F4NX0K100004", "Solid State Device", 0},
+			},
+		},
+		{
+			fixture: "smart_warning.txt",
+			vds: []VirtualDriveStat{
+				{VirtualDrive: 0, Name: "ceph-os", Size: "285686 MB", State: "Optimal", RaidLevel: "RAID1", NumberOfDrives: 2, Encryptiontype: "None", OsPath: "/dev/sda", diskGroup: "0"},
+			},
+			pds: []arcConfWantPd{
+				{4, 0, 4, "Online, Spun Up", "0", "0", "6SE2ABCD0000B7654321", "Hard Disk Device", 0},
+				{5, 0, 5, "Online, Spun Up", "0", "1", "6SE2ABCE0000B7654321", "Hard Disk Device", 3},
+				{6, 0, 6, "JBOD", "", "", "V6G1ABCD", "Hard Disk Device", 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "arcconf", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count := parseArcConfControllerCount(string(data)); count != 1 {
+				t.Errorf("controller count = %d, want 1", count)
+			}
+			ad, err := parseArcConfConfig(string(data), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ad.AdapterId != 1 || ad.Backend != backendArcConf {
+				t.Errorf("controller = %d %s, want 1 %s", ad.AdapterId, ad.Backend, backendArcConf)
+			}
+			if !reflect.DeepEqual(ad.VirtualDriveStats, tt.vds) {
+				t.Errorf("logical devices =\n%+v\nwant\n%+v", ad.VirtualDriveStats, tt.vds)
+			}
+			if len(ad.PhysicalDriveStats) != len(tt.pds) {
+				t.Fatalf("got %d physical devices, want %d", len(ad.PhysicalDriveStats), len(tt.pds))
+			}
+			for i, want := range tt.pds {
+				pd := ad.PhysicalDriveStats[i]
+				got := arcConfWantPd{pd.DeviceId, pd.EnclosureDeviceId, pd.SlotNumber, pd.FirmwareState,
+					pd.PdDiskGroup, pd.PdArm, pd.SerialNumber, pd.PdMediaType, pd.PredictiveFailureCount}
+				if got != want {
+					t.Errorf("physical device %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseArcConfEmpty(t *testing.T) {
+	if _, err := parseArcConfConfig(" \n", 1); err == nil {
+		t.Error("empty output accepted")
+	}
+}
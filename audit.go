@@ -0,0 +1,194 @@
+package diskutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// "-physdrv[32:4]" 或 "-physdrv[:4]"
+	auditPdRegex = regexp.MustCompile(`(?i)-physdrv\[(\d*):(\d+)\]`)
+	// "-r1[32:2,32:3]" 或 "-Array0[32:2,32:3]"
+	auditPdListRegex  = regexp.MustCompile(`(?:-r\d+|-Array\d+)\[([^\]]+)\]`)
+	auditVdRegex      = regexp.MustCompile(`-L(\d+)\b`)
+	auditAdapterRegex = regexp.MustCompile(`-a(\d+)\b`)
+)
+
+const auditStateNoAdapter string = "unknown: no single adapter in the command"
+
+// ErrAuditLogUnavailable is returned, wrapped, when the audit log can not be
+// opened and the mutating command is refused.
+var ErrAuditLogUnavailable = errors.New("audit log unavailable, command refused")
+
+// AuditState is a struct to get the state of a drive touched by a mutating
+// MegaCli command, Target is "PD 32:4" or "VD 0".
+type AuditState struct {
+	Target       string `json:"target"`
+	State        string `json:"state"`
+	SerialNumber string `json:"serial_number,omitempty"`
+}
+
+// AuditRecord is one line of the audit log written by EnableAuditLog().
+// ExitCode is -1 when MegaCli could not be run or its output was illegal.
+type AuditRecord struct {
+	Time     time.Time    `json:"time"`
+	User     string       `json:"user"`
+	SudoUser string       `json:"sudo_user,omitempty"`
+	Host     string       `json:"host"`
+	Caller   []string     `json:"caller"`
+	Argv     []string     `json:"argv"`
+	ExitCode int          `json:"exit_code"`
+	Error    string       `json:"error,omitempty"`
+	Before   []AuditState `json:"before"`
+	After    []AuditState `json:"after"`
+}
+
+// EnableDryRun() is used to print every state-changing MegaCli command to w
+// instead of running it. The checks before the commands still run, and waiting
+// for drives or LEDs returns at once. A nil w turns dry-run off.
+func (d *DiskStatus) EnableDryRun(w io.Writer) {
+	d.dryRun = w
+}
+
+// EnableAuditLog() is used to append a JSON line to the file at path for every
+// state-changing MegaCli command which is run: who ran it where, the full argv,
+// the exit code and the state of the affected drives before and after. A command
+// is refused with ErrAuditLogUnavailable when the audit log can not be opened.
+func (d *DiskStatus) EnableAuditLog(path string) {
+	d.auditLog = path
+}
+
+// auditTargets() 从参数中找出受影响的PD和VD，没有 -aN (如 -aALL) 时adapter为-1
+func auditTargets(args string) (int, []string, []int) {
+	adapter := -1
+	if matches := auditAdapterRegex.FindStringSubmatch(args); matches != nil {
+		adapter, _ = strconv.Atoi(matches[1])
+	}
+	drives := make([]string, 0)
+	for _, matches := range auditPdRegex.FindAllStringSubmatch(args, -1) {
+		drives = append(drives, matches[1]+":"+matches[2])
+	}
+	for _, matches := range auditPdListRegex.FindAllStringSubmatch(args, -1) {
+		drives = append(drives, strings.Split(matches[1], ",")...)
+	}
+	vds := make([]int, 0)
+	for _, matches := range auditVdRegex.FindAllStringSubmatch(args, -1) {
+		vd, _ := strconv.Atoi(matches[1])
+		vds = append(vds, vd)
+	}
+	return adapter, drives, vds
+}
+
+// auditStates() 逐个查询受影响的PD和VD的当前状态，查询失败时记录错误；
+// 不知道adapter时不去查别的卡上同位置的盘，记为unknown
+func (d *DiskStatus) auditStates(adapter int, drives []string, vds []int) []AuditState {
+	states := make([]AuditState, 0, len(drives)+len(vds))
+	if adapter < 0 {
+		for _, drive := range drives {
+			states = append(states, AuditState{Target: "PD " + drive, State: auditStateNoAdapter})
+		}
+		for _, vd := range vds {
+			states = append(states, AuditState{Target: fmt.Sprintf("VD %d", vd), State: auditStateNoAdapter})
+		}
+		return states
+	}
+	for _, drive := range drives {
+		state := AuditState{Target: "PD " + drive}
+		output, err := d.queryMegaCli(fmt.Sprintf("-pdInfo -physdrv[%s] -a%d -NoLog", drive, adapter))
+		if err != nil {
+			state.State = "unknown: " + err.Error()
+			states = append(states, state)
+			continue
+		}
+		pd := PhysicalDriveStat{}
+		for _, line := range strings.Split(output, "\n") {
+			pd.parseLine(line)
+		}
+		state.State, state.SerialNumber = pd.FirmwareState, pd.SerialNumber
+		states = append(states, state)
+	}
+	for _, vd := range vds {
+		state := AuditState{Target: fmt.Sprintf("VD %d", vd)}
+		output, err := d.queryMegaCli(fmt.Sprintf("-LDInfo -L%d -a%d -NoLog", vd, adapter))
+		if err != nil {
+			state.State = "unknown: " + err.Error()
+		} else {
+			state.State = megaCliKeyValues(output)[keyVdState]
+			if state.State == "" {
+				state.State = "absent"
+			}
+		}
+		states = append(states, state)
+	}
+	return states
+}
+
+func newAuditRecord(argv []string) AuditRecord {
+	record := AuditRecord{
+		Time:     time.Now(),
+		User:     os.Getenv("USER"),
+		SudoUser: os.Getenv("SUDO_USER"),
+		Caller:   os.Args,
+		Argv:     argv,
+	}
+	if u, err := user.Current(); err == nil {
+		record.User = u.Username
+	}
+	record.Host, _ = os.Hostname()
+	return record
+}
+
+// auditExitCode() MegaCli的退出码，没有运行或输出不合法时为-1
+func auditExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	var megaCliErr megaCliExitError
+	if errors.As(err, &megaCliErr) {
+		if code, parseErr := strconv.ParseInt(string(megaCliErr), 0, 0); parseErr == nil {
+			return int(code)
+		}
+	}
+	return -1
+}
+
+// execMegaCliAudited() 先打开审计日志，打不开时不执行命令；命令执行前后各查询一次受影响盘的状态
+func (d *DiskStatus) execMegaCliAudited(args string) (string, error) {
+	file, err := os.OpenFile(d.auditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrAuditLogUnavailable, err)
+	}
+	defer file.Close()
+
+	adapter, drives, vds := auditTargets(args)
+	record := newAuditRecord(append([]string{d.megacliPath}, strings.Split(args, " ")...))
+	record.Before = d.auditStates(adapter, drives, vds)
+	output, err := d.queryMegaCli(args)
+	record.ExitCode = auditExitCode(err)
+	if err != nil {
+		record.Error = err.Error()
+	}
+	record.After = d.auditStates(adapter, drives, vds)
+
+	data, jsonErr := json.Marshal(record)
+	if jsonErr == nil {
+		_, jsonErr = file.Write(append(data, '\n'))
+	}
+	if jsonErr != nil && err == nil {
+		return output, fmt.Errorf("command run but the audit log failed: %v", jsonErr)
+	}
+	return output, err
+}
@@ -0,0 +1,49 @@
+package diskutil
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAuditTargets(t *testing.T) {
+	tests := []struct {
+		args    string
+		adapter int
+		drives  []string
+		vds     []int
+	}{
+		{"-PDOffline -physdrv[32:4] -a1 -NoLog", 1, []string{"32:4"}, []int{}},
+		{"-CfgSpanAdd -r10 -Array0[32:0,32:1] -Array1[32:2,32:3] -a0 -NoLog", 0, []string{"32:0", "32:1", "32:2", "32:3"}, []int{}},
+		{"-LDSetProp WB -L2 -a0 -NoLog", 0, []string{}, []int{2}},
+		{"-PDLocate -start -physdrv[:7] -aALL -NoLog", -1, []string{":7"}, []int{}},
+	}
+	for _, tt := range tests {
+		adapter, drives, vds := auditTargets(tt.args)
+		if adapter != tt.adapter || !reflect.DeepEqual(drives, tt.drives) || !reflect.DeepEqual(vds, tt.vds) {
+			t.Errorf("auditTargets(%q) = %d %v %v, want %d %v %v", tt.args, adapter, drives, vds, tt.adapter, tt.drives, tt.vds)
+		}
+	}
+}
+
+func TestAuditStatesWithoutAdapter(t *testing.T) {
+	// 没有adapter时不执行MegaCli
+	d := &DiskStatus{megacliPath: filepath.Join(t.TempDir(), "MegaCli64")}
+	states := d.auditStates(-1, []string{"32:4"}, []int{1})
+	want := []AuditState{
+		{Target: "PD 32:4", State: auditStateNoAdapter},
+		{Target: "VD 1", State: auditStateNoAdapter},
+	}
+	if !reflect.DeepEqual(states, want) {
+		t.Errorf("states = %+v, want %+v", states, want)
+	}
+}
+
+func TestAuditLogUnavailable(t *testing.T) {
+	d := &DiskStatus{megacliPath: filepath.Join(t.TempDir(), "MegaCli64")}
+	d.EnableAuditLog(filepath.Join(t.TempDir(), "missing", "audit.jsonl"))
+	if _, err := d.execMegaCli("-PDOffline -physdrv[32:4] -a0 -NoLog"); !errors.Is(err, ErrAuditLogUnavailable) {
+		t.Errorf("err = %v, want ErrAuditLogUnavailable", err)
+	}
+}
@@ -0,0 +1,192 @@
+package diskutil
+
+import (
+	"errors"
+	"os/exec"
+	"path"
+)
+
+const (
+	backendMegaCli string = "megacli"
+	backendStorCli string = "storcli"
+	backendSsaCli  string = "ssacli"
+	backendMd      string = "md"
+	backendArcConf string = "arcconf"
+	backendSasIrcu string = "sasircu"
+	backendNvme    string = "nvme"
+)
+
+// Backend is an interface to collect the stat of one kind of RAID controller.
+// Every Backend returns the same AdapterStat, VirtualDriveStat and PhysicalDriveStat
+// types, so ListBrokenDrive() works unchanged whatever the controller is.
+type Backend interface {
+	// Name() is used to get the name of the backend.
+	Name() string
+	// Get() is used to get all the AdapterStats of the backend.
+	Get() ([]AdapterStat, error)
+	// GetVirtualDrive() is used to get the AdapterStats with VirtualDriveStats only.
+	GetVirtualDrive() ([]AdapterStat, error)
+	// GetPhysicalDrive() is used to get the AdapterStats with PhysicalDriveStats only.
+	GetPhysicalDrive() ([]AdapterStat, error)
+}
+
+// MegaCliBackend is a Backend which uses MegaCli64 to get the stat of MegaRaid cards.
+type MegaCliBackend struct {
+	megacliPath  string
+	adapterCount int
+	resolver     *osDeviceResolver
+}
+
+// NewMegaCliBackend() use the megaCliPath and adapterCount to build a MegaCliBackend.
+func NewMegaCliBackend(megaCliPath string, adapterCount int) (*MegaCliBackend, error) {
+	megaCliPath = path.Clean(megaCliPath)
+	if !fileExist(megaCliPath) {
+		return nil, errors.New("megaCli not exist")
+	}
+	return &MegaCliBackend{
+		megacliPath:  megaCliPath,
+		adapterCount: adapterCount,
+		resolver:     newOsDeviceResolver("", ""),
+	}, nil
+}
+
+// SetSysfsRoot() is used to change the sysfs and /dev roots used to map
+// the VDs and JBODs to the OS block devices, which is useful for tests.
+func (m *MegaCliBackend) SetSysfsRoot(sysRoot, devRoot string) {
+	m.resolver = newOsDeviceResolver(sysRoot, devRoot)
+}
+
+// Name() is used to get the name of the backend.
+func (m *MegaCliBackend) Name() string {
+	return backendMegaCli
+}
+
+// Get() is used to get all the AdapterStats of the backend, including the
+// progress, the consistency check and patrol read, and the foreign configurations.
+func (m *MegaCliBackend) Get() ([]AdapterStat, error) {
+	return m.get(true, true)
+}
+
+// GetVirtualDrive() is used to get the AdapterStats with VirtualDriveStats only.
+func (m *MegaCliBackend) GetVirtualDrive() ([]AdapterStat, error) {
+	return m.get(true, false)
+}
+
+// GetPhysicalDrive() is used to get the AdapterStats with PhysicalDriveStats only.
+func (m *MegaCliBackend) GetPhysicalDrive() ([]AdapterStat, error) {
+	return m.get(false, true)
+}
+
+func (m *MegaCliBackend) get(withVd, withPd bool) ([]AdapterStat, error) {
+	ads := make([]AdapterStat, 0)
+
+	command := m.megacliPath
+	for i := 0; i < m.adapterCount; i++ {
+		ad := AdapterStat{
+			AdapterId: i,
+			Backend:   backendMegaCli,
+		}
+		if withVd {
+			err := ad.getMegaRaidVdInfo(command, m.resolver)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if withPd {
+			err := ad.getMegaRaidPdInfo(command, m.resolver)
+			if err != nil {
+				return nil, err
+			}
+		}
+		// VD的成员关系、进度、CC/巡读和外部配置每项都要多执行几次MegaCli，只在Get()中采集，
+		// 不拖慢ListBroken*等只取VD或PD的监控路径
+		if withVd && withPd {
+			ad.getMegaRaidVdDiskGroups(command)
+			ad.getMegaRaidProgress(command)
+			ad.getMegaRaidCcPatrolRead(command)
+			ad.getMegaRaidForeign(command)
+		}
+		ads = append(ads, ad)
+	}
+	return ads, nil
+}
+
+// 常见的RAID工具安装路径，PATH中找不到时依次尝试
+var backendSearchPaths = map[string][]string{
+	"storcli64": {"/opt/MegaRAID/storcli/storcli64", "/usr/local/sbin/storcli64"},
+	"perccli64": {"/opt/MegaRAID/perccli/perccli64", "/usr/local/sbin/perccli64"},
+	"MegaCli64": {"/opt/MegaRAID/MegaCli/MegaCli64", "/usr/local/sbin/MegaCli64"},
+	"ssacli":    {"/usr/sbin/ssacli", "/opt/smartstorageadmin/ssacli/bin/ssacli"},
+	"hpssacli":  {"/usr/sbin/hpssacli"},
+	"arcconf":   {"/usr/sbin/arcconf", "/usr/Adaptec_Event_Monitor/arcconf"},
+	"sas3ircu":  {"/usr/sbin/sas3ircu", "/usr/local/sbin/sas3ircu"},
+	"sas2ircu":  {"/usr/sbin/sas2ircu", "/usr/local/sbin/sas2ircu"},
+	"nvme":      {"/usr/sbin/nvme", "/usr/local/sbin/nvme"},
+}
+
+// lookupBinary() 在PATH和常见安装路径中查找RAID工具
+func lookupBinary(name string) (string, bool) {
+	if p, err := exec.LookPath(name); err == nil {
+		return p, true
+	}
+	for _, p := range backendSearchPaths[name] {
+		if fileExist(p) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// DetectBackend() is used to select a Backend by the RAID tool found on the server.
+// storcli64 and perccli64 are preferred, MegaCli64 is used as the fallback.
+// On HPE servers ssacli or hpssacli is used, on Adaptec/Microsemi cards arcconf is used.
+// adapterCount is only used by the MegaCli backend.
+func DetectBackend(adapterCount int) (Backend, error) {
+	for _, name := range []string{"storcli64", "perccli64"} {
+		if p, ok := lookupBinary(name); ok {
+			return NewStorCliBackend(p)
+		}
+	}
+	if p, ok := lookupBinary("MegaCli64"); ok {
+		return NewMegaCliBackend(p, adapterCount)
+	}
+	for _, name := range []string{"ssacli", "hpssacli"} {
+		if p, ok := lookupBinary(name); ok {
+			return NewSsaCliBackend(p)
+		}
+	}
+	if p, ok := lookupBinary("arcconf"); ok {
+		return NewArcConfBackend(p)
+	}
+	return nil, errors.New("no supported raid tool found")
+}
+
+// DetectBackends() is used to select all the Backends available on the server:
+// the RAID tool selected by DetectBackend(), sas3ircu/sas2ircu for LSI HBAs,
+// the md backend if any md array exists and the nvme backend if any NVMe drive exists.
+func DetectBackends(adapterCount int) ([]Backend, error) {
+	backends := make([]Backend, 0)
+	if backend, err := DetectBackend(adapterCount); err == nil {
+		backends = append(backends, backend)
+	}
+	// HBA和RAID卡可以同时存在，sas2ircu和sas3ircu分别只能看到各自代际的HBA
+	for _, name := range []string{"sas3ircu", "sas2ircu"} {
+		if p, ok := lookupBinary(name); ok {
+			if backend, err := NewSasIrcuBackend(p, ""); err == nil {
+				backends = append(backends, backend)
+			}
+		}
+	}
+	if md := NewMdBackend("", ""); md.HasArray() {
+		backends = append(backends, md)
+	}
+	// 没有nvme-cli时只采集sysfs中的盘信息
+	nvmeCliPath, _ := lookupBinary("nvme")
+	if nvme, err := NewNvmeBackend(nvmeCliPath, ""); err == nil && nvme.HasDevice() {
+		backends = append(backends, nvme)
+	}
+	if len(backends) == 0 {
+		return nil, errors.New("no supported raid tool, md array or nvme drive found")
+	}
+	return backends, nil
+}
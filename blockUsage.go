@@ -0,0 +1,167 @@
+package diskutil
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 分区/holder的最大递归深度，正常的 盘->分区->LVM->dm-crypt 不会超过
+const maxHolderDepth int = 8
+
+// BlockDevice is a struct to get what is on a block device: its filesystem,
+// mount points, space usage, and the partitions and device-mapper/LVM/md
+// holders stacked on it.
+type BlockDevice struct {
+	Name        string        `json:"name"`
+	DmName      string        `json:"dm_name,omitempty"`
+	FsType      string        `json:"fs_type,omitempty"`
+	FsUuid      string        `json:"fs_uuid,omitempty"`
+	MountPoints []string      `json:"mount_points,omitempty"`
+	TotalBytes  uint64        `json:"total_bytes,omitempty"`
+	UsedBytes   uint64        `json:"used_bytes,omitempty"`
+	FreeBytes   uint64        `json:"free_bytes,omitempty"`
+	Partitions  []BlockDevice `json:"partitions,omitempty"`
+	Holders     []BlockDevice `json:"holders,omitempty"`
+}
+
+// AllMountPoints() is used to get the mount points of the device and
+// everything stacked on it.
+func (b *BlockDevice) AllMountPoints() []string {
+	mountPoints := append([]string{}, b.MountPoints...)
+	for i := range b.Partitions {
+		mountPoints = append(mountPoints, b.Partitions[i].AllMountPoints()...)
+	}
+	for i := range b.Holders {
+		mountPoints = append(mountPoints, b.Holders[i].AllMountPoints()...)
+	}
+	return mountPoints
+}
+
+// mountinfo中的一条挂载
+type mountEntry struct {
+	majorMinor string
+	mountPoint string
+	fsType     string
+	source     string
+}
+
+// 解析 /proc/self/mountinfo：
+// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+func (r *osDeviceResolver) mountEntries() []mountEntry {
+	entries := make([]mountEntry, 0)
+	f, err := os.Open(filepath.Join(r.procRoot, "self", "mountinfo"))
+	if err != nil {
+		return entries
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), " - ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fileds := strings.Fields(parts[0])
+		tail := strings.Fields(parts[1])
+		if len(fileds) < 5 || len(tail) < 2 {
+			continue
+		}
+		entries = append(entries, mountEntry{
+			majorMinor: fileds[2],
+			mountPoint: unescapeMountPoint(fileds[4]),
+			fsType:     tail[0],
+			source:     tail[1],
+		})
+	}
+	return entries
+}
+
+// mountinfo中空格等字符被转义为 \040
+func unescapeMountPoint(mountPoint string) string {
+	replacer := strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`)
+	return replacer.Replace(mountPoint)
+}
+
+// udev数据库 /run/udev/data/b<major>:<minor> 中的 E:KEY=VALUE
+func (r *osDeviceResolver) udevProperties(majorMinor string) map[string]string {
+	props := make(map[string]string)
+	f, err := os.Open(filepath.Join(r.udevRoot, "data", "b"+majorMinor))
+	if err != nil {
+		return props
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "E:") {
+			continue
+		}
+		kv := strings.SplitN(line[2:], "=", 2)
+		if len(kv) == 2 {
+			props[kv[0]] = kv[1]
+		}
+	}
+	return props
+}
+
+// 挂载源(/dev/mapper/vg-lv等)对应的内核设备名
+func (r *osDeviceResolver) sourceName(source string) string {
+	if !strings.HasPrefix(source, "/dev/") {
+		return ""
+	}
+	path := filepath.Join(r.devRoot, strings.TrimPrefix(source, "/dev/"))
+	if realPath, err := filepath.EvalSymlinks(path); err == nil {
+		path = realPath
+	}
+	return filepath.Base(path)
+}
+
+// blockDevice() 递归获取块设备上的分区、holder、文件系统和挂载点
+func (r *osDeviceResolver) blockDevice(name string, mounts []mountEntry, depth int) BlockDevice {
+	blockDir := filepath.Join(r.sysRoot, "class", "block", name)
+	device := BlockDevice{
+		Name:   name,
+		DmName: readSysfs(blockDir, "dm/name"),
+	}
+
+	majorMinor := readSysfs(blockDir, "dev")
+	props := r.udevProperties(majorMinor)
+	device.FsType = props["ID_FS_TYPE"]
+	device.FsUuid = props["ID_FS_UUID"]
+	for _, mount := range mounts {
+		if (majorMinor != "" && mount.majorMinor == majorMinor) || r.sourceName(mount.source) == name {
+			device.MountPoints = append(device.MountPoints, mount.mountPoint)
+			if device.FsType == "" {
+				device.FsType = mount.fsType
+			}
+		}
+	}
+	if len(device.MountPoints) > 0 {
+		device.TotalBytes, device.UsedBytes, device.FreeBytes = statfsUsage(device.MountPoints[0])
+	}
+	if depth >= maxHolderDepth {
+		return device
+	}
+
+	// 分区是块设备目录下带 partition 文件的子目录
+	if entries, err := os.ReadDir(blockDir); err == nil {
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), name) && fileExist(filepath.Join(blockDir, entry.Name(), "partition")) {
+				device.Partitions = append(device.Partitions, r.blockDevice(entry.Name(), mounts, depth+1))
+			}
+		}
+	}
+	if entries, err := os.ReadDir(filepath.Join(blockDir, "holders")); err == nil {
+		for _, entry := range entries {
+			device.Holders = append(device.Holders, r.blockDevice(entry.Name(), mounts, depth+1))
+		}
+	}
+	sort.Slice(device.Partitions, func(i, j int) bool {
+		return device.Partitions[i].Name < device.Partitions[j].Name
+	})
+	return device
+}
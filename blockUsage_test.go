@@ -0,0 +1,129 @@
+package diskutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMountEntries(t *testing.T) {
+	r := newFixtureResolver(t)
+	entries := r.mountEntries()
+	if len(entries) != 5 {
+		t.Fatalf("mountEntries() = %+v", entries)
+	}
+	want := []mountEntry{
+		{majorMinor: "253:0", mountPoint: "/", fsType: "xfs", source: "/dev/mapper/vg0-root"},
+		{majorMinor: "0:21", mountPoint: "/proc", fsType: "proc", source: "proc"},
+		{majorMinor: "8:1", mountPoint: "/boot", fsType: "ext4", source: "/dev/sda1"},
+		{majorMinor: "253:2", mountPoint: "/var/lib/mysql", fsType: "xfs", source: "/dev/mapper/vg1-mysql"},
+		// 挂载点中的空格被转义为 \040
+		{majorMinor: "8:32", mountPoint: "/data/disk 1", fsType: "xfs", source: "/dev/sdc"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("mountEntries() =\n%+v\nwant\n%+v", entries, want)
+	}
+
+	r.procRoot = t.TempDir()
+	if entries := r.mountEntries(); len(entries) != 0 {
+		t.Errorf("mountEntries() without mountinfo = %+v", entries)
+	}
+}
+
+func TestUnescapeMountPoint(t *testing.T) {
+	tests := map[string]string{
+		`/data/disk\0401`:    "/data/disk 1",
+		`/mnt/a\011b\134c`:   "/mnt/a\tb\\c",
+		`/var/lib/mysql`:     "/var/lib/mysql",
+		`/mnt/\040\040lead`:  "/mnt/  lead",
+		`/mnt/new\012line`:   "/mnt/new\nline",
+		`/mnt/not\04escaped`: `/mnt/not\04escaped`,
+	}
+	for mountPoint, want := range tests {
+		if got := unescapeMountPoint(mountPoint); got != want {
+			t.Errorf("unescapeMountPoint(%q) = %q, want %q", mountPoint, got, want)
+		}
+	}
+}
+
+func TestUdevProperties(t *testing.T) {
+	r := newFixtureResolver(t)
+	// S: 开头的软链接行不是属性
+	want := map[string]string{"ID_FS_TYPE": "ext4", "ID_FS_UUID": "5b0c9d1e-4b7a-4c3f-9e2d-7a8b9c0d1e2f"}
+	if props := r.udevProperties("8:1"); !reflect.DeepEqual(props, want) {
+		t.Errorf("udevProperties(8:1) = %v, want %v", props, want)
+	}
+	if props := r.udevProperties("8:0"); len(props) != 0 {
+		t.Errorf("udevProperties(8:0) = %v, want none", props)
+	}
+}
+
+// clearUsage() statfs读取的是本机的挂载点，比较前清掉
+func clearUsage(device *BlockDevice) {
+	device.TotalBytes, device.UsedBytes, device.FreeBytes = 0, 0, 0
+	for i := range device.Partitions {
+		clearUsage(&device.Partitions[i])
+	}
+	for i := range device.Holders {
+		clearUsage(&device.Holders[i])
+	}
+}
+
+func TestBlockDevice(t *testing.T) {
+	r := newFixtureResolver(t)
+	mounts := r.mountEntries()
+	tests := []struct {
+		name        string
+		want        BlockDevice
+		mountPoints []string
+	}{
+		{
+			// 系统盘：/boot分区和LVM上的根分区
+			name: "sda",
+			want: BlockDevice{
+				Name: "sda",
+				Partitions: []BlockDevice{
+					{Name: "sda1", FsType: "ext4", FsUuid: "5b0c9d1e-4b7a-4c3f-9e2d-7a8b9c0d1e2f", MountPoints: []string{"/boot"}},
+					{Name: "sda2", FsType: "LVM2_member", FsUuid: "Kx3aY1-aW0v-P9Ab-CdEf-GhIj-KlMn-OpQrSt", Holders: []BlockDevice{
+						{Name: "dm-0", DmName: "vg0-root", FsType: "xfs", FsUuid: "0f8e7d6c-5b4a-4938-8271-6a5b4c3d2e1f", MountPoints: []string{"/"}},
+					}},
+				},
+			},
+			mountPoints: []string{"/boot", "/"},
+		},
+		{
+			name: "sdb",
+			want: BlockDevice{
+				Name: "sdb", FsType: "LVM2_member", FsUuid: "Zy9xW8-vU7t-S6rQ-5pO4-nM3l-K2jI-1hG0fE",
+				Holders: []BlockDevice{
+					{Name: "dm-2", DmName: "vg1-mysql", FsType: "xfs", FsUuid: "9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d", MountPoints: []string{"/var/lib/mysql"}},
+				},
+			},
+			mountPoints: []string{"/var/lib/mysql"},
+		},
+		{
+			// 没有udev数据时文件系统类型取自mountinfo
+			name:        "sdc",
+			want:        BlockDevice{Name: "sdc", FsType: "xfs", MountPoints: []string{"/data/disk 1"}},
+			mountPoints: []string{"/data/disk 1"},
+		},
+		{
+			name: "sdd",
+			want: BlockDevice{Name: "sdd"},
+		},
+	}
+	for _, tt := range tests {
+		device := r.blockDevice(tt.name, mounts, 0)
+		clearUsage(&device)
+		if !reflect.DeepEqual(device, tt.want) {
+			t.Errorf("blockDevice(%q) =\n%+v\nwant\n%+v", tt.name, device, tt.want)
+		}
+		if mountPoints := device.AllMountPoints(); !reflect.DeepEqual(mountPoints, append([]string{}, tt.mountPoints...)) {
+			t.Errorf("%s: AllMountPoints() = %v, want %v", tt.name, mountPoints, tt.mountPoints)
+		}
+	}
+
+	// 超过最大深度不再递归
+	if device := r.blockDevice("sda", mounts, maxHolderDepth); device.Partitions != nil || device.Holders != nil {
+		t.Errorf("blockDevice() at the max depth = %+v", device)
+	}
+}
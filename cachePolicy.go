@@ -0,0 +1,172 @@
+package diskutil
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Disk cache policies of CachePolicySpec.
+const (
+	DiskCacheEnabled  string = "Enabled"
+	DiskCacheDisabled string = "Disabled"
+)
+
+// CachePolicySpec is a struct to describe the cache policy changes of
+// SetVDCachePolicy(). Empty fields and a nil CachedBadBBU keep the current setting.
+type CachePolicySpec struct {
+	WritePolicy  string `json:"write_policy,omitempty"`
+	ReadPolicy   string `json:"read_policy,omitempty"`
+	IOPolicy     string `json:"io_policy,omitempty"`
+	CachedBadBBU *bool  `json:"cached_bad_bbu,omitempty"`
+	DiskCache    string `json:"disk_cache,omitempty"`
+}
+
+// CachePolicyDelta is a struct to get a cache policy of a VD which differs from
+// its default, e.g. write through while the BBU relearns.
+type CachePolicyDelta struct {
+	AdapterId    int    `json:"adapter_id"`
+	VirtualDrive int    `json:"virtual_drive"`
+	Policy       string `json:"policy"`
+	Current      string `json:"current"`
+	Default      string `json:"default"`
+}
+
+func ldSetPropArgs(adapter, virtualDrive int, prop string) string {
+	return fmt.Sprintf("-LDSetProp %s -L%d -a%d -NoLog", prop, virtualDrive, adapter)
+}
+
+// cachedBadBBUProp() BBU故障时继续写缓存，掉电会丢数据，打开时要求force
+func cachedBadBBUProp(on, force bool) (string, error) {
+	if !on {
+		return "NoCachedBadBBU", nil
+	}
+	if !force {
+		return "", errors.New("write back with a bad BBU loses the cached data on power failure, force required")
+	}
+	return "CachedBadBBU", nil
+}
+
+// cachePolicyProps() 每项修改对应一次 -LDSetProp
+func cachePolicyProps(spec CachePolicySpec, force bool) ([]string, error) {
+	props := make([]string, 0)
+	for _, policy := range []struct {
+		value   string
+		allowed []string
+	}{
+		{spec.WritePolicy, vdWritePolicies},
+		{spec.ReadPolicy, vdReadPolicies},
+		{spec.IOPolicy, vdIOPolicies},
+	} {
+		if policy.value == "" {
+			continue
+		}
+		value, err := canonicalPolicy(policy.value, policy.allowed)
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, value)
+	}
+	if spec.CachedBadBBU != nil {
+		prop, err := cachedBadBBUProp(*spec.CachedBadBBU, force)
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, prop)
+	}
+	switch {
+	case spec.DiskCache == "":
+	case strings.EqualFold(spec.DiskCache, DiskCacheEnabled):
+		props = append(props, "-EnDskCache")
+	case strings.EqualFold(spec.DiskCache, DiskCacheDisabled):
+		props = append(props, "-DisDskCache")
+	default:
+		return nil, errors.New("disk cache policy illegal: " + spec.DiskCache)
+	}
+	if len(props) == 0 {
+		return nil, errors.New("no cache policy to change")
+	}
+	return props, nil
+}
+
+func cachePolicyDeltas(adapterId int, vd *VirtualDriveStat) []CachePolicyDelta {
+	deltas := make([]CachePolicyDelta, 0)
+	current, def := vd.CachePolicy, vd.DefaultCachePolicy
+	if current == nil || def == nil {
+		return deltas
+	}
+	add := func(policy, currentValue, defaultValue string) {
+		if currentValue != defaultValue {
+			deltas = append(deltas, CachePolicyDelta{
+				AdapterId:    adapterId,
+				VirtualDrive: vd.VirtualDrive,
+				Policy:       policy,
+				Current:      currentValue,
+				Default:      defaultValue,
+			})
+		}
+	}
+	add("write_policy", current.WritePolicy, def.WritePolicy)
+	add("read_policy", current.ReadPolicy, def.ReadPolicy)
+	add("io_policy", current.IOPolicy, def.IOPolicy)
+	add("cached_bad_bbu", fmt.Sprint(current.CachedBadBBU), fmt.Sprint(def.CachedBadBBU))
+	return deltas
+}
+
+// CachePolicyDeltas() is used to get the cache policies of the MegaRaid VDs which
+// differ from their defaults.
+func (d *DiskStatus) CachePolicyDeltas() ([]CachePolicyDelta, error) {
+	if err := d.GetVirtualDrive(); err != nil {
+		return nil, err
+	}
+	deltas := make([]CachePolicyDelta, 0)
+	for _, ad := range d.AdapterStats {
+		for i := range ad.VirtualDriveStats {
+			deltas = append(deltas, cachePolicyDeltas(ad.AdapterId, &ad.VirtualDriveStats[i])...)
+		}
+	}
+	return deltas, nil
+}
+
+// SetVDCachePolicy() is used to change the write, read, IO and disk cache policy
+// of a VD of a MegaRaid adapter by "-LDSetProp". Enabling "Cached Write if Bad BBU"
+// requires force. It returns the cache policy deltas of every VD of the adapter
+// after the change.
+func (d *DiskStatus) SetVDCachePolicy(adapter, virtualDrive int, spec CachePolicySpec, force bool) ([]CachePolicyDelta, error) {
+	props, err := cachePolicyProps(spec, force)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.GetVirtualDrive(); err != nil {
+		return nil, err
+	}
+	found := false
+	for _, ad := range d.AdapterStats {
+		if ad.Backend != backendMegaCli || ad.AdapterId != adapter {
+			continue
+		}
+		for _, vd := range ad.VirtualDriveStats {
+			found = found || vd.VirtualDrive == virtualDrive
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no VD %d found on adapter %d", virtualDrive, adapter)
+	}
+
+	for _, prop := range props {
+		if _, err := d.execMegaCli(ldSetPropArgs(adapter, virtualDrive, prop)); err != nil {
+			return nil, err
+		}
+	}
+	deltas, err := d.CachePolicyDeltas()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]CachePolicyDelta, 0)
+	for _, delta := range deltas {
+		if delta.AdapterId == adapter {
+			result = append(result, delta)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,108 @@
+package diskutil
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCachePolicyProps(t *testing.T) {
+	on, off := true, false
+	tests := []struct {
+		name  string
+		spec  CachePolicySpec
+		force bool
+		want  []string
+		err   string
+	}{
+		{"policies", CachePolicySpec{WritePolicy: "wb", ReadPolicy: "adra", IOPolicy: "direct"}, false, []string{"WB", "ADRA", "Direct"}, ""},
+		{"disk cache", CachePolicySpec{DiskCache: "disabled"}, false, []string{"-DisDskCache"}, ""},
+		{"no cached bad BBU", CachePolicySpec{CachedBadBBU: &off}, false, []string{"NoCachedBadBBU"}, ""},
+		// BBU故障时写缓存会丢数据，必须force
+		{"cached bad BBU", CachePolicySpec{WritePolicy: "WB", CachedBadBBU: &on}, false, nil, "force required"},
+		{"cached bad BBU forced", CachePolicySpec{WritePolicy: "WB", CachedBadBBU: &on, DiskCache: "Enabled"}, true, []string{"WB", "CachedBadBBU", "-EnDskCache"}, ""},
+		{"illegal write policy", CachePolicySpec{WritePolicy: "WriteBack"}, false, nil, "policy WriteBack illegal"},
+		{"illegal disk cache", CachePolicySpec{DiskCache: "Default"}, false, nil, "disk cache policy illegal: Default"},
+		{"nothing", CachePolicySpec{}, true, nil, "no cache policy to change"},
+	}
+	for _, tt := range tests {
+		props, err := cachePolicyProps(tt.spec, tt.force)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: cachePolicyProps() = %v, %v, want %q", tt.name, props, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(props, tt.want) {
+			t.Errorf("%s: cachePolicyProps() = %v, %v, want %v", tt.name, props, err, tt.want)
+		}
+	}
+}
+
+func TestCachePolicyDeltas(t *testing.T) {
+	def := &CachePolicyStat{WritePolicy: "WriteBack", ReadPolicy: "ReadAdaptive", IOPolicy: "Direct"}
+	tests := []struct {
+		name    string
+		current *CachePolicyStat
+		def     *CachePolicyStat
+		want    []CachePolicyDelta
+	}{
+		{"same", &CachePolicyStat{WritePolicy: "WriteBack", ReadPolicy: "ReadAdaptive", IOPolicy: "Direct"}, def, []CachePolicyDelta{}},
+		{
+			// BBU学习时控制器临时改为写穿
+			name:    "write through while the BBU relearns",
+			current: &CachePolicyStat{WritePolicy: "WriteThrough", ReadPolicy: "ReadAdaptive", IOPolicy: "Direct"},
+			def:     def,
+			want:    []CachePolicyDelta{{AdapterId: 1, VirtualDrive: 2, Policy: "write_policy", Current: "WriteThrough", Default: "WriteBack"}},
+		},
+		{
+			name:    "all",
+			current: &CachePolicyStat{WritePolicy: "WriteThrough", ReadPolicy: "ReadAheadNone", IOPolicy: "Cached", CachedBadBBU: true},
+			def:     def,
+			want: []CachePolicyDelta{
+				{AdapterId: 1, VirtualDrive: 2, Policy: "write_policy", Current: "WriteThrough", Default: "WriteBack"},
+				{AdapterId: 1, VirtualDrive: 2, Policy: "read_policy", Current: "ReadAheadNone", Default: "ReadAdaptive"},
+				{AdapterId: 1, VirtualDrive: 2, Policy: "io_policy", Current: "Cached", Default: "Direct"},
+				{AdapterId: 1, VirtualDrive: 2, Policy: "cached_bad_bbu", Current: "true", Default: "false"},
+			},
+		},
+		{"no default", &CachePolicyStat{WritePolicy: "WriteThrough"}, nil, []CachePolicyDelta{}},
+		{"no current", nil, def, []CachePolicyDelta{}},
+	}
+	for _, tt := range tests {
+		vd := &VirtualDriveStat{VirtualDrive: 2, CachePolicy: tt.current, DefaultCachePolicy: tt.def}
+		if deltas := cachePolicyDeltas(1, vd); !reflect.DeepEqual(deltas, tt.want) {
+			t.Errorf("%s: cachePolicyDeltas() = %+v, want %+v", tt.name, deltas, tt.want)
+		}
+	}
+}
+
+func TestSetVDCachePolicy(t *testing.T) {
+	on := true
+	tests := []struct {
+		vd    int
+		spec  CachePolicySpec
+		force bool
+		want  string
+		err   string
+	}{
+		{129, CachePolicySpec{WritePolicy: "wt", DiskCache: "Disabled"}, false,
+			"MegaCli64 -LDSetProp WT -L129 -a0 -NoLog\nMegaCli64 -LDSetProp -DisDskCache -L129 -a0 -NoLog\n", ""},
+		{0, CachePolicySpec{CachedBadBBU: &on}, false, "", "force required"},
+		{1, CachePolicySpec{WritePolicy: "WB"}, false, "", "no VD 1 found on adapter 0"},
+	}
+	for _, tt := range tests {
+		d, out := newDryRunDiskStatus(t, fixtureMegaCliAdapter(t))
+		_, err := d.SetVDCachePolicy(0, tt.vd, tt.spec, tt.force)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("SetVDCachePolicy(VD %d, %+v) = %v, want %q", tt.vd, tt.spec, err, tt.err)
+			}
+		} else if err != nil {
+			t.Errorf("SetVDCachePolicy(VD %d, %+v): %v", tt.vd, tt.spec, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("VD %d: dry-run printed\n%s\nwant\n%s", tt.vd, out.String(), tt.want)
+		}
+	}
+}
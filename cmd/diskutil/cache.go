@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/forever765/diskutil"
+)
+
+func runCache(args []string) error {
+	fs := flag.NewFlagSet("cache", flag.ExitOnError)
+	dsFlags := addDiskStatusFlags(fs)
+	adapter := fs.Int("a", 0, "adapter id")
+	vd := fs.Int("vd", -1, "VD to change, -1 only prints the policies which differ from the defaults")
+	write := fs.String("write", "", "write policy, WB or WT")
+	read := fs.String("read", "", "read policy, RA, NORA or ADRA")
+	io := fs.String("io", "", "IO policy, Direct or Cached")
+	badBBU := fs.String("cached-bad-bbu", "", "on or off, keep write back when the BBU is bad")
+	diskCache := fs.String("disk-cache", "", "disk cache policy, Enabled or Disabled")
+	force := fs.Bool("force", false, "allow -cached-bad-bbu on")
+	fs.Parse(args)
+
+	ds, err := dsFlags.newDiskStatus()
+	if err != nil {
+		return err
+	}
+	var deltas []diskutil.CachePolicyDelta
+	if *vd < 0 {
+		deltas, err = ds.CachePolicyDeltas()
+	} else {
+		spec := diskutil.CachePolicySpec{WritePolicy: *write, ReadPolicy: *read, IOPolicy: *io, DiskCache: *diskCache}
+		switch *badBBU {
+		case "":
+		case "on", "off":
+			on := *badBBU == "on"
+			spec.CachedBadBBU = &on
+		default:
+			return fmt.Errorf("-cached-bad-bbu illegal: %s", *badBBU)
+		}
+		deltas, err = ds.SetVDCachePolicy(*adapter, *vd, spec, *force)
+	}
+	if err != nil {
+		return err
+	}
+	for _, delta := range deltas {
+		fmt.Printf("adapter %d VD-%d %s: %s, default %s\n", delta.AdapterId, delta.VirtualDrive, delta.Policy, delta.Current, delta.Default)
+	}
+	return nil
+}
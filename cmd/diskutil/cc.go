@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/forever765/diskutil"
+)
+
+func printCcPatrolRead(ds *diskutil.DiskStatus) error {
+	if err := ds.Get(); err != nil {
+		return err
+	}
+	if err := ds.CountCorrectedErrors(); err != nil {
+		return err
+	}
+	for _, ad := range ds.AdapterStats {
+		if ad.CcSchedule == nil && ad.PatrolRead == nil {
+			continue
+		}
+		fmt.Printf("adapter %d (%s):\n", ad.AdapterId, ad.Backend)
+		if cc := ad.CcSchedule; cc != nil {
+			fmt.Printf("  consistency check: mode %s, every %dh, state %s, next %s, iterations %d, excluded VDs %s, corrected errors %d\n",
+				cc.Mode, cc.ExecutionDelayHours, cc.CurrentState, cc.NextStartTime, cc.Iterations, cc.ExcludedVds, cc.CorrectedErrors)
+		}
+		if pr := ad.PatrolRead; pr != nil {
+			fmt.Printf("  patrol read: mode %s, every %dh, state %s, next %s, iterations %d, corrected errors %d\n",
+				pr.Mode, pr.ExecutionDelayHours, pr.CurrentState, pr.NextStartTime, pr.Iterations, pr.CorrectedErrors)
+		}
+		for _, vd := range ad.VirtualDriveStats {
+			if vd.Progress != nil {
+				fmt.Printf("  VD-%d: %s %d%%, %ds elapsed, %ds remaining, corrected errors %d\n", vd.VirtualDrive, vd.Progress.Operation,
+					vd.Progress.PercentComplete, vd.Progress.ElapsedSeconds, vd.Progress.RemainingSeconds, vd.Progress.CorrectedErrors)
+			}
+		}
+	}
+	return nil
+}
+
+func runCc(args []string) error {
+	fs := flag.NewFlagSet("cc", flag.ExitOnError)
+	dsFlags := addDiskStatusFlags(fs)
+	adapter := fs.Int("adapter", 0, "adapter of the VD")
+	vd := fs.Int("vd", -1, "VD to start or stop the consistency check on")
+	start := fs.Bool("start", false, "start a consistency check on the VD")
+	stop := fs.Bool("stop", false, "abort the consistency check on the VD")
+	fs.Parse(args)
+
+	ds, err := dsFlags.newDiskStatus()
+	if err != nil {
+		return err
+	}
+	switch {
+	case *start && *stop:
+		return errors.New("-start and -stop are exclusive")
+	case (*start || *stop) && *vd < 0:
+		return errors.New("-vd is required")
+	case *start:
+		return ds.StartConsistencyCheck(*adapter, *vd)
+	case *stop:
+		return ds.StopConsistencyCheck(*adapter, *vd)
+	}
+	return printCcPatrolRead(ds)
+}
+
+func runPatrolRead(args []string) error {
+	fs := flag.NewFlagSet("pr", flag.ExitOnError)
+	dsFlags := addDiskStatusFlags(fs)
+	adapter := fs.Int("adapter", 0, "adapter to set")
+	mode := fs.String("mode", "", "set the patrol read mode: auto, manual or disabled")
+	delay := fs.Duration("delay", 0, "set the delay between two patrol reads, e.g. 168h")
+	startTime := fs.String("start-time", "", "set the next start time, e.g. 2026-10-20T03")
+	fs.Parse(args)
+
+	ds, err := dsFlags.newDiskStatus()
+	if err != nil {
+		return err
+	}
+	if *mode == "" && *delay == 0 && *startTime == "" {
+		return printCcPatrolRead(ds)
+	}
+	if *mode != "" {
+		if err := ds.SetPatrolReadMode(*adapter, *mode); err != nil {
+			return err
+		}
+	}
+	if *delay != 0 || *startTime != "" {
+		if *delay == 0 {
+			return errors.New("-delay is required with -start-time")
+		}
+		var start time.Time
+		if *startTime != "" {
+			if start, err = time.ParseInLocation("2006-01-02T15", *startTime, time.Local); err != nil {
+				return err
+			}
+		}
+		return ds.SetPatrolReadSchedule(*adapter, *delay, start)
+	}
+	return nil
+}
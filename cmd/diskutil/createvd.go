@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/forever765/diskutil"
+)
+
+func runCreateVd(args []string) error {
+	fs := flag.NewFlagSet("create-vd", flag.ExitOnError)
+	dsFlags := addDiskStatusFlags(fs)
+	adapter := fs.Int("a", 0, "adapter id")
+	level := fs.String("r", "", "raid level, e.g. 1, 5, 10, 60")
+	drives := fs.String("drives", "", "member drives as E:S,E:S,...")
+	media := fs.String("media", "", "select the unconfigured drives by media type, ssd or hdd")
+	pdType := fs.String("pd-type", "", "select the unconfigured drives by interface, e.g. SAS or SATA")
+	count := fs.Int("count", 0, "how many selected drives to use, 0 means all")
+	spans := fs.Int("spans", 0, "span count for RAID10/50/60")
+	strip := fs.Int("strip", 0, "strip size in KB")
+	write := fs.String("write", "", "write policy, WB or WT")
+	read := fs.String("read", "", "read policy, RA, NORA or ADRA")
+	io := fs.String("io", "", "IO policy, Direct or Cached")
+	cachedBadBBU := fs.Bool("cached-bad-bbu", false, "keep write back when the BBU is bad, requires -force")
+	force := fs.Bool("force", false, "allow -cached-bad-bbu")
+	fs.Parse(args)
+
+	spec := diskutil.VDSpec{
+		Adapter:      *adapter,
+		RaidLevel:    *level,
+		Spans:        *spans,
+		StripSizeKB:  *strip,
+		WritePolicy:  *write,
+		ReadPolicy:   *read,
+		IOPolicy:     *io,
+		CachedBadBBU: *cachedBadBBU,
+	}
+	if *drives != "" {
+		spec.Drives = strings.Split(*drives, ",")
+	} else if *media != "" || *pdType != "" || *count != 0 {
+		spec.Selector = &diskutil.DriveSelector{MediaType: *media, PdType: *pdType, Count: *count}
+	}
+
+	ds, err := dsFlags.newDiskStatus()
+	if err != nil {
+		return err
+	}
+	return ds.CreateVD(spec, *force)
+}
+
+func runDeleteVd(args []string) error {
+	fs := flag.NewFlagSet("delete-vd", flag.ExitOnError)
+	dsFlags := addDiskStatusFlags(fs)
+	adapter := fs.Int("a", 0, "adapter id")
+	token := fs.String("token", "", "confirmation token, printed when empty")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return fmt.Errorf("a VD id required")
+	}
+	vd, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("VD id illegal: %s", fs.Arg(0))
+	}
+
+	ds, err := dsFlags.newDiskStatus()
+	if err != nil {
+		return err
+	}
+	if *token == "" {
+		t, err := ds.DeleteVDToken(*adapter, vd)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("all the data on VD %d of adapter %d will be lost, run again with -token %s to delete it\n", vd, *adapter, t)
+		return nil
+	}
+	return ds.DeleteVD(*adapter, vd, *token)
+}
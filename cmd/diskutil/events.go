@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/forever765/diskutil"
+)
+
+func runEvents(args []string) error {
+	fs := flag.NewFlagSet("events", flag.ExitOnError)
+	dsFlags := addDiskStatusFlags(fs)
+	adapter := fs.Int("adapter", 0, "adapter to read the event log of")
+	sinceReboot := fs.Bool("since-reboot", false, "only the events since the last reboot")
+	stateFile := fs.String("state", "", "file keeping the last seen sequence number, only newer events are printed")
+	asJson := fs.Bool("json", false, "print one json event per line")
+	fs.Parse(args)
+
+	ds, err := dsFlags.newDiskStatus()
+	if err != nil {
+		return err
+	}
+	var events []diskutil.Event
+	switch {
+	case *stateFile != "":
+		events, err = ds.GetNewEvents(*adapter, *stateFile)
+	case *sinceReboot:
+		events, err = ds.GetEventsSinceReboot(*adapter)
+	default:
+		events, err = ds.GetEvents(*adapter)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		if *asJson {
+			fmt.Println(event.String())
+			continue
+		}
+		when := event.Time.Format("2006-01-02 15:04:05")
+		if event.Time.IsZero() {
+			when = fmt.Sprintf("boot+%ds", event.SecondsSinceReboot)
+		}
+		fmt.Printf("%d %s %-8s 0x%04x %s\n", event.SequenceNumber, when, event.ClassName, event.Code, event.Description)
+		if event.Sense != nil {
+			fmt.Printf("    sense: %s\n", event.Sense.String())
+		}
+	}
+	return nil
+}
+
+func runSense(args []string) error {
+	fs := flag.NewFlagSet("sense", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("sense data in hex is required")
+	}
+	sense, err := diskutil.DecodeSenseHex(strings.Join(fs.Args(), " "))
+	if err != nil {
+		return err
+	}
+	fmt.Println(sense.String())
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/forever765/diskutil"
+)
+
+func runForeign(args []string) error {
+	fs := flag.NewFlagSet("foreign", flag.ExitOnError)
+	dsFlags := addDiskStatusFlags(fs)
+	adapter := fs.Int("a", 0, "adapter id")
+	index := fs.Int("index", diskutil.ForeignAll, "foreign configuration index, -1 means all of them")
+	doImport := fs.Bool("import", false, "import the foreign configuration")
+	doClear := fs.Bool("clear", false, "clear the foreign configuration, the VDs on its drives are lost")
+	token := fs.String("token", "", "confirmation token printed by the preview")
+	fs.Parse(args)
+	if *doImport && *doClear {
+		return errors.New("-import and -clear are exclusive")
+	}
+
+	ds, err := dsFlags.newDiskStatus()
+	if err != nil {
+		return err
+	}
+	if *token != "" {
+		switch {
+		case *doImport:
+			return ds.ImportForeign(*adapter, *index, *token)
+		case *doClear:
+			return ds.ClearForeign(*adapter, *index, *token)
+		}
+		return errors.New("-token requires -import or -clear")
+	}
+
+	preview, err := ds.PreviewForeign(*adapter, *index)
+	if err != nil {
+		return err
+	}
+	// 清除时外部配置中的VD全部丢失，成员盘回到Unconfigured(good)
+	for _, vd := range preview.VirtualDrives {
+		sign := "+"
+		if *doClear {
+			sign = "-"
+		}
+		fmt.Printf("%s VD %d %s %s %s\n", sign, vd.VirtualDrive, vd.RaidLevel, vd.Size, vd.State)
+	}
+	for _, pd := range preview.PhysicalDrives {
+		target := "disk group " + pd.PdDiskGroup
+		if *doClear {
+			target = "Unconfigured(good)"
+		}
+		fmt.Printf("~ enclosure %d slot %d serial %s: %s -> %s\n",
+			pd.EnclosureDeviceId, pd.SlotNumber, pd.SerialNumber, pd.FirmwareState, target)
+	}
+	for _, warning := range preview.Warnings {
+		fmt.Printf("WARNING %s\n", warning)
+	}
+	action := "-import or -clear"
+	if *doImport {
+		action = "-import"
+	} else if *doClear {
+		action = "-clear"
+	}
+	fmt.Printf("run again with %s -token %s to apply it\n", action, preview.Token)
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/forever765/diskutil"
+)
+
+func planLayout(fs *flag.FlagSet, dsFlags *diskStatusFlags, force bool) (*diskutil.DiskStatus, *diskutil.LayoutPlan, error) {
+	if fs.NArg() != 1 {
+		return nil, nil, errors.New("exactly one layout file is required")
+	}
+	layout, err := diskutil.LoadLayout(fs.Arg(0))
+	if err != nil {
+		return nil, nil, err
+	}
+	ds, err := dsFlags.newDiskStatus()
+	if err != nil {
+		return nil, nil, err
+	}
+	plan, err := ds.PlanLayout(layout, force)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(plan.Steps) == 0 {
+		fmt.Println("the adapters already match the layout")
+	}
+	return ds, plan, nil
+}
+
+func printLayoutStep(i int, step diskutil.LayoutStep) {
+	mark := ""
+	if step.Destructive {
+		mark = " [destructive]"
+	}
+	fmt.Printf("%d. adapter %d: %s%s\n   %s\n", i+1, step.AdapterId, step.Description, mark, step.Command)
+}
+
+func runPlan(args []string) error {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	dsFlags := addDiskStatusFlags(fs)
+	force := fs.Bool("force", false, "allow cached_bad_bbu in the layout")
+	fs.Parse(args)
+
+	_, plan, err := planLayout(fs, dsFlags, *force)
+	if err != nil {
+		return err
+	}
+	for i, step := range plan.Steps {
+		printLayoutStep(i, step)
+	}
+	return nil
+}
+
+func runApply(args []string) error {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	dsFlags := addDiskStatusFlags(fs)
+	destructive := fs.Bool("destructive", false, "also run the steps which delete VDs or reuse their drives")
+	force := fs.Bool("force", false, "allow cached_bad_bbu in the layout")
+	fs.Parse(args)
+
+	ds, plan, err := planLayout(fs, dsFlags, *force)
+	if err != nil {
+		return err
+	}
+	i := 0
+	return ds.ApplyLayout(plan, *destructive, func(step diskutil.LayoutStep, skipped bool) {
+		printLayoutStep(i, step)
+		if skipped {
+			fmt.Println("   skipped, run with -destructive to apply it")
+		}
+		i++
+	})
+}
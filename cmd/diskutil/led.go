@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func runLed(args []string) error {
+	fs := flag.NewFlagSet("led", flag.ExitOnError)
+	dsFlags := addDiskStatusFlags(fs)
+	stop := fs.Bool("stop", false, "stop blinking instead of starting")
+	duration := fs.Duration("duration", 0, "stop blinking automatically after the duration, 0 means keep blinking")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("exactly one slot, serial number or /dev path is required")
+	}
+
+	ds, err := dsFlags.newDiskStatus()
+	if err != nil {
+		return err
+	}
+	drive, err := ds.FindPhysicalDrive(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if drive.Backend != "megacli" {
+		return fmt.Errorf("locate LED is only supported on MegaCli adapters, the drive is on %s", drive.Backend)
+	}
+	pd := drive.PhysicalDrives[0]
+	fmt.Printf("adapter %d enclosure %d slot %d: serial %s, %s\n",
+		drive.AdapterId, pd.EnclosureDeviceId, pd.SlotNumber, pd.SerialNumber, pd.FirmwareState)
+
+	switch {
+	case *stop:
+		return ds.StopLocate(drive.AdapterId, pd.EnclosureDeviceId, pd.SlotNumber)
+	case *duration > 0:
+		// Ctrl-C 时也要把灯关掉
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+		fmt.Printf("blinking for %s\n", *duration)
+		return ds.StartLocateFor(ctx, drive.AdapterId, pd.EnclosureDeviceId, pd.SlotNumber, *duration)
+	}
+	return ds.StartLocate(drive.AdapterId, pd.EnclosureDeviceId, pd.SlotNumber)
+}
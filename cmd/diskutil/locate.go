@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+func runLocate(args []string) error {
+	fs := flag.NewFlagSet("locate", flag.ExitOnError)
+	dsFlags := addDiskStatusFlags(fs)
+	asJson := fs.Bool("json", false, "print the result as json")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("exactly one path is required")
+	}
+
+	ds, err := dsFlags.newDiskStatus()
+	if err != nil {
+		return err
+	}
+	result, err := ds.Locate(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *asJson {
+		fmt.Println(result.String())
+		return nil
+	}
+
+	fmt.Printf("%s", result.Path)
+	if result.MountPoint != "" {
+		fmt.Printf(" (mounted on %s)", result.MountPoint)
+	}
+	fmt.Printf(": %s\n", strings.Join(result.Stack, " -> "))
+	for _, drive := range result.Drives {
+		if vd := drive.VirtualDrive; vd != nil {
+			fmt.Printf("adapter %d (%s) VD-%d %s: %s, %s\n", drive.AdapterId, drive.Backend, vd.VirtualDrive, vd.OsPath, vd.State, vd.Size)
+		} else {
+			fmt.Printf("adapter %d (%s) JBOD:\n", drive.AdapterId, drive.Backend)
+		}
+		for _, pd := range drive.PhysicalDrives {
+			fmt.Printf("  enclosure %d slot %d: serial %s, %s, OsPath: %s\n",
+				pd.EnclosureDeviceId, pd.SlotNumber, pd.SerialNumber, pd.FirmwareState, pd.OsPath)
+		}
+	}
+	return nil
+}
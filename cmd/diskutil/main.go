@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/forever765/diskutil"
+)
+
+// 子命令，每个子命令用自己的FlagSet解析参数，公共参数由addDiskStatusFlags()注册
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"apply":     {"apply [flags] <layout.json>  apply the non-destructive changes of a layout file", runApply},
+	"cache":     {"cache [flags]  show or change the cache policy of VDs", runCache},
+	"cc":        {"cc [flags]  show or start/stop consistency checks", runCc},
+	"create-vd": {"create-vd [flags]  validate and create a VD", runCreateVd},
+	"delete-vd": {"delete-vd [flags] <vd>  delete a VD with a confirmation token", runDeleteVd},
+	"events":    {"events [flags]  print the controller event log", runEvents},
+	"foreign":   {"foreign [flags]  preview, import or clear foreign configurations", runForeign},
+	"locate":    {"locate [flags] <path|mount point|/dev/xxx>  show the slots holding a path", runLocate},
+	"led":       {"led [flags] <E:S|serial|/dev/xxx>  blink the locate LED of a drive", runLed},
+	"pd-state":  {"pd-state [flags] <state> <E:S|serial|/dev/xxx>  bring a drive online/offline, mark it missing, good or JBOD, or prepare its removal", runPdState},
+	"plan":      {"plan [flags] <layout.json>  print the MegaCli operations which apply a layout file", runPlan},
+	"pr":        {"pr [flags]  show or set the patrol read mode and schedule", runPatrolRead},
+	"sense":     {"sense <hex>  decode SCSI sense data", runSense},
+	"spare":     {"spare [flags]  show, set or remove hot spares and check the spare policy", runSpare},
+	"replace":   {"replace [flags] <E:S|serial|/dev/xxx>  replace a failed drive step by step", runReplace},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: diskutil <command> [flags] [args]\n\nCommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+}
+
+const defaultAuditLog string = "/var/log/diskutil-audit.jsonl"
+
+// diskStatusFlags 是所有子命令公用的参数
+type diskStatusFlags struct {
+	megaPath     string
+	adapterCount int
+	autoDetect   bool
+	dryRun       bool
+	auditLog     string
+}
+
+func addDiskStatusFlags(fs *flag.FlagSet) *diskStatusFlags {
+	f := new(diskStatusFlags)
+	fs.StringVar(&f.megaPath, "mega-path", "/opt/MegaRAID/MegaCli/MegaCli64", "megaCli binary path")
+	fs.IntVar(&f.adapterCount, "adapter-count", 1, "adapter count in your server")
+	fs.BoolVar(&f.autoDetect, "auto", false, "select the RAID tools, md arrays and nvme drives automatically")
+	fs.BoolVar(&f.dryRun, "dry-run", false, "print every MegaCli command which changes the configuration instead of running it")
+	fs.StringVar(&f.auditLog, "audit-log", defaultAuditLog, "append every MegaCli command which changes the configuration to this file, empty disables it; the default is only writable by root and commands are refused when the file can not be opened")
+	return f
+}
+
+func (f *diskStatusFlags) newDiskStatus() (*diskutil.DiskStatus, error) {
+	var (
+		ds  *diskutil.DiskStatus
+		err error
+	)
+	if f.autoDetect {
+		ds, err = diskutil.NewDiskStatusAuto(f.adapterCount)
+	} else {
+		ds, err = diskutil.NewDiskStatus(f.megaPath, f.adapterCount)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if f.dryRun {
+		ds.EnableDryRun(os.Stdout)
+	}
+	if f.auditLog != "" {
+		ds.EnableAuditLog(f.auditLog)
+	}
+	return ds, nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		if errors.Is(err, diskutil.ErrAuditLogUnavailable) {
+			err = fmt.Errorf("%w (choose a writable file by -audit-log, or pass -audit-log \"\" to run without the audit log)", err)
+		}
+		fmt.Fprintf(os.Stderr, "diskutil %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
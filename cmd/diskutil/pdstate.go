@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/forever765/diskutil"
+)
+
+var pdStateChanges = map[string]func(ds *diskutil.DiskStatus, adapter, enclosure, slot int) error{
+	"online":          (*diskutil.DiskStatus).PDOnline,
+	"offline":         (*diskutil.DiskStatus).PDOffline,
+	"missing":         (*diskutil.DiskStatus).PDMarkMissing,
+	"good":            (*diskutil.DiskStatus).PDMakeGood,
+	"jbod":            (*diskutil.DiskStatus).PDMakeJBOD,
+	"prepare-removal": (*diskutil.DiskStatus).PDPrepareRemoval,
+}
+
+func runPdState(args []string) error {
+	fs := flag.NewFlagSet("pd-state", flag.ExitOnError)
+	dsFlags := addDiskStatusFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return errors.New("a state (online, offline, missing, good, jbod or prepare-removal) and a slot, serial number or /dev path are required")
+	}
+	change, ok := pdStateChanges[fs.Arg(0)]
+	if !ok {
+		return fmt.Errorf("unknown state: %s", fs.Arg(0))
+	}
+
+	ds, err := dsFlags.newDiskStatus()
+	if err != nil {
+		return err
+	}
+	drive, err := ds.FindPhysicalDrive(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	if drive.Backend != "megacli" {
+		return fmt.Errorf("drive states can only be changed on MegaCli adapters, the drive is on %s", drive.Backend)
+	}
+	pd := drive.PhysicalDrives[0]
+	return change(ds, drive.AdapterId, pd.EnclosureDeviceId, pd.SlotNumber)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/forever765/diskutil"
+)
+
+func runReplace(args []string) error {
+	fs := flag.NewFlagSet("replace", flag.ExitOnError)
+	dsFlags := addDiskStatusFlags(fs)
+	poll := fs.Duration("poll", 0, "how often the new drive and the rebuild are checked, 0 means the default")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("exactly one slot, serial number or /dev path is required")
+	}
+
+	ds, err := dsFlags.newDiskStatus()
+	if err != nil {
+		return err
+	}
+	drive, err := ds.FindPhysicalDrive(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if drive.Backend != "megacli" {
+		return fmt.Errorf("replace is only supported on MegaCli adapters, the drive is on %s", drive.Backend)
+	}
+	pd := drive.PhysicalDrives[0]
+	plan, err := ds.PlanReplace(drive.AdapterId, pd.EnclosureDeviceId, pd.SlotNumber)
+	if err != nil {
+		return err
+	}
+	if *poll > 0 {
+		plan.PollInterval = *poll
+	}
+
+	fmt.Printf("replace adapter %d enclosure %d slot %d: serial %s, %s, %s\n",
+		plan.AdapterId, plan.EnclosureDeviceId, plan.SlotNumber, pd.SerialNumber, pd.FirmwareState, pd.RawSize)
+	if dsFlags.dryRun {
+		for i, step := range plan.Steps {
+			fmt.Printf("%d. %s\n", i+1, step.Description)
+			if step.Command != "" {
+				fmt.Printf("   %s\n", step.Command)
+			}
+		}
+		return nil
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	step := 0
+	return ds.Replace(ctx, plan, func(s diskutil.ReplaceStep) {
+		// 出错后会跳到后面的Cleanup步骤
+		for step < len(plan.Steps) && plan.Steps[step].Description != s.Description {
+			step++
+		}
+		step++
+		fmt.Printf("%d/%d %s\n", step, len(plan.Steps), s.Description)
+	})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/forever765/diskutil"
+)
+
+func runSpare(args []string) error {
+	fs := flag.NewFlagSet("spare", flag.ExitOnError)
+	dsFlags := addDiskStatusFlags(fs)
+	set := fs.String("set", "", "make the drive (E:S, serial or /dev path) a hot spare")
+	remove := fs.String("rm", "", "remove the hot spare (E:S, serial or /dev path)")
+	arrays := fs.String("arrays", "", "dedicate the spare to the arrays, e.g. 0,1, empty means global")
+	affinity := fs.Bool("affinity", false, "set enclosure affinity")
+	nonRevertible := fs.Bool("non-revertible", false, "do not copy back when the failed drive is replaced")
+	fs.Parse(args)
+
+	ds, err := dsFlags.newDiskStatus()
+	if err != nil {
+		return err
+	}
+	if *set != "" || *remove != "" {
+		ident := *set
+		if ident == "" {
+			ident = *remove
+		}
+		drive, err := ds.FindPhysicalDrive(ident)
+		if err != nil {
+			return err
+		}
+		if drive.Backend != "megacli" {
+			return fmt.Errorf("hot spares can only be managed on MegaCli adapters, the drive is on %s", drive.Backend)
+		}
+		pd := drive.PhysicalDrives[0]
+		if *remove != "" {
+			return ds.RemoveHotSpare(drive.AdapterId, pd.EnclosureDeviceId, pd.SlotNumber)
+		}
+		spec := diskutil.HotSpareSpec{EnclosureAffinity: *affinity, NonRevertible: *nonRevertible}
+		for _, array := range strings.Split(*arrays, ",") {
+			if array = strings.TrimSpace(array); array == "" {
+				continue
+			}
+			n, err := strconv.Atoi(array)
+			if err != nil {
+				return fmt.Errorf("array illegal: %s", array)
+			}
+			spec.Arrays = append(spec.Arrays, n)
+		}
+		return ds.SetHotSpare(drive.AdapterId, pd.EnclosureDeviceId, pd.SlotNumber, spec)
+	}
+
+	warnings, err := ds.CheckSparePolicy()
+	if err != nil {
+		return err
+	}
+	for _, ad := range ds.AdapterStats {
+		for _, pd := range ad.PhysicalDriveStats {
+			if pd.HotSpare == nil {
+				continue
+			}
+			fmt.Printf("adapter %d (%s) enclosure %d slot %d: %s spare, arrays %v, revertible %v, enclosure affinity %v, %s %s\n",
+				ad.AdapterId, ad.Backend, pd.EnclosureDeviceId, pd.SlotNumber, pd.HotSpare.Type, pd.HotSpare.Arrays,
+				pd.HotSpare.Revertible, pd.HotSpare.EnclosureAffinity, pd.RawSize, pd.PdMediaType)
+		}
+	}
+	for _, w := range warnings {
+		fmt.Printf("WARNING adapter %d (%s) VD-%d %s: %s\n", w.AdapterId, w.Backend, w.VirtualDrive, w.RaidLevel, w.Reason)
+	}
+	return nil
+}
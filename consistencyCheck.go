@@ -0,0 +1,262 @@
+package diskutil
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Patrol read modes of SetPatrolReadMode().
+const (
+	PatrolReadAuto     string = "auto"
+	PatrolReadManual   string = "manual"
+	PatrolReadDisabled string = "disabled"
+)
+
+// CcScheduleStat is a struct to get the consistency check schedule of a MegaRaid adapter.
+// CorrectedErrors is the count of the errors corrected by the last consistency
+// check of every VD, taken from the event log.
+type CcScheduleStat struct {
+	Mode                string `json:"mode"`
+	ExecutionDelayHours int    `json:"execution_delay_hours"`
+	LastStartTime       string `json:"last_start_time,omitempty"`
+	NextStartTime       string `json:"next_start_time"`
+	CurrentState        string `json:"current_state"`
+	Iterations          int    `json:"iterations"`
+	VdsCompleted        int    `json:"vds_completed"`
+	ExcludedVds         string `json:"excluded_vds"`
+	CorrectedErrors     int    `json:"corrected_errors"`
+}
+
+// PatrolReadStat is a struct to get the patrol read settings and state of a MegaRaid adapter.
+// CorrectedErrors is the count of the medium errors corrected by the current or
+// last patrol read, taken from the event log.
+type PatrolReadStat struct {
+	Mode                string `json:"mode"`
+	ExecutionDelayHours int    `json:"execution_delay_hours"`
+	LastStartTime       string `json:"last_start_time,omitempty"`
+	NextStartTime       string `json:"next_start_time"`
+	CurrentState        string `json:"current_state"`
+	Iterations          int    `json:"iterations"`
+	OnSsd               bool   `json:"on_ssd"`
+	CorrectedErrors     int    `json:"corrected_errors"`
+}
+
+// "Key   : Value" 格式的输出转为map，值中可能带有冒号(时间)
+func megaCliKeyValues(output string) map[string]string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		values[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return values
+}
+
+// "168" 或 "168 hours"
+func leadingInt(value string) int {
+	fileds := strings.Fields(value)
+	if len(fileds) == 0 {
+		return 0
+	}
+	n, _ := strconv.Atoi(fileds[0])
+	return n
+}
+
+// Adapter #0
+//
+// Operation Mode: Concurrent
+// Execution Delay: 168
+// Next start time: 07/12/2014, 03:00:00
+// Current State: Stopped
+// Number of iterations: 20
+// Number of VD completed: 0
+// Excluded VDs          : None
+func parseCcSchedule(output string) (*CcScheduleStat, bool) {
+	values := megaCliKeyValues(output)
+	mode, ok := values["Operation Mode"]
+	if !ok {
+		return nil, false
+	}
+	return &CcScheduleStat{
+		Mode:                mode,
+		ExecutionDelayHours: leadingInt(values["Execution Delay"]),
+		LastStartTime:       values["Previous start time"],
+		NextStartTime:       values["Next start time"],
+		CurrentState:        values["Current State"],
+		Iterations:          leadingInt(values["Number of iterations"]),
+		VdsCompleted:        leadingInt(values["Number of VD completed"]),
+		ExcludedVds:         values["Excluded VDs"],
+	}, true
+}
+
+// Adapter 0: Patrol Read Information:
+//
+// Patrol Read Mode: Auto
+// Patrol Read Execution Delay: 168 hours
+// Number of iterations: 37
+// Current State: Stopped
+// Patrol Read on SSD Devices: Disabled
+func parsePatrolRead(output string) (*PatrolReadStat, bool) {
+	values := megaCliKeyValues(output)
+	mode, ok := values["Patrol Read Mode"]
+	if !ok {
+		return nil, false
+	}
+	return &PatrolReadStat{
+		Mode:                mode,
+		ExecutionDelayHours: leadingInt(values["Patrol Read Execution Delay"]),
+		LastStartTime:       values["Previous start time"],
+		NextStartTime:       values["Next start time"],
+		CurrentState:        values["Current State"],
+		Iterations:          leadingInt(values["Number of iterations"]),
+		OnSsd:               values["Patrol Read on SSD Devices"] == "Enabled",
+	}, true
+}
+
+// getMegaRaidCcPatrolRead() 获取一致性检查计划和巡读状态，卡不支持时保持为空
+func (a *AdapterStat) getMegaRaidCcPatrolRead(command string) {
+	adapterId := strconv.Itoa(a.AdapterId)
+	if cc, ok := parseCcSchedule(queryMegaCliIgnoreExit(command, "-AdpCcSched -Info -a"+adapterId+" -NoLog")); ok {
+		a.CcSchedule = cc
+	}
+	if pr, ok := parsePatrolRead(queryMegaCliIgnoreExit(command, "-AdpPR -Info -a"+adapterId+" -NoLog")); ok {
+		a.PatrolRead = pr
+	}
+}
+
+// CountCorrectedErrors() is used to fill in the corrected_errors of the consistency
+// check schedule, the patrol read and the CC progress of the MegaRaid adapters
+// collected by Get(). -AdpPR -Info and -LDCC -ShowProg do not report them, so they
+// are counted from the whole controller event log. Reading it is slow on a large
+// log, so Get() leaves them at zero and only the callers which need them call this.
+func (d *DiskStatus) CountCorrectedErrors() error {
+	for i := range d.AdapterStats {
+		ad := &d.AdapterStats[i]
+		if ad.Backend != backendMegaCli || (ad.CcSchedule == nil && ad.PatrolRead == nil) {
+			continue
+		}
+		events, err := d.GetEvents(ad.AdapterId)
+		if err != nil {
+			return err
+		}
+		ad.setCorrectedErrors(countCorrectedErrors(events))
+	}
+	return nil
+}
+
+// MegaRaid事件描述的前缀
+const (
+	eventPatrolReadStarted   string = "Patrol Read started"
+	eventPatrolReadCorrected string = "Patrol Read corrected medium error"
+	eventCcStarted           string = "Consistency Check started"
+	eventCcCorrected         string = "Consistency Check corrected medium error"
+	eventCcInconsistent      string = "Consistency Check found inconsistent parity"
+)
+
+// countCorrectedErrors() 统计最近一次巡读和每个VD最近一次一致性检查纠正的错误数，
+// 遇到开始事件时重新计数
+func countCorrectedErrors(events []Event) (int, map[int]int) {
+	sorted := make([]Event, len(events))
+	copy(sorted, events)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].SequenceNumber < sorted[j].SequenceNumber })
+
+	patrolRead := 0
+	cc := make(map[int]int)
+	for _, event := range sorted {
+		switch {
+		case strings.HasPrefix(event.Description, eventPatrolReadStarted):
+			patrolRead = 0
+		case strings.HasPrefix(event.Description, eventPatrolReadCorrected):
+			patrolRead++
+		case event.VirtualDrive == nil:
+		case strings.HasPrefix(event.Description, eventCcStarted):
+			cc[*event.VirtualDrive] = 0
+		case strings.HasPrefix(event.Description, eventCcCorrected),
+			strings.HasPrefix(event.Description, eventCcInconsistent):
+			cc[*event.VirtualDrive]++
+		}
+	}
+	return patrolRead, cc
+}
+
+// setCorrectedErrors() 纠正的错误数写入巡读状态、CC计划和一致性检查中的VD的进度
+func (a *AdapterStat) setCorrectedErrors(patrolRead int, cc map[int]int) {
+	if a.PatrolRead != nil {
+		a.PatrolRead.CorrectedErrors = patrolRead
+	}
+	if a.CcSchedule != nil {
+		a.CcSchedule.CorrectedErrors = 0
+		for _, count := range cc {
+			a.CcSchedule.CorrectedErrors += count
+		}
+	}
+	for i := range a.VirtualDriveStats {
+		progress := a.VirtualDriveStats[i].Progress
+		if progress != nil && progress.Operation == progressConsistencyCheck {
+			progress.CorrectedErrors = cc[a.VirtualDriveStats[i].VirtualDrive]
+		}
+	}
+}
+
+// StartConsistencyCheck() is used to start a consistency check on a VD of a MegaRaid adapter.
+func (d *DiskStatus) StartConsistencyCheck(adapter, virtualDrive int) error {
+	_, err := d.execMegaCli(fmt.Sprintf("-LDCC -Start -L%d -a%d -NoLog", virtualDrive, adapter))
+	return err
+}
+
+// StopConsistencyCheck() is used to abort the running consistency check on a VD of a MegaRaid adapter.
+func (d *DiskStatus) StopConsistencyCheck(adapter, virtualDrive int) error {
+	_, err := d.execMegaCli(fmt.Sprintf("-LDCC -Abort -L%d -a%d -NoLog", virtualDrive, adapter))
+	return err
+}
+
+// SetPatrolReadMode() is used to set the patrol read mode of a MegaRaid adapter
+// to PatrolReadAuto, PatrolReadManual or PatrolReadDisabled.
+func (d *DiskStatus) SetPatrolReadMode(adapter int, mode string) error {
+	args, err := patrolReadModeArgs(adapter, mode)
+	if err != nil {
+		return err
+	}
+	_, err = d.execMegaCli(args)
+	return err
+}
+
+func patrolReadModeArgs(adapter int, mode string) (string, error) {
+	var option string
+	switch mode {
+	case PatrolReadAuto:
+		option = "-EnblAuto"
+	case PatrolReadManual:
+		option = "-EnblMan"
+	case PatrolReadDisabled:
+		option = "-Dsbl"
+	default:
+		return "", errors.New("unknown patrol read mode: " + mode)
+	}
+	return fmt.Sprintf("-AdpPR %s -a%d -NoLog", option, adapter), nil
+}
+
+// SetPatrolReadSchedule() is used to set the delay between two patrol reads of a
+// MegaRaid adapter, in whole hours, and the next start time. A zero start keeps
+// the current start time.
+func (d *DiskStatus) SetPatrolReadSchedule(adapter int, delay time.Duration, start time.Time) error {
+	hours := int(delay.Hours())
+	if hours < 1 {
+		return errors.New("patrol read delay must be at least one hour")
+	}
+	if _, err := d.execMegaCli(fmt.Sprintf("-AdpPR -SetDelay %d -a%d -NoLog", hours, adapter)); err != nil {
+		return err
+	}
+	if start.IsZero() {
+		return nil
+	}
+	// MegaCli只接受整点："yyyymmdd hh"
+	_, err := d.execMegaCli(fmt.Sprintf("-AdpPR -SetStartTime %s %02d -a%d -NoLog", start.Format("20060102"), start.Hour(), adapter))
+	return err
+}
@@ -0,0 +1,108 @@
+package diskutil
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCountCorrectedErrors(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "megacli", "events_cc_pr.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := parseEvents(0, string(data))
+	// 倒序输入，统计时按序列号排序
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+
+	patrolRead, cc := countCorrectedErrors(events)
+	if patrolRead != 2 {
+		t.Errorf("patrol read corrected errors = %d, want 2", patrolRead)
+	}
+	if want := map[int]int{0: 2, 1: 1}; !reflect.DeepEqual(cc, want) {
+		t.Errorf("cc corrected errors = %v, want %v", cc, want)
+	}
+
+	ad := AdapterStat{
+		CcSchedule: &CcScheduleStat{Mode: "Concurrent"},
+		PatrolRead: &PatrolReadStat{Mode: "Auto"},
+		VirtualDriveStats: []VirtualDriveStat{
+			{VirtualDrive: 0, Progress: &ProgressStat{Operation: progressConsistencyCheck, PercentComplete: 40}},
+			{VirtualDrive: 1, Progress: &ProgressStat{Operation: progressBackgroundInitialization, PercentComplete: 7}},
+		},
+	}
+	ad.setCorrectedErrors(patrolRead, cc)
+	if ad.PatrolRead.CorrectedErrors != 2 || ad.CcSchedule.CorrectedErrors != 3 {
+		t.Errorf("adapter corrected errors = %d/%d, want 2/3", ad.PatrolRead.CorrectedErrors, ad.CcSchedule.CorrectedErrors)
+	}
+	if got := ad.VirtualDriveStats[0].Progress.CorrectedErrors; got != 2 {
+		t.Errorf("VD 0 corrected errors = %d, want 2", got)
+	}
+	if got := ad.VirtualDriveStats[1].Progress.CorrectedErrors; got != 0 {
+		t.Errorf("VD 1 is not under consistency check, corrected errors = %d", got)
+	}
+}
+
+// fakeCcMegaCli() 写一个MegaCli64，-AdpEventLog 时把事件日志样本写到 -f 指定的文件
+func fakeCcMegaCli(t *testing.T) (string, string) {
+	events, err := filepath.Abs(filepath.Join("testdata", "megacli", "events_cc_pr.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	script := filepath.Join(dir, "MegaCli64")
+	log := filepath.Join(dir, "args.log")
+	content := "#!/bin/sh\necho \"$*\" >> '" + log + "'\n" +
+		"if [ \"$1\" = -AdpEventLog ]; then cp '" + events + "' \"$4\"; else\n" +
+		"echo 'Operation Mode: Concurrent'\necho 'Patrol Read Mode: Auto'\nfi\necho 'Exit Code: 0x00'\n"
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return script, log
+}
+
+func TestGetCcPatrolReadSkipsEventLog(t *testing.T) {
+	script, log := fakeCcMegaCli(t)
+	ad := AdapterStat{AdapterId: 0}
+	ad.getMegaRaidCcPatrolRead(script)
+	if ad.CcSchedule == nil || ad.PatrolRead == nil {
+		t.Fatalf("cc schedule = %+v, patrol read = %+v", ad.CcSchedule, ad.PatrolRead)
+	}
+	if args := readArgsLog(t, log); strings.Contains(args, "-AdpEventLog") {
+		t.Errorf("the event log was read while collecting:\n%s", args)
+	}
+}
+
+func TestDiskStatusCountCorrectedErrors(t *testing.T) {
+	script, log := fakeCcMegaCli(t)
+	ad := fixtureMegaCliAdapter(t)
+	ad.CcSchedule = &CcScheduleStat{Mode: "Concurrent"}
+	ad.PatrolRead = &PatrolReadStat{Mode: "Auto"}
+	ad.VirtualDriveStats[0].Progress = &ProgressStat{Operation: progressConsistencyCheck, PercentComplete: 40}
+	// 没有巡读和一致性检查的卡不读事件日志
+	other := fixtureMegaCliAdapter(t)
+	other.AdapterId = 1
+	d := newFixtureDiskStatus(t, ad, other)
+	d.megacliPath = script
+	if err := d.Get(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.CountCorrectedErrors(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := d.AdapterStats[0]
+	if got.PatrolRead.CorrectedErrors != 2 || got.CcSchedule.CorrectedErrors != 3 {
+		t.Errorf("adapter corrected errors = %d/%d, want 2/3", got.PatrolRead.CorrectedErrors, got.CcSchedule.CorrectedErrors)
+	}
+	if n := got.VirtualDriveStats[0].Progress.CorrectedErrors; n != 2 {
+		t.Errorf("VD 0 corrected errors = %d, want 2", n)
+	}
+	if args := readArgsLog(t, log); strings.Count(args, "-AdpEventLog") != 1 || !strings.Contains(args, "-a0 -NoLog") {
+		t.Errorf("MegaCli ran\n%s\nwant one -AdpEventLog on adapter 0", args)
+	}
+}
@@ -0,0 +1,387 @@
+package diskutil
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	mediaTypeHdd string = "Hard Disk Device"
+	mediaTypeSsd string = "Solid State Device"
+)
+
+// DriveSelector is a struct to select the member drives of a VDSpec by their
+// properties instead of their slots, e.g. all the unconfigured SSDs.
+type DriveSelector struct {
+	// MediaType is "ssd", "hdd" or empty for both.
+	MediaType string `json:"media_type,omitempty"`
+	// PdType is the interface, e.g. "SAS" or "SATA", empty for all.
+	PdType string `json:"pd_type,omitempty"`
+	// Count is how many drives to take, 0 means all the matching drives.
+	Count int `json:"count,omitempty"`
+}
+
+// VDSpec is a struct to describe a VD for CreateVD(). Drives are "E:S" of the
+// member drives, or Selector picks them among the Unconfigured(good) drives.
+// Spans is required by RAID50/60 and defaults to a span per 2 drives for RAID10.
+type VDSpec struct {
+	Adapter     int            `json:"adapter"`
+	RaidLevel   string         `json:"raid_level"`
+	Drives      []string       `json:"drives,omitempty"`
+	Selector    *DriveSelector `json:"selector,omitempty"`
+	Spans       int            `json:"spans,omitempty"`
+	StripSizeKB int            `json:"strip_size_kb,omitempty"`
+	// WritePolicy is "WB" or "WT", ReadPolicy "RA", "NORA" or "ADRA",
+	// IOPolicy "Direct" or "Cached". Empty means the controller default.
+	WritePolicy  string `json:"write_policy,omitempty"`
+	ReadPolicy   string `json:"read_policy,omitempty"`
+	IOPolicy     string `json:"io_policy,omitempty"`
+	CachedBadBBU bool   `json:"cached_bad_bbu,omitempty"`
+}
+
+// 每个RAID级别每个span的最少盘数，以及是否要求偶数
+var vdRaidRules = map[string]struct {
+	minDrives int
+	even      bool
+	spanned   bool
+}{
+	"RAID0":  {1, false, false},
+	"RAID1":  {2, true, false},
+	"RAID5":  {3, false, false},
+	"RAID6":  {3, false, false},
+	"RAID10": {2, true, true},
+	"RAID50": {3, false, true},
+	"RAID60": {3, false, true},
+}
+
+func isSsd(pd *PhysicalDriveStat) bool {
+	return pd.PdMediaType == mediaTypeSsd
+}
+
+// selectDrives() 按DriveSelector从Unconfigured(good)的盘中选盘，按 E:S 排序
+func selectDrives(pds []PhysicalDriveStat, selector *DriveSelector) ([]PhysicalDriveStat, error) {
+	selected := make([]PhysicalDriveStat, 0)
+	for i := range pds {
+		pd := &pds[i]
+		if !strings.HasPrefix(pd.FirmwareState, "Unconfigured(good)") {
+			continue
+		}
+		switch strings.ToLower(selector.MediaType) {
+		case "ssd":
+			if !isSsd(pd) {
+				continue
+			}
+		case "hdd":
+			if isSsd(pd) {
+				continue
+			}
+		case "":
+		default:
+			return nil, errors.New("unknown media type: " + selector.MediaType)
+		}
+		if selector.PdType != "" && !strings.EqualFold(pd.PdType, selector.PdType) {
+			continue
+		}
+		selected = append(selected, *pd)
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		if selected[i].EnclosureDeviceId != selected[j].EnclosureDeviceId {
+			return selected[i].EnclosureDeviceId < selected[j].EnclosureDeviceId
+		}
+		return selected[i].SlotNumber < selected[j].SlotNumber
+	})
+	if selector.Count > 0 {
+		if len(selected) < selector.Count {
+			return nil, fmt.Errorf("only %d drives match the selector, %d required", len(selected), selector.Count)
+		}
+		selected = selected[:selector.Count]
+	}
+	return selected, nil
+}
+
+// "32:4" 或 ":4"，没有背板时enclosure为999
+func parseEnclosureSlot(drive string) (int, int, error) {
+	matches := enclosureSlotRegex.FindStringSubmatch(drive)
+	if matches == nil || matches[1] != "" {
+		return 0, 0, errors.New("drive illegal, E:S required: " + drive)
+	}
+	enclosure := 999
+	if matches[2] != "" {
+		enclosure, _ = strconv.Atoi(matches[2])
+	}
+	slot, _ := strconv.Atoi(matches[3])
+	return enclosure, slot, nil
+}
+
+// vdMembers() 解析 spec 中的成员盘，并检查都是 Unconfigured(good) 且类型一致
+func vdMembers(pds []PhysicalDriveStat, spec VDSpec) ([]PhysicalDriveStat, error) {
+	if len(spec.Drives) > 0 && spec.Selector != nil {
+		return nil, errors.New("drives and selector are exclusive")
+	}
+	if spec.Selector != nil {
+		return selectDrives(pds, spec.Selector)
+	}
+
+	members := make([]PhysicalDriveStat, 0, len(spec.Drives))
+	seen := make(map[string]bool)
+	for _, drive := range spec.Drives {
+		enclosure, slot, err := parseEnclosureSlot(drive)
+		if err != nil {
+			return nil, err
+		}
+		key := fmt.Sprintf("%d:%d", enclosure, slot)
+		if seen[key] {
+			return nil, errors.New("drive listed twice: " + drive)
+		}
+		seen[key] = true
+
+		var found *PhysicalDriveStat
+		for i := range pds {
+			if pds[i].EnclosureDeviceId == enclosure && pds[i].SlotNumber == slot {
+				found = &pds[i]
+			}
+		}
+		if found == nil {
+			return nil, errors.New("no drive found in " + drive)
+		}
+		if !strings.HasPrefix(found.FirmwareState, "Unconfigured(good)") {
+			return nil, fmt.Errorf("drive %s is %q, Unconfigured(good) required", drive, found.FirmwareState)
+		}
+		members = append(members, *found)
+	}
+	return members, nil
+}
+
+// CreateVDCommand() is used to validate the spec against the current PD states
+// and get the MegaCli command line which creates the VD, without running it.
+// CachedBadBBU requires force, as in CreateVD().
+func (d *DiskStatus) CreateVDCommand(spec VDSpec, force bool) (string, error) {
+	args, err := d.createVdArgs(spec, force)
+	if err != nil {
+		return "", err
+	}
+	return d.megacliPath + " " + args, nil
+}
+
+// CreateVD() is used to validate the spec against the current PD states and
+// create the VD by "-CfgLdAdd", or "-CfgSpanAdd" for RAID10/50/60. CachedBadBBU
+// loses the cached data on power failure and requires force.
+func (d *DiskStatus) CreateVD(spec VDSpec, force bool) error {
+	args, err := d.createVdArgs(spec, force)
+	if err != nil {
+		return err
+	}
+	_, err = d.execMegaCli(args)
+	return err
+}
+
+func (d *DiskStatus) createVdArgs(spec VDSpec, force bool) (string, error) {
+	if d.megacliPath == "" {
+		return "", errors.New("megaCli backend required")
+	}
+	if err := d.GetPhysicalDrive(); err != nil {
+		return "", err
+	}
+	var pds []PhysicalDriveStat
+	for _, ad := range d.AdapterStats {
+		if ad.Backend == backendMegaCli && ad.AdapterId == spec.Adapter {
+			pds = ad.PhysicalDriveStats
+		}
+	}
+	return createVdArgsFor(spec, pds, force)
+}
+
+// createVdArgsFor() 按给定的PD状态检查spec并生成MegaCli参数
+func createVdArgsFor(spec VDSpec, pds []PhysicalDriveStat, force bool) (string, error) {
+	raidLevel := normalizeRaidLevel(spec.RaidLevel)
+	rule, ok := vdRaidRules[raidLevel]
+	if !ok {
+		return "", errors.New("raid level not supported: " + spec.RaidLevel)
+	}
+	members, err := vdMembers(pds, spec)
+	if err != nil {
+		return "", err
+	}
+	if len(members) == 0 {
+		return "", errors.New("no member drive")
+	}
+	for i := range members {
+		if members[i].PdMediaType != members[0].PdMediaType || members[i].PdType != members[0].PdType {
+			return "", errors.New("member drives mix media types or interfaces")
+		}
+	}
+
+	spans := 1
+	if rule.spanned {
+		spans = spec.Spans
+		if spans == 0 && raidLevel == "RAID10" {
+			spans = len(members) / 2
+		}
+		if spans < 2 {
+			return "", errors.New(raidLevel + " requires at least 2 spans")
+		}
+	} else if spec.Spans > 1 {
+		return "", errors.New(raidLevel + " can not be spanned")
+	}
+	if len(members)%spans != 0 {
+		return "", fmt.Errorf("%d drives can not be split into %d spans", len(members), spans)
+	}
+	perSpan := len(members) / spans
+	if perSpan < rule.minDrives || (rule.even && perSpan%2 != 0) {
+		return "", fmt.Errorf("%s requires at least %d drives per span, got %d", raidLevel, rule.minDrives, perSpan)
+	}
+	if raidLevel == "RAID1" && perSpan != 2 {
+		return "", errors.New("RAID1 requires exactly 2 drives")
+	}
+
+	policies, err := vdPolicyArgs(spec, force)
+	if err != nil {
+		return "", err
+	}
+	drives := make([]string, 0, len(members))
+	for _, pd := range members {
+		drives = append(drives, strings.TrimSuffix(strings.TrimPrefix(physDrv(pd.EnclosureDeviceId, pd.SlotNumber), "-physdrv["), "]"))
+	}
+	level := strings.TrimPrefix(raidLevel, "RAID")
+	var args string
+	if !rule.spanned {
+		args = fmt.Sprintf("-CfgLdAdd -r%s[%s]", level, strings.Join(drives, ","))
+	} else {
+		args = "-CfgSpanAdd -r" + level
+		for i := 0; i < spans; i++ {
+			args += fmt.Sprintf(" -Array%d[%s]", i, strings.Join(drives[i*perSpan:(i+1)*perSpan], ","))
+		}
+	}
+	return fmt.Sprintf("%s%s -a%d -NoLog", args, policies, spec.Adapter), nil
+}
+
+var (
+	vdWritePolicies = []string{"WB", "WT"}
+	vdReadPolicies  = []string{"RA", "NORA", "ADRA"}
+	vdIOPolicies    = []string{"Direct", "Cached"}
+)
+
+// 不区分大小写匹配，返回MegaCli的写法
+func canonicalPolicy(value string, allowed []string) (string, error) {
+	for _, policy := range allowed {
+		if strings.EqualFold(value, policy) {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("policy %s illegal, one of %v required", value, allowed)
+}
+
+// 缓存策略和条带大小参数
+func vdPolicyArgs(spec VDSpec, force bool) (string, error) {
+	args := ""
+	for _, policy := range []struct {
+		value   string
+		allowed []string
+	}{
+		{spec.WritePolicy, vdWritePolicies},
+		{spec.ReadPolicy, vdReadPolicies},
+		{spec.IOPolicy, vdIOPolicies},
+	} {
+		if policy.value == "" {
+			continue
+		}
+		value, err := canonicalPolicy(policy.value, policy.allowed)
+		if err != nil {
+			return "", err
+		}
+		args += " " + value
+	}
+	if spec.CachedBadBBU {
+		prop, err := cachedBadBBUProp(true, force)
+		if err != nil {
+			return "", err
+		}
+		args += " " + prop
+	}
+	if spec.StripSizeKB != 0 {
+		size := spec.StripSizeKB
+		if size < 8 || size > 1024 || size&(size-1) != 0 {
+			return "", fmt.Errorf("strip size %dKB illegal, a power of 2 between 8 and 1024 required", size)
+		}
+		args += fmt.Sprintf(" -strpsz%d", size)
+	}
+	return args, nil
+}
+
+// deleteVdToken() 由VD的名字、容量和成员盘序列号生成，VD变化后旧的token失效
+func deleteVdToken(adapter int, vd *VirtualDriveStat, members []PhysicalDriveStat) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d/%d/%s/%s/%s", adapter, vd.VirtualDrive, vd.Name, vd.Size, vd.RaidLevel)
+	for _, pd := range members {
+		fmt.Fprintf(h, "/%s", pd.SerialNumber)
+	}
+	return fmt.Sprintf("delete-a%d-vd%d-%x", adapter, vd.VirtualDrive, h.Sum(nil)[:4])
+}
+
+// findVd() 重新采集并返回MegaRaid adapter上的VD和它的成员盘
+func (d *DiskStatus) findVd(adapter, virtualDrive int) (*VirtualDriveStat, []PhysicalDriveStat, error) {
+	if err := d.Get(); err != nil {
+		return nil, nil, err
+	}
+	for _, ad := range d.AdapterStats {
+		if ad.Backend != backendMegaCli || ad.AdapterId != adapter {
+			continue
+		}
+		for i := range ad.VirtualDriveStats {
+			vd := ad.VirtualDriveStats[i]
+			if vd.VirtualDrive != virtualDrive {
+				continue
+			}
+			// 不知道成员盘时token无法反映成员盘的变化
+			if vd.diskGroup == "" {
+				return nil, nil, fmt.Errorf("the member drives of VD %d on adapter %d are unknown", virtualDrive, adapter)
+			}
+			members := make([]PhysicalDriveStat, 0)
+			for j := range ad.PhysicalDriveStats {
+				if isVdMember(&vd, &ad.PhysicalDriveStats[j]) {
+					members = append(members, ad.PhysicalDriveStats[j])
+				}
+			}
+			return &vd, members, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("no VD %d found on adapter %d", virtualDrive, adapter)
+}
+
+// DeleteVDToken() is used to get the confirmation token which DeleteVD() requires.
+// The token changes when the VD does, so a token can not delete another VD.
+func (d *DiskStatus) DeleteVDToken(adapter, virtualDrive int) (string, error) {
+	vd, members, err := d.findVd(adapter, virtualDrive)
+	if err != nil {
+		return "", err
+	}
+	return deleteVdToken(adapter, vd, members), nil
+}
+
+// DeleteVD() is used to delete a VD of a MegaRaid adapter by "-CfgLdDel".
+// token must be the one returned by DeleteVDToken(), and a VD with mounted
+// filesystems is never deleted.
+func (d *DiskStatus) DeleteVD(adapter, virtualDrive int, token string) error {
+	vd, members, err := d.findVd(adapter, virtualDrive)
+	if err != nil {
+		return err
+	}
+	if token != deleteVdToken(adapter, vd, members) {
+		return errors.New("confirmation token does not match the VD, get a new one by DeleteVDToken()")
+	}
+	if vd.OsDevice != nil && vd.OsDevice.Usage != nil {
+		if mountPoints := vd.OsDevice.Usage.AllMountPoints(); len(mountPoints) > 0 {
+			return fmt.Errorf("VD %d is mounted on %v", virtualDrive, mountPoints)
+		}
+	}
+	_, err = d.execMegaCli(deleteVdArgs(adapter, virtualDrive))
+	return err
+}
+
+func deleteVdArgs(adapter, virtualDrive int) string {
+	return fmt.Sprintf("-CfgLdDel -L%d -a%d -NoLog", virtualDrive, adapter)
+}
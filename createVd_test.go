@@ -0,0 +1,85 @@
+package diskutil
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCreateVdArgsCachedBadBBU(t *testing.T) {
+	pds := []PhysicalDriveStat{
+		{EnclosureDeviceId: 32, SlotNumber: 0, FirmwareState: "Unconfigured(good), Spun Up", PdType: "SAS", PdMediaType: mediaTypeHdd},
+		{EnclosureDeviceId: 32, SlotNumber: 1, FirmwareState: "Unconfigured(good), Spun Up", PdType: "SAS", PdMediaType: mediaTypeHdd},
+	}
+	spec := VDSpec{Adapter: 0, RaidLevel: "1", Drives: []string{"32:0", "32:1"}, WritePolicy: "wb", CachedBadBBU: true}
+
+	if _, err := createVdArgsFor(spec, pds, false); err == nil {
+		t.Error("CachedBadBBU accepted without force")
+	}
+	args, err := createVdArgsFor(spec, pds, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "-CfgLdAdd -r1[32:0,32:1] WB CachedBadBBU -a0 -NoLog"; args != want {
+		t.Errorf("args = %q, want %q", args, want)
+	}
+}
+
+func TestDeleteVD(t *testing.T) {
+	ad := fixtureMegaCliAdapter(t)
+	for i := range ad.PhysicalDriveStats {
+		ad.PhysicalDriveStats[i].SerialNumber = fmt.Sprintf("SN%02d", ad.PhysicalDriveStats[i].SlotNumber)
+	}
+	// VD 2 没有映射到系统，disk group 2 是它的成员盘
+	ad.VirtualDriveStats = append(ad.VirtualDriveStats, VirtualDriveStat{VirtualDrive: 2, RaidLevel: "RAID1", OsPath: "Unknown", diskGroup: "2"})
+	ad.PhysicalDriveStats = append(ad.PhysicalDriveStats,
+		PhysicalDriveStat{EnclosureDeviceId: 32, SlotNumber: 8, FirmwareState: "Online, Spun Up", PdDiskGroup: "2", SerialNumber: "SN08", OsPath: "Unknown"},
+		PhysicalDriveStat{EnclosureDeviceId: 32, SlotNumber: 9, FirmwareState: "Online, Spun Up", PdDiskGroup: "2", SerialNumber: "SN09", OsPath: "Unknown"},
+	)
+	d, out := newDryRunDiskStatus(t, ad)
+	backend := d.backends[0].(*fixedBackend)
+
+	_, members, err := d.findVd(0, 129)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 2 || members[0].SlotNumber != 2 || members[1].SlotNumber != 3 {
+		t.Errorf("members of VD 129 = %+v, want 32:2 and 32:3", members)
+	}
+
+	token, err := d.DeleteVDToken(0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 成员盘换过之后token失效
+	backend.ads[0].PhysicalDriveStats[len(ad.PhysicalDriveStats)-1].SerialNumber = "SN10"
+	if err := d.DeleteVD(0, 2, token); err == nil || !strings.Contains(err.Error(), "token does not match") {
+		t.Errorf("DeleteVD() with a stale token = %v", err)
+	}
+	if token, err = d.DeleteVDToken(0, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.DeleteVD(0, 2, token); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "MegaCli64 -CfgLdDel -L2 -a0 -NoLog\n" {
+		t.Errorf("dry-run printed %q", out.String())
+	}
+
+	// VD 129上的LVM挂载在 /var/lib/mysql
+	out.Reset()
+	if token, err = d.DeleteVDToken(0, 129); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.DeleteVD(0, 129, token); err == nil || !strings.Contains(err.Error(), "/var/lib/mysql") {
+		t.Errorf("DeleteVD() of a mounted VD = %v", err)
+	}
+
+	backend.ads[0].VirtualDriveStats[2].diskGroup = ""
+	if _, err := d.DeleteVDToken(0, 2); err == nil || !strings.Contains(err.Error(), "member drives of VD 2 on adapter 0 are unknown") {
+		t.Errorf("DeleteVDToken() without members = %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("dry-run printed %q", out.String())
+	}
+}
@@ -4,9 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
-	"path"
+	"path/filepath"
 	"strings"
 )
 
@@ -20,6 +21,10 @@ const (
 	keyVdNumberOfDrives         string = "Number Of Drives"
 	keyVdEncryptiontype         string = "Encryption type"
 	keyVdOsPath                 string = "Os Path"
+	keyVdRaidLevel              string = "RAID Level"
+	keyVdCurrentCachePolicy     string = "Current Cache Policy"
+	keyVdDefaultCachePolicy     string = "Default Cache Policy"
+	keyVdDiskCachePolicy        string = "Disk Cache Policy"
 	keyPdEnclosureDeviceId      string = "Enclosure Device ID"
 	keyPdSlotNumber             string = "Slot Number"
 	keyPdDeviceId               string = "Device Id"
@@ -33,6 +38,11 @@ const (
 	keyPdInquiryData            string = "Inquiry Data"
 	keyPdDiskGroup              string = "DiskGroup"
 	keyPdDriveTemperature       string = "Drive Temperature"
+	keyPdSasAddress             string = "SAS Address(0)"
+	keyPdWwn                    string = "WWN"
+	keyPdHotSpareType           string = "Type"
+	keyPdHotSpareArray          string = "Array #"
+	keyPdForeignState           string = "Foreign State"
 
 	typeString int = iota
 	typeInt
@@ -43,6 +53,14 @@ const (
 type DiskStatus struct {
 	megacliPath  string
 	adapterCount int
+	backends     []Backend
+	resolver     *osDeviceResolver
+	// smartctl为空时不采集SMART数据
+	smartctlPath     string
+	smartConcurrency int
+	// dryRun不为空时只打印会修改配置的命令，auditLog不为空时记录每条执行的修改命令
+	dryRun       io.Writer
+	auditLog     string
 	AdapterStats []AdapterStat `json:"adapter_stats"`
 }
 
@@ -71,16 +89,77 @@ func fileExist(filename string) bool {
 
 // NewDiskStatus() use the megaCliPath and apapterCount to build a DiskStatus.
 func NewDiskStatus(megaCliPath string, adapterCount int) (*DiskStatus, error) {
-	megaCliPath = path.Clean(megaCliPath)
-	if !fileExist(megaCliPath) {
-		return nil, errors.New("megaCli not exist")
+	backend, err := NewMegaCliBackend(megaCliPath, adapterCount)
+	if err != nil {
+		return nil, err
+	}
+	return NewDiskStatusWithBackends(backend)
+}
+
+// NewDiskStatusWithBackends() use the given Backends to build a DiskStatus.
+// The AdapterStats of all the Backends are collected into the same DiskStatus.
+func NewDiskStatusWithBackends(backends ...Backend) (*DiskStatus, error) {
+	if len(backends) == 0 {
+		return nil, errors.New("no backend provided")
 	}
 	ds := new(DiskStatus)
-	ds.megacliPath = megaCliPath
-	ds.adapterCount = adapterCount
+	for _, backend := range backends {
+		if mb, ok := backend.(*MegaCliBackend); ok && ds.megacliPath == "" {
+			ds.megacliPath = mb.megacliPath
+			ds.adapterCount = mb.adapterCount
+		}
+	}
+	ds.backends = backends
+	ds.resolver = newOsDeviceResolver("", "")
 	return ds, nil
 }
 
+// SetSysfsRoot() is used to change the sysfs and /dev roots used to map the drives
+// to the OS block devices, for the DiskStatus and all of its Backends. It is useful for tests.
+func (d *DiskStatus) SetSysfsRoot(sysRoot, devRoot string) {
+	resolver := newOsDeviceResolver(sysRoot, devRoot)
+	resolver.procRoot = d.resolver.procRoot
+	resolver.udevRoot = d.resolver.udevRoot
+	d.resolver = resolver
+	for _, backend := range d.backends {
+		if b, ok := backend.(interface{ SetSysfsRoot(string, string) }); ok {
+			b.SetSysfsRoot(sysRoot, devRoot)
+		}
+	}
+}
+
+// NewDiskStatusAuto() use the RAID tool and md arrays found on the server to build a DiskStatus.
+// adapterCount is only used when MegaCli64 is selected.
+func NewDiskStatusAuto(adapterCount int) (*DiskStatus, error) {
+	backends, err := DetectBackends(adapterCount)
+	if err != nil {
+		return nil, err
+	}
+	return NewDiskStatusWithBackends(backends...)
+}
+
+// Backends() is used to get the names of the Backends of a DiskStatus.
+func (d *DiskStatus) Backends() []string {
+	names := make([]string, 0, len(d.backends))
+	for _, backend := range d.backends {
+		names = append(names, backend.Name())
+	}
+	return names
+}
+
+// SetProcRoot() is used to change the /proc and /run/udev roots used to get
+// the mount points and filesystems on the drives. It is useful for tests.
+func (d *DiskStatus) SetProcRoot(procRoot, udevRoot string) {
+	if procRoot == "" {
+		procRoot = defaultProcRoot
+	}
+	if udevRoot == "" {
+		udevRoot = defaultUdevRoot
+	}
+	d.resolver.procRoot = filepath.Clean(procRoot)
+	d.resolver.udevRoot = filepath.Clean(udevRoot)
+}
+
 func execCmd(command, args string) (string, error) {
 	var argArray []string
 	if args != "" {
@@ -99,74 +178,104 @@ func execCmd(command, args string) (string, error) {
 	return string(buf), nil
 }
 
-// Get() is used to get all the stat of a DiskStatus.
-func (d *DiskStatus) Get() error {
-	ads := make([]AdapterStat, 0)
+// queryMegaCli() 执行只读的MegaCli命令，并检查 Exit Code
+func (d *DiskStatus) queryMegaCli(args string) (string, error) {
+	if d.megacliPath == "" {
+		return "", errors.New("megaCli backend required")
+	}
+	output, err := runMegaCli(d.megacliPath, args)
+	if err != nil {
+		return "", err
+	}
+	return output, nil
+}
 
-	command := d.megacliPath
-	for i := 0; i < d.adapterCount; i++ {
-		ad := AdapterStat{
-			AdapterId: i,
-		}
-		err := ad.getMegaRaidVdInfo(command)
+// runMegaCli() MegaCli的进程退出码就是 Exit Code，所以退出码不为0时也保留输出，
+// 由输出中的 Exit Code 判断结果，不为0x00时返回输出和 megaCliExitError
+func runMegaCli(command, args string) (string, error) {
+	buf, err := exec.Command(command, strings.Split(args, " ")...).Output()
+	output := string(buf)
+	parts := strings.SplitN(output, keyExitResult, 2)
+	if len(parts) != 2 {
 		if err != nil {
-			d.AdapterStats = nil
-			return err
-		}
-		err = ad.getMegaRaidPdInfo(command)
-		if err != nil {
-			d.AdapterStats = nil
-			return err
+			return "", fmt.Errorf("megaCli failed: %v (Arguments: %s)", err, args)
 		}
-		ads = append(ads, ad)
+		return "", errors.New("megaCli output illegal")
 	}
+	result := strings.TrimSpace(parts[1])
+	if result != "0x00" {
+		return output, megaCliExitError(result)
+	}
+	return output, nil
+}
 
-	d.AdapterStats = ads
-	return nil
+// queryMegaCliIgnoreExit() 用于状态查询：-ShowProg 在没有进行中的操作时、-Info 在卡不支持时
+// Exit Code 不为0x00，但输出依然可以解析；无法执行或输出不合法时返回空
+func queryMegaCliIgnoreExit(command, args string) string {
+	output, err := runMegaCli(command, args)
+	var exitErr megaCliExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return ""
+	}
+	return output
 }
 
-// GetVirtualDrive() is used to get the VirtualDriveStat of a DiskStatus.
-func (d *DiskStatus) GetVirtualDrive() error {
-	ads := make([]AdapterStat, 0)
+// megaCliExitError 是 Exit Code 不为0x00时的错误，值为 "0x01" 这样的退出码
+type megaCliExitError string
 
-	command := d.megacliPath
-	for i := 0; i < d.adapterCount; i++ {
-		ad := AdapterStat{
-			AdapterId: i,
-		}
-		err := ad.getMegaRaidVdInfo(command)
-		if err != nil {
-			d.AdapterStats = nil
-			return err
-		}
-		ads = append(ads, ad)
-	}
+func (e megaCliExitError) Error() string {
+	return "megaCli return error: " + string(e)
+}
 
-	d.AdapterStats = ads
-	return nil
+// execMegaCli() 执行会修改RAID卡配置的MegaCli命令，dry-run时只打印，开启审计时写审计日志
+func (d *DiskStatus) execMegaCli(args string) (string, error) {
+	if d.megacliPath == "" {
+		return "", errors.New("megaCli backend required")
+	}
+	if d.dryRun != nil {
+		_, err := fmt.Fprintf(d.dryRun, "%s %s\n", d.megacliPath, args)
+		return "", err
+	}
+	if d.auditLog != "" {
+		return d.execMegaCliAudited(args)
+	}
+	return d.queryMegaCli(args)
 }
 
-// GetPhysicalDrive() is used to get the PhysicalDriveStat of a DiskStatus.
-func (d *DiskStatus) GetPhysicalDrive() error {
+func (d *DiskStatus) collect(get func(Backend) ([]AdapterStat, error)) error {
 	ads := make([]AdapterStat, 0)
 
-	command := d.megacliPath
-	for i := 0; i < d.adapterCount; i++ {
-		ad := AdapterStat{
-			AdapterId: i,
-		}
-		err := ad.getMegaRaidPdInfo(command)
+	for _, backend := range d.backends {
+		stats, err := get(backend)
 		if err != nil {
 			d.AdapterStats = nil
 			return err
 		}
-		ads = append(ads, ad)
+		ads = append(ads, stats...)
 	}
+	d.resolver.fillMultipath(ads)
+	d.resolver.fillOsDevices(ads)
+	d.enrichSmart(ads)
 
 	d.AdapterStats = ads
 	return nil
 }
 
+// Get() is used to get all the stat of a DiskStatus.
+func (d *DiskStatus) Get() error {
+	return d.collect(Backend.Get)
+}
+
+// GetVirtualDrive() is used to get the VirtualDriveStat of a DiskStatus.
+func (d *DiskStatus) GetVirtualDrive() error {
+	return d.collect(Backend.GetVirtualDrive)
+}
+
+// GetPhysicalDrive() is used to get the PhysicalDriveStat of a DiskStatus.
+func (d *DiskStatus) GetPhysicalDrive() error {
+	return d.collect(Backend.GetPhysicalDrive)
+}
+
 // ListBrokenDrive() is used to list the Broken Drives of a DiskStatus.
 func (d *DiskStatus) ListBrokenDrive() ([]VirtualDriveStat, []PhysicalDriveStat, error) {
 	brokenVds, err := d.ListBrokenVirtualDrive()
@@ -200,6 +309,11 @@ func (d *DiskStatus) ListBrokenVirtualDrive() ([]VirtualDriveStat, error) {
 	return brokenVds, nil
 }
 
+// pdHealthy() Online的盘和直通给系统的JBOD盘(如IT模式HBA上的RDY盘)都是正常的
+func pdHealthy(state string) bool {
+	return strings.Contains(state, "Online") || strings.HasPrefix(state, "JBOD")
+}
+
 // ListBrokenPhysicalDrive() is used to list the Broken Physical Drives of a DiskStatus.
 func (d *DiskStatus) ListBrokenPhysicalDrive() ([]PhysicalDriveStat, error) {
 	err := d.GetPhysicalDrive()
@@ -210,7 +324,7 @@ func (d *DiskStatus) ListBrokenPhysicalDrive() ([]PhysicalDriveStat, error) {
 	brokenPds := make([]PhysicalDriveStat, 0)
 	for _, ads := range d.AdapterStats {
 		for _, pds := range ads.PhysicalDriveStats {
-			if !strings.Contains(pds.FirmwareState, "Online") {
+			if !pdHealthy(pds.FirmwareState) {
 				brokenPds = append(brokenPds, pds)
 			}
 		}
@@ -0,0 +1,333 @@
+package diskutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MegaCli事件时间格式 "Mon Oct 12 10:24:03 2020"
+const megaCliEventTimeLayout string = "Mon Jan _2 15:04:05 2006"
+
+var (
+	// "PD 0a(e0x20/s10)"，没有背板时为 "PD 0a(s10)"
+	eventPdRegex = regexp.MustCompile(`PD ([0-9a-fA-F]+)\((?:e0x([0-9a-fA-F]+)/)?s(\d+)\)`)
+	// "VD 00/0"
+	eventVdRegex = regexp.MustCompile(`VD ([0-9a-fA-F]+)/(\d+)`)
+	// "Sense: 3/11/00"
+	eventSenseRegex = regexp.MustCompile(`Sense: ([0-9a-fA-F]+)/([0-9a-fA-F]+)/([0-9a-fA-F]+)`)
+
+	eventClasses = map[int]string{
+		-2: "debug",
+		-1: "progress",
+		0:  "info",
+		1:  "warning",
+		2:  "critical",
+		3:  "fatal",
+		4:  "dead",
+	}
+	eventLocales = []string{"ld", "pd", "enclosure", "bbu", "sas", "controller", "config", "cluster"}
+)
+
+// EventPd is a struct to get the PD an Event is about.
+type EventPd struct {
+	DeviceId          int `json:"device_id"`
+	EnclosureDeviceId int `json:"enclosure_device_id"`
+	SlotNumber        int `json:"slot_number"`
+}
+
+// Event is a struct to get one entry of the event log of a MegaRaid adapter.
+// Time is zero for the events logged before the clock was set, they have
+// SecondsSinceReboot instead.
+type Event struct {
+	AdapterId          int        `json:"adapter_id"`
+	SequenceNumber     uint32     `json:"sequence_number"`
+	Time               time.Time  `json:"time"`
+	SecondsSinceReboot int        `json:"seconds_since_reboot,omitempty"`
+	Code               uint32     `json:"code"`
+	Class              int        `json:"class"`
+	ClassName          string     `json:"class_name"`
+	Locale             uint32     `json:"locale"`
+	LocaleNames        []string   `json:"locale_names"`
+	Description        string     `json:"description"`
+	PhysicalDrive      *EventPd   `json:"physical_drive,omitempty"`
+	VirtualDrive       *int       `json:"virtual_drive,omitempty"`
+	Sense              *SenseData `json:"sense,omitempty"`
+}
+
+// String() is used to get the print string.
+func (e *Event) String() string {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+func localeNames(locale uint32) []string {
+	names := make([]string, 0)
+	for i, name := range eventLocales {
+		if locale&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// parseEvents() 解析 -AdpEventLog 输出的事件，每个事件以 "seqNum:" 开头：
+//
+// seqNum: 0x00003f7b
+// Time: Mon Oct 12 10:24:03 2020
+//
+// Code: 0x00000071
+// Class: 0
+// Locale: 0x02
+// Event Description: Unexpected sense: PD 0a(e0x20/s10) Path 5000c500a1b2c3d4, CDB: 28 00 ..., Sense: 3/11/00
+// Event Data:
+// ===========
+// Device ID: 10
+// Enclosure Index: 32
+// Slot Number: 10
+// Sense Length: 18
+// Sense Data:
+// 0070 0000 0003 ...
+func parseEvents(adapterId int, output string) []Event {
+	events := make([]Event, 0)
+	var (
+		event      *Event
+		inSense    bool
+		senseBytes []byte
+		eventPd    EventPd
+		hasPd      bool
+	)
+	flush := func() {
+		if event == nil {
+			return
+		}
+		if hasPd && event.PhysicalDrive == nil {
+			pd := eventPd
+			event.PhysicalDrive = &pd
+		}
+		// 原始sense数据比描述中的 "Sense: 3/11/00" 信息更全
+		if sense, err := DecodeSense(senseBytes); err == nil {
+			event.Sense = sense
+		}
+		events = append(events, *event)
+		event, inSense, senseBytes, eventPd, hasPd = nil, false, nil, EventPd{EnclosureDeviceId: 999}, false
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		kv := strings.SplitN(trimmed, ":", 2)
+		if len(kv) == 2 {
+			key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+			if key == "seqNum" {
+				flush()
+				seq, _ := strconv.ParseUint(value, 0, 32)
+				event = &Event{AdapterId: adapterId, SequenceNumber: uint32(seq), LocaleNames: []string{}}
+				eventPd = EventPd{EnclosureDeviceId: 999}
+				continue
+			}
+			if event == nil {
+				continue
+			}
+			inSense = false
+			switch key {
+			case "Time":
+				if t, err := time.ParseInLocation(megaCliEventTimeLayout, value, time.Local); err == nil {
+					event.Time = t
+				}
+			case "Seconds since last reboot":
+				event.SecondsSinceReboot, _ = strconv.Atoi(value)
+			case "Code":
+				code, _ := strconv.ParseUint(value, 0, 32)
+				event.Code = uint32(code)
+			case "Class":
+				event.Class, _ = strconv.Atoi(value)
+				event.ClassName = eventClasses[event.Class]
+			case "Locale":
+				locale, _ := strconv.ParseUint(value, 0, 32)
+				event.Locale = uint32(locale)
+				event.LocaleNames = localeNames(event.Locale)
+			case "Event Description":
+				event.Description = value
+				parseEventDescription(event)
+			case "Device ID":
+				eventPd.DeviceId, _ = strconv.Atoi(value)
+				hasPd = true
+			case "Enclosure Index":
+				if enclosure, err := strconv.Atoi(value); err == nil {
+					eventPd.EnclosureDeviceId = enclosure
+				}
+			case "Slot Number":
+				eventPd.SlotNumber, _ = strconv.Atoi(value)
+			case "Sense Data":
+				inSense = true
+				senseBytes = appendHexBytes(senseBytes, value)
+			}
+			continue
+		}
+		if event != nil && inSense && trimmed != "" {
+			senseBytes = appendHexBytes(senseBytes, trimmed)
+		}
+	}
+	flush()
+	return events
+}
+
+// 从事件描述中提取PD/VD和sense key
+func parseEventDescription(event *Event) {
+	if matches := eventPdRegex.FindStringSubmatch(event.Description); matches != nil {
+		pd := &EventPd{EnclosureDeviceId: 999}
+		deviceId, _ := strconv.ParseInt(matches[1], 16, 0)
+		pd.DeviceId = int(deviceId)
+		// 0xff 表示没有背板
+		if matches[2] != "" && !strings.EqualFold(matches[2], "ff") {
+			enclosure, _ := strconv.ParseInt(matches[2], 16, 0)
+			pd.EnclosureDeviceId = int(enclosure)
+		}
+		pd.SlotNumber, _ = strconv.Atoi(matches[3])
+		event.PhysicalDrive = pd
+	}
+	if matches := eventVdRegex.FindStringSubmatch(event.Description); matches != nil {
+		vd, _ := strconv.Atoi(matches[2])
+		event.VirtualDrive = &vd
+	}
+	if matches := eventSenseRegex.FindStringSubmatch(event.Description); matches != nil {
+		key, _ := strconv.ParseInt(matches[1], 16, 0)
+		asc, _ := strconv.ParseInt(matches[2], 16, 0)
+		ascq, _ := strconv.ParseInt(matches[3], 16, 0)
+		event.Sense = newSenseData(int(key), int(asc), int(ascq))
+	}
+}
+
+// getEventLog() 执行 -AdpEventLog 获取事件
+func (d *DiskStatus) getEventLog(adapter int, args string) ([]Event, error) {
+	if d.megacliPath == "" {
+		return nil, errors.New("megaCli backend required")
+	}
+	return readMegaCliEventLog(d.megacliPath, adapter, args)
+}
+
+// readMegaCliEventLog() 事件写入临时文件后再解析
+func readMegaCliEventLog(command string, adapter int, args string) ([]Event, error) {
+	dir, err := os.MkdirTemp("", "diskutil-events-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "events.log")
+
+	if _, err := runMegaCli(command, fmt.Sprintf("-AdpEventLog %s -f %s -a%d -NoLog", args, file, adapter)); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return parseEvents(adapter, string(data)), nil
+}
+
+// GetEvents() is used to get all the events in the event log of a MegaRaid adapter.
+func (d *DiskStatus) GetEvents(adapter int) ([]Event, error) {
+	return d.getEventLog(adapter, "-GetEvents")
+}
+
+// GetEventsSinceReboot() is used to get the events of a MegaRaid adapter since the last reboot.
+func (d *DiskStatus) GetEventsSinceReboot(adapter int) ([]Event, error) {
+	return d.getEventLog(adapter, "-GetSinceReboot")
+}
+
+// GetEventsSince() is used to get the events of a MegaRaid adapter newer than the
+// sequence number. MegaCli can not filter by sequence number, so the count of the
+// new events is taken from -GetEventLogInfo and fetched by -GetLatest. When the
+// newest sequence number is lower than seqNum the log was cleared or has wrapped,
+// and all the events in the log are returned.
+func (d *DiskStatus) GetEventsSince(adapter int, seqNum uint32) ([]Event, error) {
+	output, err := d.queryMegaCli(fmt.Sprintf("-AdpEventLog -GetEventLogInfo -a%d -NoLog", adapter))
+	if err != nil {
+		return nil, err
+	}
+	newest, err := strconv.ParseUint(megaCliKeyValues(output)["Newest Sequence Number"], 0, 32)
+	if err != nil {
+		return nil, fmt.Errorf("newest sequence number illegal: %v", err)
+	}
+	if uint32(newest) == seqNum {
+		return []Event{}, nil
+	}
+	// 日志被清空或回绕，seqNum之后的事件已经不在了，返回整个日志
+	if uint32(newest) < seqNum {
+		return d.GetEvents(adapter)
+	}
+
+	events, err := d.getEventLog(adapter, fmt.Sprintf("-GetLatest %d", uint32(newest)-seqNum))
+	if err != nil {
+		return nil, err
+	}
+	newer := make([]Event, 0, len(events))
+	for _, event := range events {
+		if event.SequenceNumber > seqNum {
+			newer = append(newer, event)
+		}
+	}
+	return newer, nil
+}
+
+// GetNewEvents() is used to get the events of a MegaRaid adapter which are newer
+// than the last call. The last seen sequence number of every adapter is kept in
+// stateFile, all the events are returned when it does not exist yet.
+func (d *DiskStatus) GetNewEvents(adapter int, stateFile string) ([]Event, error) {
+	state := make(map[string]uint32)
+	if data, err := os.ReadFile(stateFile); err == nil {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("event state file illegal: %v", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key := strconv.Itoa(adapter)
+	var (
+		events []Event
+		err    error
+	)
+	if seqNum, ok := state[key]; ok {
+		events, err = d.GetEventsSince(adapter, seqNum)
+	} else {
+		events, err = d.GetEvents(adapter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return events, nil
+	}
+
+	// 不和旧的序列号比较，日志清空后序列号从头开始
+	newest := uint32(0)
+	for _, event := range events {
+		if event.SequenceNumber > newest {
+			newest = event.SequenceNumber
+		}
+	}
+	state[key] = newest
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	// 先写临时文件再改名，中途退出不会留下损坏的状态文件
+	tmpFile := stateFile + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmpFile, stateFile); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
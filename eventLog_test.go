@@ -0,0 +1,134 @@
+package diskutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseEvents(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "megacli", "events_cleared.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := parseEvents(1, string(data))
+	if len(events) != 2 {
+		t.Fatalf("events = %+v", events)
+	}
+
+	cleared := events[0]
+	if cleared.AdapterId != 1 || cleared.SequenceNumber != 1 || cleared.Code != 0x30 || cleared.ClassName != "info" {
+		t.Errorf("event 1 = %+v", cleared)
+	}
+	if want := time.Date(2026, 10, 19, 9, 0, 0, 0, time.Local); !cleared.Time.Equal(want) {
+		t.Errorf("event 1 time = %v, want %v", cleared.Time, want)
+	}
+	if len(cleared.LocaleNames) != 1 || cleared.LocaleNames[0] != "controller" {
+		t.Errorf("event 1 locales = %v, want [controller]", cleared.LocaleNames)
+	}
+	if cleared.PhysicalDrive != nil || cleared.Sense != nil {
+		t.Errorf("event 1 has a PD or sense data: %+v", cleared)
+	}
+
+	sense := events[1]
+	if pd := sense.PhysicalDrive; pd == nil || *pd != (EventPd{DeviceId: 10, EnclosureDeviceId: 32, SlotNumber: 10}) {
+		t.Errorf("event 2 PD = %+v, want [32:10] device 10", pd)
+	}
+	// CDB数据不能混进sense数据
+	if s := sense.Sense; s == nil || s.Key != 3 || s.Asc != 0x11 || s.Ascq != 0 || s.Information == nil || *s.Information != 0x1a2b3c {
+		t.Errorf("event 2 sense = %+v, want 3/11/00 at 1a2b3c", s)
+	}
+}
+
+// fakeEventMegaCli() 写一个MegaCli64，-GetEventLogInfo 输出 newest 文件中的序列号，
+// 其它 -AdpEventLog 命令把 events 文件指向的样本写到 -f 指定的文件
+func fakeEventMegaCli(t *testing.T) (string, func(newest, events string)) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "MegaCli64")
+	content := "#!/bin/sh\n" +
+		"if [ \"$2\" = -GetEventLogInfo ]; then echo \"Newest Sequence Number: $(cat '" + dir + "/newest')\"; else\n" +
+		"while [ \"$1\" != -f ]; do shift; done\ncp \"$(cat '" + dir + "/events')\" \"$2\"\nfi\necho 'Exit Code: 0x00'\n"
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	set := func(newest, events string) {
+		path, err := filepath.Abs(filepath.Join("testdata", "megacli", events))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "newest"), []byte(newest), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "events"), []byte(path), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return script, set
+}
+
+func TestGetNewEvents(t *testing.T) {
+	script, set := fakeEventMegaCli(t)
+	d := &DiskStatus{megacliPath: script}
+	stateFile := filepath.Join(t.TempDir(), "events.json")
+
+	tests := []struct {
+		name   string
+		newest string
+		events string
+		state  string
+		want   int
+	}{
+		{"no state file", "0x100f", "events_cc_pr.txt", `{"0":4111}`, 15},
+		{"no new event", "0x100f", "events_cc_pr.txt", `{"0":4111}`, 0},
+		{"log cleared", "0x2", "events_cleared.txt", `{"0":2}`, 2},
+	}
+	for _, tt := range tests {
+		set(tt.newest, tt.events)
+		events, err := d.GetNewEvents(0, stateFile)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(events) != tt.want {
+			t.Errorf("%s: got %d events, want %d", tt.name, len(events), tt.want)
+		}
+		data, err := os.ReadFile(stateFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.state {
+			t.Errorf("%s: state = %s, want %s", tt.name, data, tt.state)
+		}
+		// 状态文件先写临时文件再改名
+		if _, err := os.Stat(stateFile + ".tmp"); !os.IsNotExist(err) {
+			t.Errorf("%s: the temporary state file is left: %v", tt.name, err)
+		}
+	}
+
+	// 增量获取只返回新的事件
+	if err := os.WriteFile(stateFile, []byte(`{"0":4109,"1":7}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	set("0x100f", "events_cc_pr.txt")
+	events, err := d.GetNewEvents(0, stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].SequenceNumber != 0x100e || events[1].SequenceNumber != 0x100f {
+		t.Errorf("events since 0x100d = %+v, want 0x100e and 0x100f", events)
+	}
+	if data, _ := os.ReadFile(stateFile); string(data) != `{"0":4111,"1":7}` {
+		t.Errorf("state = %s, the other adapters must be kept", data)
+	}
+
+	// 损坏的状态文件不能被覆盖
+	if err := os.WriteFile(stateFile, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.GetNewEvents(0, stateFile); err == nil {
+		t.Error("GetNewEvents() accepted a broken state file")
+	}
+	if data, _ := os.ReadFile(stateFile); string(data) != "{" {
+		t.Errorf("the broken state file was rewritten: %s", data)
+	}
+}
@@ -12,11 +12,15 @@ import (
 var (
 	megaPath     string
 	adapterCount int
+	autoDetect   bool
+	smartctl     string
 )
 
 func init() {
 	flag.StringVar(&megaPath, "mega-path", "/opt/MegaRAID/MegaCli/MegaCli64", "megaCli binary path")
 	flag.IntVar(&adapterCount, "adapter-count", 1, "adapter count in your server")
+	flag.BoolVar(&autoDetect, "auto", false, "select storcli64/perccli64/MegaCli64 automatically")
+	flag.StringVar(&smartctl, "smartctl", "", "smartctl binary path, empty means no SMART data")
 }
 
 func keepUppercaseLetters(input string) string {
@@ -31,12 +35,27 @@ func keepUppercaseLetters(input string) string {
 
 func main() {
 	flag.Parse()
-	ds, err := diskutil.NewDiskStatus(megaPath, adapterCount)
+	var (
+		ds  *diskutil.DiskStatus
+		err error
+	)
+	if autoDetect {
+		ds, err = diskutil.NewDiskStatusAuto(adapterCount)
+	} else {
+		ds, err = diskutil.NewDiskStatus(megaPath, adapterCount)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "DiskStatus New error: %v\n", err)
 		return
 	}
 
+	if smartctl != "" {
+		if err := ds.EnableSmart(smartctl, 0); err != nil {
+			fmt.Fprintf(os.Stderr, "DiskStatus EnableSmart error: %v\n", err)
+			return
+		}
+	}
+
 	err = ds.Get()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "DiskStatus Get error: %v\n", err)
@@ -46,7 +65,11 @@ func main() {
 	for _, ads := range ds.AdapterStats {
 		for _, vds := range ads.VirtualDriveStats {
 			vdStatus := vds.State
-			fmt.Printf("VD-%d: status: %s, size: %s, NumberOfDrives:%v, OsPath: %s\n", vds.VirtualDrive, vdStatus, vds.Size, vds.NumberOfDrives, vds.OsPath)
+			mountPoints := []string{}
+			if vds.OsDevice != nil && vds.OsDevice.Usage != nil {
+				mountPoints = vds.OsDevice.Usage.AllMountPoints()
+			}
+			fmt.Printf("VD-%d: status: %s, size: %s, NumberOfDrives:%v, OsPath: %s, MountPoints: %v\n", vds.VirtualDrive, vdStatus, vds.Size, vds.NumberOfDrives, vds.OsPath, mountPoints)
 		}
 		fmt.Printf("\n")
 
@@ -45,3 +45,12 @@ func parseFiled(line, filed string, targetType int) (interface{}, error) {
 	}
 	return nil, errors.New("type not supported")
 }
+
+// 解析 "key    : value" 格式的字段，key中可能带有冒号(例如 Enclosure#/Slot#)，所以按 " : " 分割
+func splitSpacedField(line string) (string, string, bool) {
+	fileds := strings.SplitN(line, " : ", 2)
+	if len(fileds) != 2 {
+		return "", "", false
+	}
+	return strings.TrimSpace(fileds[0]), strings.TrimSpace(fileds[1]), true
+}
@@ -0,0 +1,231 @@
+package diskutil
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ForeignAll selects all the foreign configurations of an adapter.
+	ForeignAll int = -1
+
+	keyForeignGroup  string = "DISK GROUP:"
+	keyForeignPdInfo string = "Physical Disk Information"
+)
+
+// "There are 2 foreign configuration(s) on controller 0."
+// "There is 1 foreign configuration on controller 0."
+// "There is no foreign configuration on controller 0."
+var foreignScanRegex = regexp.MustCompile(`There (?:are|is) (\d+) foreign configuration`)
+
+// ForeignConfig is a struct to get a foreign configuration found on the drives
+// of a MegaRaid adapter, e.g. after moving them from another server.
+type ForeignConfig struct {
+	Index      int                `json:"index"`
+	DiskGroups []ForeignDiskGroup `json:"disk_groups"`
+}
+
+// ForeignDiskGroup is a struct to get a disk group of a ForeignConfig.
+type ForeignDiskGroup struct {
+	DiskGroup          int                 `json:"disk_group"`
+	VirtualDriveStats  []VirtualDriveStat  `json:"virtual_drive_stats"`
+	PhysicalDriveStats []PhysicalDriveStat `json:"physical_drive_stats"`
+}
+
+// ForeignPreview is a struct to get what importing foreign configurations would
+// bring: the VDs as they will be after the import and the drives which carry them.
+// Token must be passed to ImportForeign() or ClearForeign().
+type ForeignPreview struct {
+	AdapterId      int                 `json:"adapter_id"`
+	Index          int                 `json:"index"`
+	VirtualDrives  []VirtualDriveStat  `json:"virtual_drives"`
+	PhysicalDrives []PhysicalDriveStat `json:"physical_drives"`
+	Warnings       []string            `json:"warnings"`
+	Token          string              `json:"token"`
+}
+
+// 没有外部配置时为0
+func parseForeignScan(output string) int {
+	matches := foreignScanRegex.FindStringSubmatch(output)
+	if matches == nil {
+		return 0
+	}
+	count, _ := strconv.Atoi(matches[1])
+	return count
+}
+
+// parseForeignConfig() 解析 -CfgForeign -Dsply/-Preview 的输出，每个disk group下先列VD再列PD：
+//
+// DISK GROUP: 0
+// Number of Spans: 1
+// ...
+// Virtual Drive Information:
+// Virtual Drive: 0 (Target Id: 0)
+// ...
+// Physical Disk Information:
+// Physical Disk: 0
+// Enclosure Device ID: 32
+// ...
+func parseForeignConfig(index int, output string) (*ForeignConfig, error) {
+	config := &ForeignConfig{Index: index, DiskGroups: make([]ForeignDiskGroup, 0)}
+	groups := strings.Split(output, keyForeignGroup)
+	for _, group := range groups[1:] {
+		dg := ForeignDiskGroup{
+			DiskGroup:          leadingInt(strings.TrimSpace(group)),
+			VirtualDriveStats:  make([]VirtualDriveStat, 0),
+			PhysicalDriveStats: make([]PhysicalDriveStat, 0),
+		}
+		vdInfo, pdInfo := group, ""
+		if parts := strings.SplitN(group, keyForeignPdInfo, 2); len(parts) == 2 {
+			vdInfo, pdInfo = parts[0], parts[1]
+		}
+
+		for _, info := range strings.Split(vdInfo, keyVdVirtualDrive) {
+			if !strings.Contains(info, keyVdTargetId) {
+				continue
+			}
+			vd := VirtualDriveStat{}
+			for _, line := range strings.Split(keyVdVirtualDrive+info, "\n") {
+				if err := vd.parseLine(line); err != nil {
+					return nil, err
+				}
+			}
+			dg.VirtualDriveStats = append(dg.VirtualDriveStats, vd)
+		}
+		for _, info := range strings.Split(pdInfo, keyPdEnclosureDeviceId) {
+			if !strings.Contains(info, keyPdSlotNumber) {
+				continue
+			}
+			pd := PhysicalDriveStat{}
+			for _, line := range strings.Split(keyPdEnclosureDeviceId+info, "\n") {
+				if err := pd.parseLine(line); err != nil {
+					return nil, err
+				}
+			}
+			if pd.PdDiskGroup == "" {
+				pd.PdDiskGroup = strconv.Itoa(dg.DiskGroup)
+			}
+			dg.PhysicalDriveStats = append(dg.PhysicalDriveStats, pd)
+		}
+		config.DiskGroups = append(config.DiskGroups, dg)
+	}
+	return config, nil
+}
+
+// "" 表示全部外部配置
+func foreignIndexArg(index int) string {
+	if index == ForeignAll {
+		return ""
+	}
+	return " " + strconv.Itoa(index)
+}
+
+// getMegaRaidForeign() 扫描外部配置，有外部配置时逐个 -Dsply，卡不支持时保持为空
+func (a *AdapterStat) getMegaRaidForeign(command string) {
+	count := parseForeignScan(queryMegaCliIgnoreExit(command, fmt.Sprintf("-CfgForeign -Scan -a%d -NoLog", a.AdapterId)))
+	for i := 0; i < count; i++ {
+		output := queryMegaCliIgnoreExit(command, fmt.Sprintf("-CfgForeign -Dsply %d -a%d -NoLog", i, a.AdapterId))
+		if config, err := parseForeignConfig(i, output); err == nil {
+			a.ForeignConfigs = append(a.ForeignConfigs, *config)
+		}
+	}
+}
+
+// foreignToken() 由预览中的VD和成员盘生成，外部配置变化后旧的token失效
+func foreignToken(preview *ForeignPreview) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d/%d", preview.AdapterId, preview.Index)
+	for _, vd := range preview.VirtualDrives {
+		fmt.Fprintf(h, "/vd%d/%s/%s/%s", vd.VirtualDrive, vd.RaidLevel, vd.Size, vd.State)
+	}
+	for _, pd := range preview.PhysicalDrives {
+		fmt.Fprintf(h, "/pd%d:%d/%s", pd.EnclosureDeviceId, pd.SlotNumber, pd.SerialNumber)
+	}
+	return fmt.Sprintf("foreign-a%d-%x", preview.AdapterId, h.Sum(nil)[:4])
+}
+
+// PreviewForeign() is used to preview importing a foreign configuration of a
+// MegaRaid adapter, or all of them with ForeignAll, by "-CfgForeign -Preview".
+// Warnings list the VDs which would come back degraded or offline.
+func (d *DiskStatus) PreviewForeign(adapter, index int) (*ForeignPreview, error) {
+	output, err := d.queryMegaCli(fmt.Sprintf("-CfgForeign -Scan -a%d -NoLog", adapter))
+	if err != nil {
+		return nil, err
+	}
+	count := parseForeignScan(output)
+	if count == 0 {
+		return nil, fmt.Errorf("no foreign configuration on adapter %d", adapter)
+	}
+	if index != ForeignAll && (index < 0 || index >= count) {
+		return nil, fmt.Errorf("foreign configuration %d not found, adapter %d has %d", index, adapter, count)
+	}
+	output, err = d.queryMegaCli(fmt.Sprintf("-CfgForeign -Preview%s -a%d -NoLog", foreignIndexArg(index), adapter))
+	if err != nil {
+		return nil, err
+	}
+	config, err := parseForeignConfig(index, output)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &ForeignPreview{
+		AdapterId:      adapter,
+		Index:          index,
+		VirtualDrives:  make([]VirtualDriveStat, 0),
+		PhysicalDrives: make([]PhysicalDriveStat, 0),
+		Warnings:       make([]string, 0),
+	}
+	for _, dg := range config.DiskGroups {
+		preview.VirtualDrives = append(preview.VirtualDrives, dg.VirtualDriveStats...)
+		preview.PhysicalDrives = append(preview.PhysicalDrives, dg.PhysicalDriveStats...)
+	}
+
+	for _, vd := range preview.VirtualDrives {
+		if vd.State != "" && vd.State != "Optimal" {
+			preview.Warnings = append(preview.Warnings, fmt.Sprintf("VD %d (%s, %s) would be imported %s", vd.VirtualDrive, vd.RaidLevel, vd.Size, vd.State))
+		}
+	}
+	preview.Token = foreignToken(preview)
+	return preview, nil
+}
+
+// checkForeignToken() 重新预览，确认外部配置和调用者看到的一致
+func (d *DiskStatus) checkForeignToken(adapter, index int, token string) error {
+	preview, err := d.PreviewForeign(adapter, index)
+	if err != nil {
+		return err
+	}
+	if token != preview.Token {
+		return errors.New("confirmation token does not match the foreign configuration, get a new one by PreviewForeign()")
+	}
+	return nil
+}
+
+// ImportForeign() is used to import a foreign configuration of a MegaRaid adapter,
+// or all of them with ForeignAll. token must be the one of PreviewForeign().
+func (d *DiskStatus) ImportForeign(adapter, index int, token string) error {
+	if err := d.checkForeignToken(adapter, index, token); err != nil {
+		return err
+	}
+	_, err := d.execMegaCli(fmt.Sprintf("-CfgForeign -Import%s -a%d -NoLog", foreignIndexArg(index), adapter))
+	return err
+}
+
+// ClearForeign() is used to clear a foreign configuration of a MegaRaid adapter,
+// or all of them with ForeignAll, which loses the VDs on those drives. token must
+// be the one of PreviewForeign().
+func (d *DiskStatus) ClearForeign(adapter, index int, token string) error {
+	if err := d.checkForeignToken(adapter, index, token); err != nil {
+		return err
+	}
+	which := foreignIndexArg(index)
+	if index == ForeignAll {
+		which = " ALL"
+	}
+	_, err := d.execMegaCli(fmt.Sprintf("-CfgForeign -Clear%s -a%d -NoLog", which, adapter))
+	return err
+}
@@ -0,0 +1,209 @@
+package diskutil
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readMegaCliFixture(t *testing.T, name string) string {
+	data, err := os.ReadFile(filepath.Join("testdata", "megacli", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestParseForeignScan(t *testing.T) {
+	tests := map[string]int{
+		"foreign_scan_two.txt":  2,
+		"foreign_scan_one.txt":  1,
+		"foreign_scan_none.txt": 0,
+	}
+	for name, want := range tests {
+		if count := parseForeignScan(readMegaCliFixture(t, name)); count != want {
+			t.Errorf("%s: parseForeignScan() = %d, want %d", name, count, want)
+		}
+	}
+}
+
+func TestParseForeignConfig(t *testing.T) {
+	config, err := parseForeignConfig(0, readMegaCliFixture(t, "foreign_dsply.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.DiskGroups) != 1 {
+		t.Fatalf("disk groups = %+v", config.DiskGroups)
+	}
+	dg := config.DiskGroups[0]
+	if len(dg.VirtualDriveStats) != 1 {
+		t.Fatalf("VDs = %+v", dg.VirtualDriveStats)
+	}
+	vd := dg.VirtualDriveStats[0]
+	if vd.VirtualDrive != 0 || vd.Name != "data" || vd.RaidLevel != "RAID1" || vd.Size != "1.817 TB" || vd.State != "Optimal" || vd.NumberOfDrives != 2 {
+		t.Errorf("VD = %+v", vd)
+	}
+	if len(dg.PhysicalDriveStats) != 2 {
+		t.Fatalf("PDs = %+v", dg.PhysicalDriveStats)
+	}
+	for i, serial := range []string{"0004Z1X2ABCD", "0004Z1X2ABCE"} {
+		pd := dg.PhysicalDriveStats[i]
+		if pd.EnclosureDeviceId != 32 || pd.SlotNumber != 4+i || pd.SerialNumber != serial || pd.PdDiskGroup != "0" ||
+			pd.ForeignState != "Foreign" || pd.FirmwareState != "Unconfigured(good), Spun Up" {
+			t.Errorf("PD %d = %+v", i, pd)
+		}
+	}
+
+	if config, err := parseForeignConfig(0, readMegaCliFixture(t, "foreign_scan_none.txt")); err != nil || len(config.DiskGroups) != 0 {
+		t.Errorf("parseForeignConfig() without a foreign configuration = %+v, %v", config, err)
+	}
+}
+
+// fakeForeignMegaCli() 写一个MegaCli64，-CfgForeign -Scan/-Dsply/-Preview 输出 set() 指定的样本，
+// 其它命令只记录参数
+func fakeForeignMegaCli(t *testing.T) (string, string, func(scan, preview string)) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "MegaCli64")
+	log := filepath.Join(dir, "args.log")
+	content := "#!/bin/sh\ncase \"$2\" in\n" +
+		"-Scan) cat '" + dir + "/scan' ;;\n" +
+		"-Dsply) cat '" + dir + "/dsply' ;;\n" +
+		"-Preview) cat '" + dir + "/preview' ;;\n" +
+		"*) echo \"$*\" >> '" + log + "'; echo 'Exit Code: 0x00' ;;\nesac\n"
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "dsply"), []byte(readMegaCliFixture(t, "foreign_dsply.txt")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	set := func(scan, preview string) {
+		if err := os.WriteFile(filepath.Join(dir, "scan"), []byte(readMegaCliFixture(t, scan)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "preview"), []byte(readMegaCliFixture(t, preview)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return script, log, set
+}
+
+func TestGetMegaRaidForeign(t *testing.T) {
+	script, _, set := fakeForeignMegaCli(t)
+	tests := map[string]int{
+		"foreign_scan_two.txt":  2,
+		"foreign_scan_one.txt":  1,
+		"foreign_scan_none.txt": 0,
+	}
+	for scan, want := range tests {
+		set(scan, "foreign_preview.txt")
+		ad := AdapterStat{AdapterId: 0}
+		ad.getMegaRaidForeign(script)
+		if len(ad.ForeignConfigs) != want {
+			t.Errorf("%s: foreign configs = %+v, want %d", scan, ad.ForeignConfigs, want)
+			continue
+		}
+		for i, config := range ad.ForeignConfigs {
+			if config.Index != i || len(config.DiskGroups) != 1 {
+				t.Errorf("%s: foreign config %d = %+v", scan, i, config)
+			}
+		}
+	}
+}
+
+func TestPreviewForeign(t *testing.T) {
+	script, _, set := fakeForeignMegaCli(t)
+	d := &DiskStatus{megacliPath: script}
+	tests := []struct {
+		scan  string
+		index int
+		err   string
+	}{
+		{"foreign_scan_none.txt", 0, "no foreign configuration on adapter 0"},
+		{"foreign_scan_one.txt", 1, "foreign configuration 1 not found, adapter 0 has 1"},
+		{"foreign_scan_one.txt", 0, ""},
+		{"foreign_scan_two.txt", ForeignAll, ""},
+	}
+	for _, tt := range tests {
+		set(tt.scan, "foreign_preview.txt")
+		preview, err := d.PreviewForeign(0, tt.index)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: PreviewForeign(%d) = %+v, %v, want %q", tt.scan, tt.index, preview, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: PreviewForeign(%d): %v", tt.scan, tt.index, err)
+			continue
+		}
+		if len(preview.VirtualDrives) != 1 || len(preview.PhysicalDrives) != 2 || len(preview.Warnings) != 0 {
+			t.Errorf("%s: preview = %+v", tt.scan, preview)
+		}
+	}
+
+	set("foreign_scan_one.txt", "foreign_preview_degraded.txt")
+	preview, err := d.PreviewForeign(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "VD 0 (RAID1, 1.817 TB) would be imported Degraded"
+	if len(preview.Warnings) != 1 || preview.Warnings[0] != want {
+		t.Errorf("warnings = %q, want %q", preview.Warnings, want)
+	}
+}
+
+func TestForeignTokenChanges(t *testing.T) {
+	script, log, set := fakeForeignMegaCli(t)
+	d := &DiskStatus{megacliPath: script}
+	set("foreign_scan_one.txt", "foreign_preview.txt")
+	preview, err := d.PreviewForeign(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := d.PreviewForeign(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Token != preview.Token {
+		t.Errorf("token changed without a change of the configuration: %s, %s", preview.Token, again.Token)
+	}
+
+	// 预览之后一块盘被拔掉，外部配置变了
+	set("foreign_scan_one.txt", "foreign_preview_degraded.txt")
+	changed, err := d.PreviewForeign(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed.Token == preview.Token {
+		t.Fatalf("token %s did not change with the configuration", preview.Token)
+	}
+	if err := d.ImportForeign(0, 0, preview.Token); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("ImportForeign() with a stale token = %v", err)
+	}
+	if err := d.ClearForeign(0, 0, preview.Token); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("ClearForeign() with a stale token = %v", err)
+	}
+	if args := readArgsLog(t, log); args != "" {
+		t.Errorf("MegaCli ran with a stale token: %q", args)
+	}
+
+	out := new(bytes.Buffer)
+	d.EnableDryRun(out)
+	if err := d.ImportForeign(0, 0, changed.Token); err != nil {
+		t.Fatal(err)
+	}
+	set("foreign_scan_two.txt", "foreign_preview.txt")
+	all, err := d.PreviewForeign(0, ForeignAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ClearForeign(0, ForeignAll, all.Token); err != nil {
+		t.Fatal(err)
+	}
+	printed := script + " -CfgForeign -Import 0 -a0 -NoLog\n" + script + " -CfgForeign -Clear ALL -a0 -NoLog\n"
+	if out.String() != printed {
+		t.Errorf("dry-run printed\n%s\nwant\n%s", out.String(), printed)
+	}
+}
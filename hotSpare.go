@@ -0,0 +1,197 @@
+package diskutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Hot spare types of HotSpareStat.
+const (
+	HotSpareGlobal    string = "global"
+	HotSpareDedicated string = "dedicated"
+)
+
+// HotSpareStat is a struct to get the hot spare assignment of a PD.
+// Arrays are the disk groups a dedicated spare protects.
+type HotSpareStat struct {
+	Type              string `json:"type"`
+	Arrays            []int  `json:"arrays"`
+	Revertible        bool   `json:"revertible"`
+	EnclosureAffinity bool   `json:"enclosure_affinity"`
+}
+
+// HotSpareSpec is a struct to describe a hot spare for SetHotSpare().
+// A spare with Arrays is dedicated to them, otherwise it is global.
+type HotSpareSpec struct {
+	Arrays            []int
+	EnclosureAffinity bool
+	NonRevertible     bool
+}
+
+// SpareWarning is a struct to get a redundant VD which has no usable hot spare.
+type SpareWarning struct {
+	AdapterId    int    `json:"adapter_id"`
+	Backend      string `json:"backend"`
+	VirtualDrive int    `json:"virtual_drive"`
+	RaidLevel    string `json:"raid_level"`
+	Reason       string `json:"reason"`
+}
+
+// "Dedicated, is revertible, with enclosure affinity"
+func parseMegaCliHotSpareType(value string) *HotSpareStat {
+	spare := &HotSpareStat{Type: HotSpareGlobal, Arrays: []int{}}
+	lower := strings.ToLower(value)
+	if strings.HasPrefix(lower, "dedicated") {
+		spare.Type = HotSpareDedicated
+	}
+	spare.Revertible = strings.Contains(lower, "is revertible")
+	spare.EnclosureAffinity = strings.Contains(lower, "affinity") && !strings.Contains(lower, "without")
+	return spare
+}
+
+// "0, 1" 或 "0,1"
+func parseIntList(value string) []int {
+	result := make([]int, 0)
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if n, err := strconv.Atoi(field); err == nil {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, strconv.Itoa(v))
+	}
+	return strings.Join(parts, ",")
+}
+
+// SetHotSpare() is used to make the PD in the enclosure and slot of a MegaRaid
+// adapter a global hot spare, or a dedicated one when spec.Arrays is not empty.
+func (d *DiskStatus) SetHotSpare(adapter, enclosure, slot int, spec HotSpareSpec) error {
+	_, err := d.execMegaCli(setHotSpareArgs(adapter, enclosure, slot, spec))
+	return err
+}
+
+func setHotSpareArgs(adapter, enclosure, slot int, spec HotSpareSpec) string {
+	args := "-PDHSP -Set"
+	if len(spec.Arrays) > 0 {
+		args += " -Dedicated -Array" + joinInts(spec.Arrays)
+	}
+	if spec.EnclosureAffinity {
+		args += " -EnclAffinity"
+	}
+	if spec.NonRevertible {
+		args += " -nonRevertible"
+	}
+	return fmt.Sprintf("%s %s -a%d -NoLog", args, physDrv(enclosure, slot), adapter)
+}
+
+// RemoveHotSpare() is used to remove the hot spare in the enclosure and slot of a MegaRaid adapter.
+func (d *DiskStatus) RemoveHotSpare(adapter, enclosure, slot int) error {
+	_, err := d.execMegaCli(removeHotSpareArgs(adapter, enclosure, slot))
+	return err
+}
+
+func removeHotSpareArgs(adapter, enclosure, slot int) string {
+	return fmt.Sprintf("-PDHSP -Rmv %s -a%d -NoLog", physDrv(enclosure, slot), adapter)
+}
+
+// RAID0、linear、JBOD没有冗余，不需要热备
+func isRedundantRaid(raidLevel string) bool {
+	level := strings.TrimPrefix(raidLevel, "RAID")
+	return level != "" && level[0] >= '1' && level[0] <= '9'
+}
+
+// 热备是否可以替换VD中的任意一块盘：覆盖该阵列、容量不小于最小的成员盘、介质类型一致
+func spareUsable(spare *PhysicalDriveStat, diskGroup string, members []PhysicalDriveStat) bool {
+	if !strings.HasPrefix(spare.FirmwareState, "Hotspare") {
+		return false
+	}
+	if spare.HotSpare != nil && spare.HotSpare.Type == HotSpareDedicated {
+		covered := false
+		for _, array := range spare.HotSpare.Arrays {
+			if strconv.Itoa(array) == diskGroup {
+				covered = true
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	spareSize, spareSizeOk := rawSizeBytes(spare.RawSize)
+	for i := range members {
+		if size, ok := rawSizeBytes(members[i].RawSize); ok && spareSizeOk && spareSize < size {
+			return false
+		}
+		if members[i].PdMediaType != "" && spare.PdMediaType != members[i].PdMediaType {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckSparePolicy() is used to find the redundant VDs which have no usable
+// hot spare: no global or dedicated spare on the same adapter which is at least
+// as large as the members and has the same media type.
+func (d *DiskStatus) CheckSparePolicy() ([]SpareWarning, error) {
+	if err := d.Get(); err != nil {
+		return nil, err
+	}
+	warnings := make([]SpareWarning, 0)
+	for _, ad := range d.AdapterStats {
+		for i := range ad.VirtualDriveStats {
+			vd := &ad.VirtualDriveStats[i]
+			if !isRedundantRaid(vd.RaidLevel) {
+				continue
+			}
+			members := make([]PhysicalDriveStat, 0)
+			for j := range ad.PhysicalDriveStats {
+				if isVdMember(vd, &ad.PhysicalDriveStats[j]) {
+					members = append(members, ad.PhysicalDriveStats[j])
+				}
+			}
+			// 不知道成员盘时无法判断热备是否可用
+			if len(members) == 0 {
+				warnings = append(warnings, SpareWarning{
+					AdapterId:    ad.AdapterId,
+					Backend:      ad.Backend,
+					VirtualDrive: vd.VirtualDrive,
+					RaidLevel:    vd.RaidLevel,
+					Reason:       "the member drives of the VD are unknown",
+				})
+				continue
+			}
+
+			spares, usable := 0, false
+			for j := range ad.PhysicalDriveStats {
+				pd := &ad.PhysicalDriveStats[j]
+				if strings.HasPrefix(pd.FirmwareState, "Hotspare") {
+					spares++
+				}
+				if spareUsable(pd, vd.diskGroup, members) {
+					usable = true
+					break
+				}
+			}
+			if usable {
+				continue
+			}
+			reason := "no hot spare on the adapter"
+			if spares > 0 {
+				reason = fmt.Sprintf("none of the %d hot spares covers the VD with a matching size and media type", spares)
+			}
+			warnings = append(warnings, SpareWarning{
+				AdapterId:    ad.AdapterId,
+				Backend:      ad.Backend,
+				VirtualDrive: vd.VirtualDrive,
+				RaidLevel:    vd.RaidLevel,
+				Reason:       reason,
+			})
+		}
+	}
+	return warnings, nil
+}
@@ -0,0 +1,106 @@
+package diskutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMegaCliHotSpareType(t *testing.T) {
+	tests := []struct {
+		value string
+		want  HotSpareStat
+	}{
+		{"Global", HotSpareStat{Type: HotSpareGlobal, Arrays: []int{}}},
+		{"Dedicated, is revertible, with enclosure affinity", HotSpareStat{Type: HotSpareDedicated, Arrays: []int{}, Revertible: true, EnclosureAffinity: true}},
+		{"Global, is revertible, without enclosure affinity", HotSpareStat{Type: HotSpareGlobal, Arrays: []int{}, Revertible: true}},
+	}
+	for _, tt := range tests {
+		if spare := parseMegaCliHotSpareType(tt.value); !reflect.DeepEqual(*spare, tt.want) {
+			t.Errorf("parseMegaCliHotSpareType(%q) = %+v, want %+v", tt.value, *spare, tt.want)
+		}
+	}
+}
+
+func TestSetHotSpareArgs(t *testing.T) {
+	tests := []struct {
+		spec HotSpareSpec
+		want string
+	}{
+		{HotSpareSpec{}, "-PDHSP -Set -physdrv[32:6] -a0 -NoLog"},
+		{HotSpareSpec{Arrays: []int{0, 1}, EnclosureAffinity: true, NonRevertible: true},
+			"-PDHSP -Set -Dedicated -Array0,1 -EnclAffinity -nonRevertible -physdrv[32:6] -a0 -NoLog"},
+	}
+	for _, tt := range tests {
+		if args := setHotSpareArgs(0, 32, 6, tt.spec); args != tt.want {
+			t.Errorf("setHotSpareArgs(%+v) = %q, want %q", tt.spec, args, tt.want)
+		}
+	}
+}
+
+func TestCheckSparePolicy(t *testing.T) {
+	spare := func(slot int, rawSize string, spare *HotSpareStat) PhysicalDriveStat {
+		return PhysicalDriveStat{EnclosureDeviceId: 32, SlotNumber: slot, FirmwareState: "Hotspare, Spun Up",
+			RawSize: rawSize, PdMediaType: mediaTypeHdd, HotSpare: spare, OsPath: "Unknown"}
+	}
+	tests := []struct {
+		name   string
+		spares []PhysicalDriveStat
+		modify func(ad *AdapterStat)
+		want   map[int]string
+	}{
+		{
+			name: "no spare",
+			want: map[int]string{0: "no hot spare on the adapter", 129: "no hot spare on the adapter"},
+		},
+		{
+			name:   "global spare",
+			spares: []PhysicalDriveStat{spare(6, "1.819 TB [0xe8e088b0 Sectors]", &HotSpareStat{Type: HotSpareGlobal})},
+			want:   map[int]string{},
+		},
+		{
+			name:   "global spare too small",
+			spares: []PhysicalDriveStat{spare(6, "558.406 GB [0x45cc0000 Sectors]", &HotSpareStat{Type: HotSpareGlobal})},
+			want:   map[int]string{129: "none of the 1 hot spares covers the VD with a matching size and media type"},
+		},
+		{
+			// 专用热备按disk group覆盖：Array 1 是VD 129，不是VD 1
+			name:   "dedicated spare",
+			spares: []PhysicalDriveStat{spare(6, "1.819 TB [0xe8e088b0 Sectors]", &HotSpareStat{Type: HotSpareDedicated, Arrays: []int{1}})},
+			want:   map[int]string{0: "none of the 1 hot spares covers the VD with a matching size and media type"},
+		},
+		{
+			name:   "unknown members",
+			spares: []PhysicalDriveStat{spare(6, "1.819 TB [0xe8e088b0 Sectors]", &HotSpareStat{Type: HotSpareGlobal})},
+			modify: func(ad *AdapterStat) {
+				ad.VirtualDriveStats[1].diskGroup = ""
+			},
+			want: map[int]string{129: "the member drives of the VD are unknown"},
+		},
+	}
+	for _, tt := range tests {
+		ad := fixtureMegaCliAdapter(t)
+		for i := range ad.PhysicalDriveStats {
+			ad.PhysicalDriveStats[i].PdMediaType = mediaTypeHdd
+			ad.PhysicalDriveStats[i].RawSize = "1.819 TB [0xe8e088b0 Sectors]"
+		}
+		// VD 0的成员盘比较小
+		ad.PhysicalDriveStats[0].RawSize = "446.625 GB [0x37d40000 Sectors]"
+		ad.PhysicalDriveStats[1].RawSize = "446.625 GB [0x37d40000 Sectors]"
+		ad.PhysicalDriveStats = append(ad.PhysicalDriveStats, tt.spares...)
+		if tt.modify != nil {
+			tt.modify(&ad)
+		}
+		d := newFixtureDiskStatus(t, ad)
+		warnings, err := d.CheckSparePolicy()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[int]string)
+		for _, warning := range warnings {
+			got[warning.VirtualDrive] = warning.Reason
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: warnings = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package diskutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Layout is a struct to describe the desired RAID layout of a server, usually
+// loaded from a JSON file by LoadLayout(). An adapter listed in the layout is
+// described completely: its VDs and hot spares which are not in the layout are
+// deleted and removed. The adapters which are not listed are left alone.
+type Layout struct {
+	Adapters []AdapterLayout `json:"adapters"`
+}
+
+// AdapterLayout is a struct to describe the desired layout of a MegaRaid adapter.
+// Every VD must list its member drives, the Adapter of a VDSpec is ignored.
+type AdapterLayout struct {
+	Adapter       int               `json:"adapter"`
+	VirtualDrives []VDSpec          `json:"virtual_drives"`
+	HotSpares     []HotSpareLayout  `json:"hot_spares,omitempty"`
+	PatrolRead    *PatrolReadLayout `json:"patrol_read,omitempty"`
+}
+
+// HotSpareLayout is a struct to describe a hot spare in "E:S". Arrays are disk
+// group numbers as the controller reports them once the layout is applied.
+type HotSpareLayout struct {
+	Drive             string `json:"drive"`
+	Arrays            []int  `json:"arrays,omitempty"`
+	EnclosureAffinity bool   `json:"enclosure_affinity,omitempty"`
+	NonRevertible     bool   `json:"non_revertible,omitempty"`
+}
+
+// PatrolReadLayout is a struct to describe the patrol read of an adapter.
+// Mode is PatrolReadAuto, PatrolReadManual or PatrolReadDisabled, a zero
+// DelayHours keeps the current delay.
+type PatrolReadLayout struct {
+	Mode       string `json:"mode"`
+	DelayHours int    `json:"delay_hours,omitempty"`
+}
+
+// LayoutStep is one step of a LayoutPlan. Destructive steps delete a VD or
+// reuse the drives of a deleted one, ApplyLayout() skips them unless asked.
+type LayoutStep struct {
+	AdapterId   int    `json:"adapter_id"`
+	Description string `json:"description"`
+	Command     string `json:"command"`
+	Destructive bool   `json:"destructive"`
+	run         func() error
+}
+
+// LayoutPlan is a struct to get the steps which bring the adapters to a Layout.
+// An empty plan means the adapters already match it.
+type LayoutPlan struct {
+	Steps []LayoutStep `json:"steps"`
+}
+
+// LoadLayout() is used to read a Layout from a JSON file. Unknown keys are
+// refused, so a typo does not silently drop a part of the layout.
+func LoadLayout(path string) (*Layout, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	layout := new(Layout)
+	if err := decoder.Decode(layout); err != nil {
+		return nil, fmt.Errorf("layout %s illegal: %v", path, err)
+	}
+	return layout, nil
+}
+
+func pdKey(enclosure, slot int) string {
+	return fmt.Sprintf("%d:%d", enclosure, slot)
+}
+
+// 成员盘 "E:S" 排序后拼接，用于比较两个VD的成员是否相同
+func memberKeys(members []PhysicalDriveStat) string {
+	keys := make([]string, 0, len(members))
+	for _, pd := range members {
+		keys = append(keys, pdKey(pd.EnclosureDeviceId, pd.SlotNumber))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+func hotSpareMatches(pd *PhysicalDriveStat, spare HotSpareLayout) bool {
+	if !strings.HasPrefix(pd.FirmwareState, "Hotspare") || pd.HotSpare == nil {
+		return false
+	}
+	current := pd.HotSpare
+	wantType := HotSpareGlobal
+	if len(spare.Arrays) > 0 {
+		wantType = HotSpareDedicated
+	}
+	return current.Type == wantType && joinInts(current.Arrays) == joinInts(spare.Arrays) &&
+		current.EnclosureAffinity == spare.EnclosureAffinity && current.Revertible == !spare.NonRevertible
+}
+
+// PlanLayout() is used to diff the layout against the collected MegaRaid adapters
+// and get the MegaCli operations which apply it. A VD is kept when a VD of the
+// layout has the same members and RAID level, its cache policy is changed in
+// place; any other VD is deleted and the missing ones are created. Turning on
+// CachedBadBBU, on a new VD or in place, requires force.
+func (d *DiskStatus) PlanLayout(layout *Layout, force bool) (*LayoutPlan, error) {
+	if d.megacliPath == "" {
+		return nil, errors.New("megaCli backend required")
+	}
+	if err := d.Get(); err != nil {
+		return nil, err
+	}
+	plan := &LayoutPlan{Steps: make([]LayoutStep, 0)}
+	seen := make(map[int]bool)
+	for _, adLayout := range layout.Adapters {
+		if seen[adLayout.Adapter] {
+			return nil, fmt.Errorf("adapter %d listed twice", adLayout.Adapter)
+		}
+		seen[adLayout.Adapter] = true
+		var ad *AdapterStat
+		for i := range d.AdapterStats {
+			if d.AdapterStats[i].Backend == backendMegaCli && d.AdapterStats[i].AdapterId == adLayout.Adapter {
+				ad = &d.AdapterStats[i]
+			}
+		}
+		if ad == nil {
+			return nil, fmt.Errorf("no MegaRaid adapter %d found", adLayout.Adapter)
+		}
+		steps, err := d.planAdapterLayout(ad, adLayout, force)
+		if err != nil {
+			return nil, fmt.Errorf("adapter %d: %v", adLayout.Adapter, err)
+		}
+		plan.Steps = append(plan.Steps, steps...)
+	}
+	return plan, nil
+}
+
+// planAdapterLayout() 在PD状态的副本上模拟每一步，按 删除VD、移除热备、创建VD、设置热备、缓存策略、巡读 的顺序生成步骤
+func (d *DiskStatus) planAdapterLayout(ad *AdapterStat, layout AdapterLayout, force bool) ([]LayoutStep, error) {
+	adapter := ad.AdapterId
+	step := func(description, args string, destructive bool, run func() error) LayoutStep {
+		if run == nil {
+			run = func() error {
+				_, err := d.execMegaCli(args)
+				return err
+			}
+		}
+		return LayoutStep{
+			AdapterId:   adapter,
+			Description: description,
+			Command:     d.megacliPath + " " + args,
+			Destructive: destructive,
+			run:         run,
+		}
+	}
+
+	pds := make([]PhysicalDriveStat, len(ad.PhysicalDriveStats))
+	copy(pds, ad.PhysicalDriveStats)
+	byKey := make(map[string]*PhysicalDriveStat)
+	for i := range pds {
+		byKey[pdKey(pds[i].EnclosureDeviceId, pds[i].SlotNumber)] = &pds[i]
+	}
+
+	// 同一块盘不能出现在两个位置
+	used := make(map[string]string)
+	use := func(drive, owner string) (string, error) {
+		enclosure, slot, err := parseEnclosureSlot(drive)
+		if err != nil {
+			return "", err
+		}
+		key := pdKey(enclosure, slot)
+		if other, ok := used[key]; ok {
+			return "", fmt.Errorf("drive %s is in both %s and %s", drive, other, owner)
+		}
+		used[key] = owner
+		return key, nil
+	}
+	// 在副本上填默认值，不修改调用方的Layout
+	specs := make([]VDSpec, len(layout.VirtualDrives))
+	copy(specs, layout.VirtualDrives)
+	wantMembers := make([]string, len(specs))
+	for i := range specs {
+		spec := &specs[i]
+		spec.Adapter = adapter
+		if spec.Selector != nil || len(spec.Drives) == 0 {
+			return nil, fmt.Errorf("VD #%d of the layout must list its member drives", i)
+		}
+		// 这里只检查取值，CachedBadBBU在创建VD或修改策略时才检查force
+		if _, err := vdPolicyArgs(*spec, true); err != nil {
+			return nil, err
+		}
+		keys := make([]string, 0, len(spec.Drives))
+		for _, drive := range spec.Drives {
+			key, err := use(drive, fmt.Sprintf("VD #%d", i))
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		wantMembers[i] = strings.Join(keys, ",")
+	}
+	wantSpares := make(map[string]HotSpareLayout)
+	for _, spare := range layout.HotSpares {
+		key, err := use(spare.Drive, "the hot spares")
+		if err != nil {
+			return nil, err
+		}
+		wantSpares[key] = spare
+	}
+
+	var deletes, removes, creates, spares, props, patrol []LayoutStep
+	matched := make([]bool, len(specs))
+	freed := make(map[string]bool)
+	for i := range ad.VirtualDriveStats {
+		vd := ad.VirtualDriveStats[i]
+		// 成员盘未知时会被当作和Layout不同的VD删除
+		if vd.diskGroup == "" {
+			return nil, fmt.Errorf("VD %d: its member drives are unknown", vd.VirtualDrive)
+		}
+		members := make([]PhysicalDriveStat, 0)
+		for j := range ad.PhysicalDriveStats {
+			if isVdMember(&vd, &ad.PhysicalDriveStats[j]) {
+				members = append(members, ad.PhysicalDriveStats[j])
+			}
+		}
+		keys := memberKeys(members)
+		found := -1
+		for j, spec := range specs {
+			if !matched[j] && wantMembers[j] == keys && normalizeRaidLevel(spec.RaidLevel) == vd.RaidLevel {
+				found = j
+				break
+			}
+		}
+		if found >= 0 {
+			matched[found] = true
+			steps, err := vdPropSteps(step, adapter, &vd, specs[found], force)
+			if err != nil {
+				return nil, err
+			}
+			props = append(props, steps...)
+			continue
+		}
+
+		token := deleteVdToken(adapter, &vd, members)
+		virtualDrive := vd.VirtualDrive
+		deletes = append(deletes, step(fmt.Sprintf("delete VD %d (%s, %s, members %s)", vd.VirtualDrive, vd.RaidLevel, vd.Size, keys),
+			deleteVdArgs(adapter, vd.VirtualDrive), true, func() error {
+				return d.DeleteVD(adapter, virtualDrive, token)
+			}))
+		for _, pd := range members {
+			key := pdKey(pd.EnclosureDeviceId, pd.SlotNumber)
+			byKey[key].FirmwareState = "Unconfigured(good), Spun Up"
+			byKey[key].PdDiskGroup = ""
+			freed[key] = true
+		}
+	}
+
+	satisfied := make(map[string]bool)
+	for i := range pds {
+		pd := &pds[i]
+		if !strings.HasPrefix(pd.FirmwareState, "Hotspare") {
+			continue
+		}
+		key := pdKey(pd.EnclosureDeviceId, pd.SlotNumber)
+		if spare, ok := wantSpares[key]; ok && hotSpareMatches(pd, spare) {
+			satisfied[key] = true
+			continue
+		}
+		removes = append(removes, step(fmt.Sprintf("remove the hot spare %s", key),
+			removeHotSpareArgs(adapter, pd.EnclosureDeviceId, pd.SlotNumber), false, nil))
+		pd.FirmwareState = "Unconfigured(good), Spun Up"
+	}
+
+	for i, spec := range specs {
+		if matched[i] {
+			continue
+		}
+		args, err := createVdArgsFor(spec, pds, force)
+		if err != nil {
+			return nil, fmt.Errorf("VD #%d of the layout: %v", i, err)
+		}
+		destructive := false
+		for _, key := range strings.Split(wantMembers[i], ",") {
+			destructive = destructive || freed[key]
+			byKey[key].FirmwareState = "Online, Spun Up"
+		}
+		spec := spec
+		creates = append(creates, step(fmt.Sprintf("create a %s VD on %s", normalizeRaidLevel(spec.RaidLevel), wantMembers[i]),
+			args, destructive, func() error {
+				return d.CreateVD(spec, force)
+			}))
+	}
+
+	spareKeys := make([]string, 0, len(wantSpares))
+	for key := range wantSpares {
+		spareKeys = append(spareKeys, key)
+	}
+	sort.Strings(spareKeys)
+	for _, key := range spareKeys {
+		if satisfied[key] {
+			continue
+		}
+		spare := wantSpares[key]
+		pd, ok := byKey[key]
+		if !ok {
+			return nil, errors.New("no drive found in " + spare.Drive)
+		}
+		if !strings.HasPrefix(pd.FirmwareState, "Unconfigured(good)") {
+			return nil, fmt.Errorf("hot spare %s is %q, Unconfigured(good) required", spare.Drive, pd.FirmwareState)
+		}
+		spec := HotSpareSpec{Arrays: spare.Arrays, EnclosureAffinity: spare.EnclosureAffinity, NonRevertible: spare.NonRevertible}
+		kind := HotSpareGlobal
+		if len(spare.Arrays) > 0 {
+			kind = HotSpareDedicated
+		}
+		spares = append(spares, step(fmt.Sprintf("make %s a %s hot spare", key, kind),
+			setHotSpareArgs(adapter, pd.EnclosureDeviceId, pd.SlotNumber, spec), freed[key], nil))
+	}
+
+	if pr := layout.PatrolRead; pr != nil {
+		if ad.PatrolRead == nil || !strings.EqualFold(ad.PatrolRead.Mode, pr.Mode) {
+			args, err := patrolReadModeArgs(adapter, pr.Mode)
+			if err != nil {
+				return nil, err
+			}
+			patrol = append(patrol, step("set the patrol read mode to "+pr.Mode, args, false, nil))
+		}
+		if pr.DelayHours > 0 && (ad.PatrolRead == nil || ad.PatrolRead.ExecutionDelayHours != pr.DelayHours) {
+			delay := time.Duration(pr.DelayHours) * time.Hour
+			patrol = append(patrol, step(fmt.Sprintf("set the patrol read delay to %d hours", pr.DelayHours),
+				fmt.Sprintf("-AdpPR -SetDelay %d -a%d -NoLog", pr.DelayHours, adapter), false, func() error {
+					return d.SetPatrolReadSchedule(adapter, delay, time.Time{})
+				}))
+		}
+	}
+
+	steps := make([]LayoutStep, 0)
+	for _, phase := range [][]LayoutStep{deletes, removes, creates, spares, props, patrol} {
+		steps = append(steps, phase...)
+	}
+	return steps, nil
+}
+
+// vdPropSteps() 比较已有VD的缓存策略，不一致的项由 cachePolicyProps() 生成 -LDSetProp，
+// 和 SetVDCachePolicy() 一样打开CachedBadBBU时要求force
+func vdPropSteps(step func(string, string, bool, func() error) LayoutStep, adapter int, vd *VirtualDriveStat, spec VDSpec, force bool) ([]LayoutStep, error) {
+	steps := make([]LayoutStep, 0)
+	current := vd.CachePolicy
+	if current == nil {
+		return steps, nil
+	}
+	var change CachePolicySpec
+	changed := false
+	for _, policy := range []struct {
+		want, current string
+		change        *string
+	}{
+		{spec.WritePolicy, current.WritePolicy, &change.WritePolicy},
+		{spec.ReadPolicy, current.ReadPolicy, &change.ReadPolicy},
+		{spec.IOPolicy, current.IOPolicy, &change.IOPolicy},
+	} {
+		if policy.want == "" || strings.EqualFold(policy.want, policy.current) {
+			continue
+		}
+		*policy.change = policy.want
+		changed = true
+	}
+	// "Cached Write if Bad BBU" 跟随写策略，只在指定了写策略时比较
+	if spec.WritePolicy != "" && spec.CachedBadBBU != current.CachedBadBBU {
+		cachedBadBBU := spec.CachedBadBBU
+		change.CachedBadBBU = &cachedBadBBU
+		changed = true
+	}
+	if !changed {
+		return steps, nil
+	}
+	props, err := cachePolicyProps(change, force)
+	if err != nil {
+		return nil, fmt.Errorf("VD %d: %v", vd.VirtualDrive, err)
+	}
+	for _, prop := range props {
+		steps = append(steps, step(fmt.Sprintf("set %s on VD %d", prop, vd.VirtualDrive),
+			ldSetPropArgs(adapter, vd.VirtualDrive, prop), false, nil))
+	}
+	return steps, nil
+}
+
+// ApplyLayout() is used to run the steps of a LayoutPlan in order. Destructive
+// steps are skipped unless destructive is true. progress is called before every
+// step, with skipped set for the skipped ones, and can be nil. It stops at the
+// first failed step.
+func (d *DiskStatus) ApplyLayout(plan *LayoutPlan, destructive bool, progress func(step LayoutStep, skipped bool)) error {
+	for i, step := range plan.Steps {
+		skipped := step.Destructive && !destructive
+		if progress != nil {
+			progress(step, skipped)
+		}
+		if skipped {
+			continue
+		}
+		// dry-run时步骤之间的状态不会变化，只打印计划好的命令
+		if d.dryRun != nil {
+			if _, err := fmt.Fprintln(d.dryRun, step.Command); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := step.run(); err != nil {
+			return fmt.Errorf("step %d (%s) failed: %w", i+1, step.Description, err)
+		}
+	}
+	return nil
+}
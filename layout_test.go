@@ -0,0 +1,115 @@
+package diskutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanAdapterLayoutKeepsLayout(t *testing.T) {
+	d := &DiskStatus{megacliPath: "MegaCli64"}
+	ad := &AdapterStat{
+		AdapterId: 3,
+		PhysicalDriveStats: []PhysicalDriveStat{
+			{EnclosureDeviceId: 32, SlotNumber: 0, FirmwareState: "Unconfigured(good), Spun Up", PdType: "SAS", PdMediaType: mediaTypeHdd},
+			{EnclosureDeviceId: 32, SlotNumber: 1, FirmwareState: "Unconfigured(good), Spun Up", PdType: "SAS", PdMediaType: mediaTypeHdd},
+		},
+	}
+	layout := AdapterLayout{
+		Adapter:       3,
+		VirtualDrives: []VDSpec{{RaidLevel: "RAID1", Drives: []string{"32:0", "32:1"}}},
+	}
+	before := make([]VDSpec, len(layout.VirtualDrives))
+	copy(before, layout.VirtualDrives)
+
+	steps, err := d.planAdapterLayout(ad, layout, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 1 || steps[0].Command != "MegaCli64 -CfgLdAdd -r1[32:0,32:1] -a3 -NoLog" {
+		t.Errorf("steps = %+v", steps)
+	}
+	if !reflect.DeepEqual(layout.VirtualDrives, before) {
+		t.Errorf("layout changed to %+v", layout.VirtualDrives)
+	}
+}
+
+func TestPlanAdapterLayoutCachedBadBBU(t *testing.T) {
+	d := &DiskStatus{megacliPath: "MegaCli64"}
+	ad := &AdapterStat{
+		AdapterId: 0,
+		VirtualDriveStats: []VirtualDriveStat{
+			{VirtualDrive: 0, RaidLevel: "RAID1", diskGroup: "0", CachePolicy: &CachePolicyStat{WritePolicy: "WB", ReadPolicy: "RA", IOPolicy: "Direct"}},
+		},
+		PhysicalDriveStats: []PhysicalDriveStat{
+			{EnclosureDeviceId: 32, SlotNumber: 0, FirmwareState: "Online, Spun Up", PdDiskGroup: "0", PdType: "SAS", PdMediaType: mediaTypeHdd},
+			{EnclosureDeviceId: 32, SlotNumber: 1, FirmwareState: "Online, Spun Up", PdDiskGroup: "0", PdType: "SAS", PdMediaType: mediaTypeHdd},
+		},
+	}
+	layout := AdapterLayout{
+		VirtualDrives: []VDSpec{{RaidLevel: "RAID1", Drives: []string{"32:0", "32:1"}, WritePolicy: "WB", CachedBadBBU: true}},
+	}
+
+	if _, err := d.planAdapterLayout(ad, layout, false); err == nil {
+		t.Error("CachedBadBBU turned on without force")
+	}
+	steps, err := d.planAdapterLayout(ad, layout, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 1 || steps[0].Command != "MegaCli64 -LDSetProp CachedBadBBU -L0 -a0 -NoLog" {
+		t.Errorf("steps = %+v", steps)
+	}
+
+	// 已经打开的不需要force
+	ad.VirtualDriveStats[0].CachePolicy.CachedBadBBU = true
+	if steps, err := d.planAdapterLayout(ad, layout, false); err != nil || len(steps) != 0 {
+		t.Errorf("steps = %+v, %v, want none", steps, err)
+	}
+}
+
+func TestPlanAdapterLayoutDiskGroupNotVdNumber(t *testing.T) {
+	d := &DiskStatus{megacliPath: "MegaCli64"}
+	// 删除重建过VD之后，VD 1 在 disk group 0 上，VD 0 在 disk group 1 上
+	ad := &AdapterStat{
+		AdapterId: 0,
+		VirtualDriveStats: []VirtualDriveStat{
+			{VirtualDrive: 0, RaidLevel: "RAID1", Size: "1.818 TB", diskGroup: "1"},
+			{VirtualDrive: 1, RaidLevel: "RAID1", Size: "446.625 GB", diskGroup: "0"},
+		},
+		PhysicalDriveStats: []PhysicalDriveStat{
+			{EnclosureDeviceId: 32, SlotNumber: 0, FirmwareState: "Online, Spun Up", PdDiskGroup: "0", PdType: "SAS", PdMediaType: mediaTypeSsd},
+			{EnclosureDeviceId: 32, SlotNumber: 1, FirmwareState: "Online, Spun Up", PdDiskGroup: "0", PdType: "SAS", PdMediaType: mediaTypeSsd},
+			{EnclosureDeviceId: 32, SlotNumber: 2, FirmwareState: "Online, Spun Up", PdDiskGroup: "1", PdType: "SAS", PdMediaType: mediaTypeHdd},
+			{EnclosureDeviceId: 32, SlotNumber: 3, FirmwareState: "Online, Spun Up", PdDiskGroup: "1", PdType: "SAS", PdMediaType: mediaTypeHdd},
+		},
+	}
+	layout := AdapterLayout{
+		VirtualDrives: []VDSpec{
+			{RaidLevel: "RAID1", Drives: []string{"32:0", "32:1"}},
+			{RaidLevel: "RAID1", Drives: []string{"32:2", "32:3"}},
+		},
+	}
+	steps, err := d.planAdapterLayout(ad, layout, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 0 {
+		t.Errorf("steps = %+v, want none", steps)
+	}
+
+	// 把 32:2/32:3 换成RAID0，只删除 disk group 1 上的VD 0
+	layout.VirtualDrives[1].RaidLevel = "RAID0"
+	steps, err = d.planAdapterLayout(ad, layout, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 2 || steps[0].Command != "MegaCli64 -CfgLdDel -L0 -a0 -NoLog" || !steps[0].Destructive ||
+		steps[1].Command != "MegaCli64 -CfgLdAdd -r0[32:2,32:3] -a0 -NoLog" {
+		t.Errorf("steps = %+v", steps)
+	}
+
+	ad.VirtualDriveStats[1].diskGroup = ""
+	if _, err := d.planAdapterLayout(ad, layout, false); err == nil {
+		t.Error("VD without known members was planned")
+	}
+}
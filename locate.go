@@ -0,0 +1,176 @@
+package diskutil
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocateResult is a struct to get the drives under a file path, mount point or device node.
+type LocateResult struct {
+	Path       string `json:"path"`
+	MountPoint string `json:"mount_point,omitempty"`
+	// Stack is the kernel block devices from the top (dm/LVM/md/partition) down to the disks.
+	Stack  []string       `json:"stack"`
+	Drives []LocatedDrive `json:"drives"`
+}
+
+// LocatedDrive is a struct to get one VD or JBOD under a LocateResult with its member PDs.
+// VirtualDrive is nil for a JBOD.
+type LocatedDrive struct {
+	AdapterId      int                 `json:"adapter_id"`
+	Backend        string              `json:"backend"`
+	VirtualDrive   *VirtualDriveStat   `json:"virtual_drive,omitempty"`
+	PhysicalDrives []PhysicalDriveStat `json:"physical_drives"`
+}
+
+// String() is used to get the print string.
+func (l *LocateResult) String() string {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+// Locate() is used to find the VDs and PDs which hold a file path, mount point or
+// device node, e.g. "which slot holds /var/lib/mysql". The path is resolved through
+// the partition, device-mapper, LVM and md layers down to the disks, and the disks
+// are joined with the OsPath of the drives.
+func (d *DiskStatus) Locate(pathOrMount string) (*LocateResult, error) {
+	result := &LocateResult{Path: pathOrMount}
+	name, mountPoint, err := d.resolver.topBlock(pathOrMount)
+	if err != nil {
+		return nil, err
+	}
+	result.MountPoint = mountPoint
+	result.Stack = d.resolver.lowerBlocks(name)
+
+	if err := d.Get(); err != nil {
+		return nil, err
+	}
+	inStack := make(map[string]bool)
+	for _, block := range result.Stack {
+		inStack[block] = true
+	}
+
+	result.Drives = make([]LocatedDrive, 0)
+	for i := range d.AdapterStats {
+		ad := &d.AdapterStats[i]
+		seenPds := make(map[int]bool)
+		for j := range ad.VirtualDriveStats {
+			vd := ad.VirtualDriveStats[j]
+			if !strings.HasPrefix(vd.OsPath, "/") || !inStack[d.resolver.kernelName(vd.OsPath)] {
+				continue
+			}
+			located := LocatedDrive{
+				AdapterId:      ad.AdapterId,
+				Backend:        ad.Backend,
+				VirtualDrive:   &vd,
+				PhysicalDrives: make([]PhysicalDriveStat, 0),
+			}
+			for k, pd := range ad.PhysicalDriveStats {
+				if isVdMember(&vd, &pd) {
+					located.PhysicalDrives = append(located.PhysicalDrives, pd)
+					seenPds[k] = true
+				}
+			}
+			result.Drives = append(result.Drives, located)
+		}
+		for j, pd := range ad.PhysicalDriveStats {
+			if seenPds[j] || !strings.HasPrefix(pd.OsPath, "/") || !inStack[d.resolver.kernelName(pd.OsPath)] {
+				continue
+			}
+			result.Drives = append(result.Drives, LocatedDrive{
+				AdapterId:      ad.AdapterId,
+				Backend:        ad.Backend,
+				PhysicalDrives: []PhysicalDriveStat{pd},
+			})
+		}
+	}
+	if len(result.Drives) == 0 {
+		return result, errors.New("no drive found under " + pathOrMount)
+	}
+	return result, nil
+}
+
+// isVdMember() PD的DiskGroup和VD所在的disk group相同时是VD的成员。
+// disk group由后端采集VD时记录，不能按VD序号推断：删除重建过VD后两者可能不同
+func isVdMember(vd *VirtualDriveStat, pd *PhysicalDriveStat) bool {
+	return vd.diskGroup != "" && pd.PdDiskGroup == vd.diskGroup
+}
+
+// topBlock() 获取路径所在的块设备名，设备节点直接解析，其他路径按最长前缀匹配挂载点
+func (r *osDeviceResolver) topBlock(pathOrMount string) (string, string, error) {
+	if strings.HasPrefix(pathOrMount, "/dev/") {
+		name := r.sourceName(pathOrMount)
+		if !fileExist(filepath.Join(r.sysRoot, "class", "block", name)) {
+			return "", "", errors.New("not a block device: " + pathOrMount)
+		}
+		return name, "", nil
+	}
+
+	path, err := filepath.Abs(pathOrMount)
+	if err != nil {
+		return "", "", err
+	}
+	if realPath, err := filepath.EvalSymlinks(path); err == nil {
+		path = realPath
+	}
+	var best *mountEntry
+	mounts := r.mountEntries()
+	for i := range mounts {
+		mp := mounts[i].mountPoint
+		if path != mp && mp != "/" && !strings.HasPrefix(path, mp+"/") {
+			continue
+		}
+		// 同一挂载点被多次挂载时，后挂载的生效
+		if best == nil || len(mp) >= len(best.mountPoint) {
+			best = &mounts[i]
+		}
+	}
+	if best == nil {
+		return "", "", errors.New("no mount point found for " + pathOrMount)
+	}
+
+	// btrfs等文件系统的major:minor是匿名设备，改用挂载源
+	if realDir, err := filepath.EvalSymlinks(filepath.Join(r.sysRoot, "dev", "block", best.majorMinor)); err == nil {
+		return filepath.Base(realDir), best.mountPoint, nil
+	}
+	if name := r.sourceName(best.source); name != "" && fileExist(filepath.Join(r.sysRoot, "class", "block", name)) {
+		return name, best.mountPoint, nil
+	}
+	return "", best.mountPoint, errors.New("mount point " + best.mountPoint + " is not on a block device")
+}
+
+// lowerBlocks() 从块设备向下遍历分区的父设备和 slaves，返回从上到下的所有块设备
+func (r *osDeviceResolver) lowerBlocks(name string) []string {
+	stack := make([]string, 0)
+	seen := make(map[string]bool)
+	queue := []string{name}
+	for len(queue) > 0 && len(stack) < maxHolderDepth*maxHolderDepth {
+		block := queue[0]
+		queue = queue[1:]
+		if seen[block] {
+			continue
+		}
+		seen[block] = true
+		stack = append(stack, block)
+
+		blockDir := filepath.Join(r.sysRoot, "class", "block", block)
+		if fileExist(filepath.Join(blockDir, "partition")) {
+			if realDir, err := filepath.EvalSymlinks(blockDir); err == nil {
+				queue = append(queue, filepath.Base(filepath.Dir(realDir)))
+			}
+			continue
+		}
+		if entries, err := os.ReadDir(filepath.Join(blockDir, "slaves")); err == nil {
+			for _, entry := range entries {
+				queue = append(queue, entry.Name())
+			}
+		}
+	}
+	return stack
+}
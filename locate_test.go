@@ -0,0 +1,172 @@
+package diskutil
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fixedBackend 每次采集都返回同样的AdapterStats，用于测试Get()之后的逻辑
+type fixedBackend struct {
+	ads []AdapterStat
+}
+
+func (f *fixedBackend) Name() string { return "fixed" }
+
+func (f *fixedBackend) Get() ([]AdapterStat, error) {
+	ads := make([]AdapterStat, len(f.ads))
+	for i, ad := range f.ads {
+		ad.VirtualDriveStats = append([]VirtualDriveStat{}, ad.VirtualDriveStats...)
+		ad.PhysicalDriveStats = append([]PhysicalDriveStat{}, ad.PhysicalDriveStats...)
+		ads[i] = ad
+	}
+	return ads, nil
+}
+
+func (f *fixedBackend) GetVirtualDrive() ([]AdapterStat, error) { return f.Get() }
+
+func (f *fixedBackend) GetPhysicalDrive() ([]AdapterStat, error) { return f.Get() }
+
+// fixtureRoot() OsPath需要是绝对路径，所以fixture也用绝对路径
+func fixtureRoot(t *testing.T) string {
+	root, err := filepath.Abs(filepath.Join("testdata", "sysfs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+// newFixtureDiskStatus() 使用 testdata/sysfs 中的sysfs、/dev、mountinfo和udev数据库
+func newFixtureDiskStatus(t *testing.T, ads ...AdapterStat) *DiskStatus {
+	d, err := NewDiskStatusWithBackends(&fixedBackend{ads: ads})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := fixtureRoot(t)
+	d.SetSysfsRoot(filepath.Join(root, "sys"), filepath.Join(root, "dev"))
+	d.SetProcRoot(filepath.Join(root, "proc"), filepath.Join(root, "run", "udev"))
+	return d
+}
+
+// VD 129的disk group是1，和VD序号对不上
+func fixtureMegaCliAdapter(t *testing.T) AdapterStat {
+	devRoot := filepath.Join(fixtureRoot(t), "dev")
+	return AdapterStat{
+		AdapterId: 0,
+		Backend:   backendMegaCli,
+		VirtualDriveStats: []VirtualDriveStat{
+			{VirtualDrive: 0, State: "Optimal", RaidLevel: "RAID1", OsPath: filepath.Join(devRoot, "sda"), diskGroup: "0"},
+			{VirtualDrive: 129, State: "Optimal", RaidLevel: "RAID1", OsPath: filepath.Join(devRoot, "sdb"), diskGroup: "1"},
+		},
+		PhysicalDriveStats: []PhysicalDriveStat{
+			{EnclosureDeviceId: 32, SlotNumber: 0, FirmwareState: "Online, Spun Up", PdDiskGroup: "0", PdSpan: "0", PdArm: "0", OsPath: "Unknown"},
+			{EnclosureDeviceId: 32, SlotNumber: 1, FirmwareState: "Online, Spun Up", PdDiskGroup: "0", PdSpan: "0", PdArm: "1", OsPath: "Unknown"},
+			{EnclosureDeviceId: 32, SlotNumber: 2, FirmwareState: "Online, Spun Up", PdDiskGroup: "1", PdSpan: "0", PdArm: "0", OsPath: "Unknown"},
+			{EnclosureDeviceId: 32, SlotNumber: 3, FirmwareState: "Online, Spun Up", PdDiskGroup: "1", PdSpan: "0", PdArm: "1", OsPath: "Unknown"},
+			{EnclosureDeviceId: 32, SlotNumber: 20, DeviceId: 20, FirmwareState: "JBOD", OsPath: filepath.Join(devRoot, "sdc")},
+		},
+	}
+}
+
+func TestParseMegaRaidVdDiskGroups(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "megacli", "ldpdinfo.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]string{1: "0", 2: "1"}
+	if diskGroups := parseMegaRaidVdDiskGroups(string(data)); !reflect.DeepEqual(diskGroups, want) {
+		t.Errorf("disk groups = %v, want %v", diskGroups, want)
+	}
+	if diskGroups := parseMegaRaidVdDiskGroups(""); len(diskGroups) != 0 {
+		t.Errorf("disk groups of empty output = %v", diskGroups)
+	}
+}
+
+func TestTopBlock(t *testing.T) {
+	d := newFixtureDiskStatus(t)
+	tests := []struct {
+		path       string
+		name       string
+		mountPoint string
+		err        bool
+	}{
+		{"/var/lib/mysql/ibdata1", "dm-2", "/var/lib/mysql", false},
+		{"/var/lib/mysql", "dm-2", "/var/lib/mysql", false},
+		{"/var/lib/mysqlx", "dm-0", "/", false},
+		{"/boot/grub2/grub.cfg", "sda1", "/boot", false},
+		{"/data/disk 1/chunk", "sdc", "/data/disk 1", false},
+		{"/dev/sdb", "sdb", "", false},
+		{"/dev/mapper/vg1-mysql", "dm-2", "", false},
+		{"/dev/sdz", "", "", true},
+	}
+	for _, tt := range tests {
+		name, mountPoint, err := d.resolver.topBlock(tt.path)
+		if name != tt.name || mountPoint != tt.mountPoint || (err != nil) != tt.err {
+			t.Errorf("topBlock(%q) = %q, %q, %v, want %q, %q, error %v", tt.path, name, mountPoint, err, tt.name, tt.mountPoint, tt.err)
+		}
+	}
+}
+
+func TestLowerBlocks(t *testing.T) {
+	d := newFixtureDiskStatus(t)
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"dm-2", []string{"dm-2", "sdb"}},
+		{"dm-0", []string{"dm-0", "sda2", "sda"}},
+		{"sda1", []string{"sda1", "sda"}},
+		{"dm-1", []string{"dm-1", "sde", "sdf"}},
+		{"sdc", []string{"sdc"}},
+	}
+	for _, tt := range tests {
+		if stack := d.resolver.lowerBlocks(tt.name); !reflect.DeepEqual(stack, tt.want) {
+			t.Errorf("lowerBlocks(%q) = %v, want %v", tt.name, stack, tt.want)
+		}
+	}
+}
+
+func TestLocate(t *testing.T) {
+	d := newFixtureDiskStatus(t, fixtureMegaCliAdapter(t))
+	tests := []struct {
+		path   string
+		vd     int
+		slots  []int
+		hasErr bool
+	}{
+		// VD 129在disk group 1上，成员盘按disk group而不是VD序号匹配
+		{"/var/lib/mysql/ibdata1", 129, []int{2, 3}, false},
+		{"/etc/hosts", 0, []int{0, 1}, false},
+		{"/dev/sda1", 0, []int{0, 1}, false},
+		{"/data/disk 1/chunk", -1, []int{20}, false},
+		{"/dev/sdd", 0, nil, true},
+	}
+	for _, tt := range tests {
+		result, err := d.Locate(tt.path)
+		if tt.hasErr {
+			if err == nil {
+				t.Errorf("Locate(%q) = %v, want error", tt.path, result)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Locate(%q): %v", tt.path, err)
+		}
+		if len(result.Drives) != 1 {
+			t.Fatalf("Locate(%q) drives = %+v", tt.path, result.Drives)
+		}
+		drive := result.Drives[0]
+		vd := -1
+		if drive.VirtualDrive != nil {
+			vd = drive.VirtualDrive.VirtualDrive
+		}
+		slots := make([]int, 0)
+		for _, pd := range drive.PhysicalDrives {
+			slots = append(slots, pd.SlotNumber)
+		}
+		if vd != tt.vd || !reflect.DeepEqual(slots, tt.slots) {
+			t.Errorf("Locate(%q) = VD %d slots %v, want VD %d slots %v", tt.path, vd, slots, tt.vd, tt.slots)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package diskutil
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultProcRoot string = "/proc"
+	defaultSysRoot  string = "/sys"
+)
+
+var mdStatArrayRegex = regexp.MustCompile(`^(md\d+)\s*:\s*(\S+)`)
+
+// MdArrayStat is a struct to get the Linux software RAID detail of a VirtualDriveStat.
+type MdArrayStat struct {
+	ArrayState   string   `json:"array_state"`
+	Level        string   `json:"level"`
+	RaidDisks    int      `json:"raid_disks"`
+	Degraded     int      `json:"degraded"`
+	SyncAction   string   `json:"sync_action"`
+	SyncProgress float64  `json:"sync_progress"`
+	Members      []string `json:"members"`
+}
+
+// MdBackend is a Backend which reads /proc/mdstat and /sys/block/md*/md to get
+// the stat of Linux software RAID. Every md array is a VirtualDriveStat and
+// every member device is a PhysicalDriveStat of one AdapterStat.
+type MdBackend struct {
+	procRoot string
+	sysRoot  string
+}
+
+// NewMdBackend() use the procRoot and sysRoot to build a MdBackend.
+// Empty roots mean /proc and /sys, other roots are useful for tests.
+func NewMdBackend(procRoot, sysRoot string) *MdBackend {
+	if procRoot == "" {
+		procRoot = defaultProcRoot
+	}
+	if sysRoot == "" {
+		sysRoot = defaultSysRoot
+	}
+	return &MdBackend{
+		procRoot: filepath.Clean(procRoot),
+		sysRoot:  filepath.Clean(sysRoot),
+	}
+}
+
+// SetSysfsRoot() is used to change the sysfs root of the backend, the
+// devRoot is not used because md devices are always named /dev/mdN.
+func (m *MdBackend) SetSysfsRoot(sysRoot, devRoot string) {
+	m.sysRoot = newOsDeviceResolver(sysRoot, devRoot).sysRoot
+}
+
+// Name() is used to get the name of the backend.
+func (m *MdBackend) Name() string {
+	return backendMd
+}
+
+// HasArray() is used to check if there is any md array on the server.
+func (m *MdBackend) HasArray() bool {
+	arrays, err := m.listArrays()
+	return err == nil && len(arrays) > 0
+}
+
+// Get() is used to get all the AdapterStats of the backend.
+func (m *MdBackend) Get() ([]AdapterStat, error) {
+	return m.get(true, true)
+}
+
+// GetVirtualDrive() is used to get the AdapterStats with VirtualDriveStats only.
+func (m *MdBackend) GetVirtualDrive() ([]AdapterStat, error) {
+	return m.get(true, false)
+}
+
+// GetPhysicalDrive() is used to get the AdapterStats with PhysicalDriveStats only.
+func (m *MdBackend) GetPhysicalDrive() ([]AdapterStat, error) {
+	return m.get(false, true)
+}
+
+func (m *MdBackend) get(withVd, withPd bool) ([]AdapterStat, error) {
+	arrays, err := m.listArrays()
+	if err != nil {
+		return nil, err
+	}
+
+	ad := AdapterStat{
+		AdapterId: 0,
+		Backend:   backendMd,
+	}
+	vds := make([]VirtualDriveStat, 0)
+	pds := make([]PhysicalDriveStat, 0)
+	for _, name := range arrays {
+		vd, members, err := m.parseArray(name)
+		if err != nil {
+			return nil, err
+		}
+		for i := range members {
+			members[i].DeviceId = len(pds) + i
+		}
+		vds = append(vds, vd)
+		pds = append(pds, members...)
+	}
+	if withVd {
+		ad.VirtualDriveStats = vds
+	}
+	if withPd {
+		ad.PhysicalDriveStats = pds
+	}
+	return []AdapterStat{ad}, nil
+}
+
+// 从/proc/mdstat获取所有md阵列的名字
+func (m *MdBackend) listArrays() ([]string, error) {
+	f, err := os.Open(filepath.Join(m.procRoot, "mdstat"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	arrays := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		matches := mdStatArrayRegex.FindStringSubmatch(scanner.Text())
+		if matches != nil {
+			arrays = append(arrays, matches[1])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(arrays, func(i, j int) bool {
+		return mdNumber(arrays[i]) < mdNumber(arrays[j])
+	})
+	return arrays, nil
+}
+
+func mdNumber(name string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(name, "md"))
+	return n
+}
+
+func (m *MdBackend) parseArray(name string) (VirtualDriveStat, []PhysicalDriveStat, error) {
+	blockDir := filepath.Join(m.sysRoot, "block", name)
+	mdDir := filepath.Join(blockDir, "md")
+	if !fileExist(mdDir) {
+		return VirtualDriveStat{}, nil, errors.New("md sysfs not exist: " + mdDir)
+	}
+
+	stat := &MdArrayStat{
+		ArrayState: readSysfs(mdDir, "array_state"),
+		Level:      readSysfs(mdDir, "level"),
+		RaidDisks:  readSysfsInt(mdDir, "raid_disks"),
+		Degraded:   readSysfsInt(mdDir, "degraded"),
+		SyncAction: readSysfs(mdDir, "sync_action"),
+		Members:    make([]string, 0),
+	}
+	// sync_completed: "12345 / 67890" 或 "none"
+	completed := strings.Split(readSysfs(mdDir, "sync_completed"), "/")
+	if len(completed) == 2 {
+		done, err1 := strconv.ParseFloat(strings.TrimSpace(completed[0]), 64)
+		total, err2 := strconv.ParseFloat(strings.TrimSpace(completed[1]), 64)
+		if err1 == nil && err2 == nil && total > 0 {
+			stat.SyncProgress = float64(int(done/total*1000)) / 10
+		}
+	}
+
+	vd := VirtualDriveStat{
+		VirtualDrive:   mdNumber(name),
+		Name:           name,
+		Size:           formatSize(uint64(readSysfsInt(blockDir, "size")) * 512),
+		State:          mdArrayState(stat),
+		RaidLevel:      normalizeRaidLevel(stat.Level),
+		NumberOfDrives: stat.RaidDisks,
+		Encryptiontype: "None",
+		OsPath:         "/dev/" + name,
+		MdStat:         stat,
+		diskGroup:      name,
+	}
+
+	entries, err := os.ReadDir(mdDir)
+	if err != nil {
+		return vd, nil, err
+	}
+	pds := make([]PhysicalDriveStat, 0)
+	usedSlots := make(map[int]bool)
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "dev-") {
+			continue
+		}
+		devDir := filepath.Join(mdDir, entry.Name())
+		dev := strings.TrimPrefix(entry.Name(), "dev-")
+		stat.Members = append(stat.Members, dev)
+
+		pd := m.parseMember(dev, readSysfs(devDir, "state"))
+		pd.PdDiskGroup = name
+		// faulty和spare的slot为 "none"，不能和slot 0混在一起
+		pd.SlotNumber = -1
+		if slot, err := strconv.Atoi(readSysfs(devDir, "slot")); err == nil {
+			pd.SlotNumber = slot
+			pd.PdArm = strconv.Itoa(slot)
+			usedSlots[slot] = true
+			// 已分配slot的spare正在被recover
+			if strings.HasPrefix(pd.FirmwareState, "Hotspare") {
+				pd.FirmwareState = "Rebuild"
+			}
+		}
+		pds = append(pds, pd)
+	}
+	// 缺失的成员盘在sysfs中没有目录，按空闲slot补一条Missing
+	for slot := 0; slot < stat.RaidDisks && stat.Degraded > 0; slot++ {
+		if usedSlots[slot] {
+			continue
+		}
+		pds = append(pds, PhysicalDriveStat{
+			EnclosureDeviceId: 999,
+			SlotNumber:        slot,
+			PdDiskGroup:       name,
+			PdArm:             strconv.Itoa(slot),
+			FirmwareState:     "Missing",
+			OsPath:            "Unknown",
+		})
+	}
+	sort.SliceStable(pds, func(i, j int) bool {
+		return pds[i].SlotNumber < pds[j].SlotNumber
+	})
+	return vd, pds, nil
+}
+
+func (m *MdBackend) parseMember(dev, state string) PhysicalDriveStat {
+	pd := PhysicalDriveStat{
+		EnclosureDeviceId: 999,
+		FirmwareState:     mdMemberState(state),
+		OsPath:            "/dev/" + dev,
+	}
+
+	devDir := filepath.Join(m.sysRoot, "class", "block", dev)
+	pd.RawSize = formatSize(uint64(readSysfsInt(devDir, "size")) * 512)
+
+	// 分区成员的型号、介质类型在父磁盘上
+	diskDir := devDir
+	if realDir, err := filepath.EvalSymlinks(devDir); err == nil {
+		diskDir = realDir
+		if fileExist(filepath.Join(realDir, "partition")) {
+			diskDir = filepath.Dir(realDir)
+		}
+	}
+	pd.Brand = readSysfs(diskDir, "device/vendor")
+	pd.Model = readSysfs(diskDir, "device/model")
+	pd.SerialNumber = readSysfs(diskDir, "device/serial")
+	switch readSysfs(diskDir, "queue/rotational") {
+	case "1":
+		pd.PdMediaType = "Hard Disk Device"
+	case "0":
+		pd.PdMediaType = "Solid State Device"
+	}
+	return pd
+}
+
+// md阵列状态转换为MegaCli风格，保证ListBrokenVirtualDrive的判断不变
+func mdArrayState(stat *MdArrayStat) string {
+	switch stat.ArrayState {
+	case "clear", "inactive", "suspended":
+		return stat.ArrayState
+	}
+	if stat.Degraded > 0 {
+		if stat.SyncAction == "recover" {
+			return fmt.Sprintf("Degraded, recover %.1f%%", stat.SyncProgress)
+		}
+		return "Degraded"
+	}
+	return "Optimal"
+}
+
+// dev-*/state 是逗号分隔的标志位，例如 in_sync,write_mostly
+func mdMemberState(state string) string {
+	flags := strings.Split(state, ",")
+	for _, flag := range flags {
+		if flag == "faulty" {
+			return "Failed"
+		}
+	}
+	for _, flag := range flags {
+		switch flag {
+		case "in_sync":
+			return "Online, Spun Up"
+		case "spare":
+			return "Hotspare, Spun Up"
+		}
+	}
+	return state
+}
+
+func readSysfs(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func readSysfsInt(dir, name string) int {
+	value, err := strconv.Atoi(readSysfs(dir, name))
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// 按MegaCli的格式输出容量，例如 278.875 GB
+func formatSize(bytes uint64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB", "PB"}
+	size := float64(bytes)
+	unit := 0
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+	return strconv.FormatFloat(size, 'f', 3, 64) + " " + units[unit]
+}
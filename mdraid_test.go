@@ -0,0 +1,110 @@
+package diskutil
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMdBackendGet(t *testing.T) {
+	root := filepath.Join("testdata", "md")
+	m := NewMdBackend(filepath.Join(root, "proc"), filepath.Join(root, "sys"))
+	if !m.HasArray() {
+		t.Fatal("HasArray() = false")
+	}
+	ads, err := m.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ads) != 1 || ads[0].Backend != backendMd {
+		t.Fatalf("adapters = %+v", ads)
+	}
+
+	vds := ads[0].VirtualDriveStats
+	wantVds := []struct {
+		name, size, state, raidLevel string
+		drives                       int
+		stat                         MdArrayStat
+	}{
+		{"md0", "511.438 MB", "Optimal", "RAID1", 2, MdArrayStat{
+			ArrayState: "clean", Level: "raid1", RaidDisks: 2, SyncAction: "idle", Members: []string{"sda1", "sdb1"},
+		}},
+		{"md1", "7.277 TB", "Degraded, recover 25.0%", "RAID6", 4, MdArrayStat{
+			ArrayState: "active", Level: "raid6", RaidDisks: 4, Degraded: 2, SyncAction: "recover", SyncProgress: 25,
+			Members: []string{"sdc", "sdd", "sde", "sdf"},
+		}},
+	}
+	if len(vds) != len(wantVds) {
+		t.Fatalf("got %d arrays, want %d", len(vds), len(wantVds))
+	}
+	for i, want := range wantVds {
+		vd := vds[i]
+		if vd.Name != want.name || vd.Size != want.size || vd.State != want.state || vd.RaidLevel != want.raidLevel ||
+			vd.NumberOfDrives != want.drives || vd.OsPath != "/dev/"+want.name {
+			t.Errorf("array %d = %+v", i, vd)
+		}
+		if !reflect.DeepEqual(*vd.MdStat, want.stat) {
+			t.Errorf("%s md stat = %+v, want %+v", want.name, *vd.MdStat, want.stat)
+		}
+	}
+
+	wantPds := []struct {
+		osPath, state, diskGroup, arm, model, mediaType string
+		slot                                            int
+	}{
+		{"/dev/sda1", "Online, Spun Up", "md0", "0", "INTEL SSDSC2KB48", "Solid State Device", 0},
+		{"/dev/sdb1", "Online, Spun Up", "md0", "1", "INTEL SSDSC2KB48", "Solid State Device", 1},
+		{"/dev/sdf", "Failed", "md1", "", "ST4000NM0035-1V4", "Hard Disk Device", -1},
+		{"/dev/sdc", "Online, Spun Up", "md1", "0", "ST4000NM0035-1V4", "Hard Disk Device", 0},
+		{"/dev/sdd", "Rebuild", "md1", "1", "ST4000NM0035-1V4", "Hard Disk Device", 1},
+		{"Unknown", "Missing", "md1", "2", "", "", 2},
+		{"/dev/sde", "Online, Spun Up", "md1", "3", "ST4000NM0035-1V4", "Hard Disk Device", 3},
+	}
+	pds := ads[0].PhysicalDriveStats
+	if len(pds) != len(wantPds) {
+		t.Fatalf("got %d members, want %d", len(pds), len(wantPds))
+	}
+	for i, want := range wantPds {
+		pd := pds[i]
+		got := []interface{}{pd.OsPath, pd.FirmwareState, pd.PdDiskGroup, pd.PdArm, pd.Model, pd.PdMediaType, pd.SlotNumber}
+		exp := []interface{}{want.osPath, want.state, want.diskGroup, want.arm, want.model, want.mediaType, want.slot}
+		if !reflect.DeepEqual(got, exp) {
+			t.Errorf("member %d = %v, want %v", i, got, exp)
+		}
+	}
+	if pds[0].SerialNumber != "PHYF_sda" || pds[0].RawSize != "512.000 MB" {
+		t.Errorf("partition member = %+v, want the serial of sda and the size of sda1", pds[0])
+	}
+}
+
+func TestMdStates(t *testing.T) {
+	members := []struct {
+		state, want string
+	}{
+		{"in_sync", "Online, Spun Up"},
+		{"in_sync,write_mostly", "Online, Spun Up"},
+		{"faulty,in_sync", "Failed"},
+		{"spare", "Hotspare, Spun Up"},
+		{"blocked", "blocked"},
+	}
+	for _, tt := range members {
+		if got := mdMemberState(tt.state); got != tt.want {
+			t.Errorf("mdMemberState(%q) = %q, want %q", tt.state, got, tt.want)
+		}
+	}
+
+	arrays := []struct {
+		stat MdArrayStat
+		want string
+	}{
+		{MdArrayStat{ArrayState: "clean"}, "Optimal"},
+		{MdArrayStat{ArrayState: "active", Degraded: 1}, "Degraded"},
+		{MdArrayStat{ArrayState: "active", Degraded: 1, SyncAction: "recover", SyncProgress: 42.5}, "Degraded, recover 42.5%"},
+		{MdArrayStat{ArrayState: "inactive", Degraded: 1}, "inactive"},
+	}
+	for _, tt := range arrays {
+		if got := mdArrayState(&tt.stat); got != tt.want {
+			t.Errorf("mdArrayState(%+v) = %q, want %q", tt.stat, got, tt.want)
+		}
+	}
+}
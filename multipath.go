@@ -0,0 +1,116 @@
+package diskutil
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const dmMultipathUuidPrefix string = "mpath-"
+
+// MultipathPath is a struct to get one underlying path of a multipath device.
+type MultipathPath struct {
+	Device      string `json:"device"`
+	ScsiAddress string `json:"scsi_address"`
+	State       string `json:"state"`
+}
+
+// MultipathDevice is a struct to get the device-mapper multipath map of a JBOD
+// which is connected by more than one path, e.g. a dual-path SAS JBOD.
+type MultipathDevice struct {
+	Name     string          `json:"name"`
+	DmDevice string          `json:"dm_device"`
+	Wwid     string          `json:"wwid"`
+	Paths    []MultipathPath `json:"paths"`
+}
+
+// multipathMaps() 遍历 /sys/block/dm-*，找出dm uuid以mpath-开头的map，key为每条路径的设备名
+func (r *osDeviceResolver) multipathMaps() map[string]*MultipathDevice {
+	maps := make(map[string]*MultipathDevice)
+	blockDir := filepath.Join(r.sysRoot, "block")
+	entries, err := os.ReadDir(blockDir)
+	if err != nil {
+		return maps
+	}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), "dm-") {
+			continue
+		}
+		dmDir := filepath.Join(blockDir, entry.Name())
+		uuid := readSysfs(dmDir, "dm/uuid")
+		if !strings.HasPrefix(uuid, dmMultipathUuidPrefix) {
+			continue
+		}
+		slaves, err := os.ReadDir(filepath.Join(dmDir, "slaves"))
+		if err != nil {
+			continue
+		}
+
+		mp := &MultipathDevice{
+			Name:     readSysfs(dmDir, "dm/name"),
+			DmDevice: entry.Name(),
+			Wwid:     strings.TrimPrefix(uuid, dmMultipathUuidPrefix),
+			Paths:    make([]MultipathPath, 0, len(slaves)),
+		}
+		for _, slave := range slaves {
+			path := MultipathPath{
+				Device: slave.Name(),
+				State:  readSysfs(filepath.Join(blockDir, slave.Name()), "device/state"),
+			}
+			if realDir, err := filepath.EvalSymlinks(filepath.Join(blockDir, slave.Name(), "device")); err == nil {
+				if scsiAddressRegex.MatchString(filepath.Base(realDir)) {
+					path.ScsiAddress = filepath.Base(realDir)
+				}
+			}
+			mp.Paths = append(mp.Paths, path)
+			maps[slave.Name()] = mp
+		}
+		sort.Slice(mp.Paths, func(i, j int) bool {
+			return mp.Paths[i].Device < mp.Paths[j].Device
+		})
+	}
+	return maps
+}
+
+// fillMultipath() 把属于multipath map的JBOD映射到 /dev/mapper/<name>，并列出所有路径
+func (r *osDeviceResolver) fillMultipath(ads []AdapterStat) {
+	var maps map[string]*MultipathDevice
+	for i := range ads {
+		for j := range ads[i].PhysicalDriveStats {
+			pd := &ads[i].PhysicalDriveStats[j]
+			if !strings.HasPrefix(pd.OsPath, "/") {
+				continue
+			}
+			if maps == nil {
+				maps = r.multipathMaps()
+			}
+			mp, ok := maps[filepath.Base(pd.OsPath)]
+			if !ok {
+				continue
+			}
+			pd.Multipath = mp
+			if mp.Name != "" {
+				pd.OsPath = r.devPath(filepath.Join("mapper", mp.Name))
+			} else {
+				pd.OsPath = r.devPath(mp.DmDevice)
+			}
+		}
+	}
+}
+
+// kernelName() 获取OsPath对应的内核设备名，/dev/mapper/<name> 等软链接需要解析
+func (r *osDeviceResolver) kernelName(osPath string) string {
+	if strings.HasPrefix(osPath, r.devRoot+"/mapper/") {
+		if realPath, err := filepath.EvalSymlinks(osPath); err == nil {
+			return filepath.Base(realPath)
+		}
+		name := strings.TrimPrefix(osPath, r.devRoot+"/mapper/")
+		for _, mp := range r.multipathMaps() {
+			if mp.Name == name {
+				return mp.DmDevice
+			}
+		}
+	}
+	return filepath.Base(osPath)
+}
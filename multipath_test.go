@@ -0,0 +1,85 @@
+package diskutil
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMultipathMaps(t *testing.T) {
+	r := newFixtureResolver(t)
+	maps := r.multipathMaps()
+	if len(maps) != 2 || maps["sde"] == nil || maps["sde"] != maps["sdf"] {
+		t.Fatalf("multipathMaps() = %v, want one map of sde and sdf", maps)
+	}
+	want := MultipathDevice{
+		Name:     "mpathb",
+		DmDevice: "dm-1",
+		Wwid:     "35000c500cafe0000",
+		Paths: []MultipathPath{
+			{Device: "sde", ScsiAddress: "0:0:40:0", State: "running"},
+			{Device: "sdf", ScsiAddress: "0:0:41:0", State: "transport-offline"},
+		},
+	}
+	if !reflect.DeepEqual(*maps["sde"], want) {
+		t.Errorf("multipath map =\n%+v\nwant\n%+v", *maps["sde"], want)
+	}
+}
+
+func TestFillMultipath(t *testing.T) {
+	ad := fixtureMegaCliAdapter(t)
+	ad.PhysicalDriveStats = append(ad.PhysicalDriveStats, PhysicalDriveStat{EnclosureDeviceId: 32, SlotNumber: 40, DeviceId: 40,
+		FirmwareState: "JBOD", OsPath: filepath.Join(fixtureRoot(t), "dev", "sde")})
+	d := newFixtureDiskStatus(t, ad)
+	if err := d.Get(); err != nil {
+		t.Fatal(err)
+	}
+
+	devRoot := filepath.Join(fixtureRoot(t), "dev")
+	for _, pd := range d.AdapterStats[0].PhysicalDriveStats {
+		switch pd.SlotNumber {
+		case 40:
+			if pd.OsPath != filepath.Join(devRoot, "mapper", "mpathb") {
+				t.Errorf("dual-path JBOD OsPath = %q, want the multipath map", pd.OsPath)
+			}
+			if pd.Multipath == nil || len(pd.Multipath.Paths) != 2 || pd.Multipath.Paths[1].State != "transport-offline" {
+				t.Errorf("dual-path JBOD Multipath = %+v", pd.Multipath)
+			}
+			if pd.OsDevice == nil || pd.OsDevice.Name != "dm-1" {
+				t.Errorf("dual-path JBOD OsDevice = %+v, want dm-1", pd.OsDevice)
+			}
+		case 20:
+			if pd.OsPath != filepath.Join(devRoot, "sdc") || pd.Multipath != nil {
+				t.Errorf("single path JBOD = %q %+v, want sdc", pd.OsPath, pd.Multipath)
+			}
+		default:
+			if pd.OsPath != "Unknown" || pd.Multipath != nil {
+				t.Errorf("[32:%d] = %q %+v, want Unknown", pd.SlotNumber, pd.OsPath, pd.Multipath)
+			}
+		}
+	}
+}
+
+func TestKernelName(t *testing.T) {
+	r := newFixtureResolver(t)
+	tests := []struct {
+		osPath string
+		want   string
+	}{
+		{filepath.Join(r.devRoot, "mapper", "mpathb"), "dm-1"},
+		{filepath.Join(r.devRoot, "mapper", "vg1-mysql"), "dm-2"},
+		{filepath.Join(r.devRoot, "sdc"), "sdc"},
+		{filepath.Join(r.devRoot, "mapper", "mpathz"), "mpathz"},
+	}
+	for _, tt := range tests {
+		if name := r.kernelName(tt.osPath); name != tt.want {
+			t.Errorf("kernelName(%q) = %q, want %q", tt.osPath, name, tt.want)
+		}
+	}
+
+	// /dev/mapper下没有软链接时按multipath map的名字查找
+	r.devRoot = t.TempDir()
+	if name := r.kernelName(filepath.Join(r.devRoot, "mapper", "mpathb")); name != "dm-1" {
+		t.Errorf("kernelName() without the /dev/mapper link = %q, want dm-1", name)
+	}
+}
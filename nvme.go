@@ -0,0 +1,286 @@
+package diskutil
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 控制器目录下的namespace：nvme0n1，开启原生多路径时是路径设备nvme0c1n1。
+// 名字中的第一个数字是子系统实例而不是控制器编号，块设备要从sysfs解析
+var nvmeNamespaceRegex = regexp.MustCompile(`^nvme\d+(?:c\d+)?n\d+$`)
+
+// critical_warning的每一位的含义，见NVMe规范 SMART / Health Information
+var nvmeCriticalWarnings = []string{
+	"available spare below threshold",
+	"temperature threshold exceeded",
+	"reliability degraded",
+	"media read only",
+	"volatile memory backup failed",
+	"persistent memory region read only",
+}
+
+// NvmeHealthStat is a struct to get the NVMe SMART / Health Information of a PhysicalDriveStat.
+type NvmeHealthStat struct {
+	CriticalWarning         int      `json:"critical_warning"`
+	CriticalWarnings        []string `json:"critical_warnings"`
+	PercentageUsed          int      `json:"percentage_used"`
+	MediaErrors             int      `json:"media_errors"`
+	AvailableSpare          int      `json:"available_spare"`
+	AvailableSpareThreshold int      `json:"available_spare_threshold"`
+	Temperature             int      `json:"temperature"`
+	PowerOnHours            int      `json:"power_on_hours"`
+	UnsafeShutdowns         int      `json:"unsafe_shutdowns"`
+}
+
+// NvmeBackend is a Backend which reads /sys/class/nvme and "nvme smart-log" to get
+// the inventory and health of NVMe drives, which bypass the RAID card entirely.
+// Every NVMe controller is a PhysicalDriveStat of one AdapterStat.
+type NvmeBackend struct {
+	nvmePath string
+	sysRoot  string
+}
+
+// NewNvmeBackend() use the nvmeCliPath and sysRoot to build a NvmeBackend.
+// An empty nvmeCliPath means only the sysfs inventory is collected without
+// health, an empty sysRoot means /sys.
+func NewNvmeBackend(nvmeCliPath string, sysRoot string) (*NvmeBackend, error) {
+	if nvmeCliPath != "" {
+		nvmeCliPath = path.Clean(nvmeCliPath)
+		if !fileExist(nvmeCliPath) {
+			return nil, errors.New("nvme-cli not exist")
+		}
+	}
+	if sysRoot == "" {
+		sysRoot = defaultSysRoot
+	}
+	return &NvmeBackend{
+		nvmePath: nvmeCliPath,
+		sysRoot:  filepath.Clean(sysRoot),
+	}, nil
+}
+
+// SetSysfsRoot() is used to change the sysfs root of the backend, the
+// devRoot is not used because nvme namespaces are always named /dev/nvmeXnY.
+func (n *NvmeBackend) SetSysfsRoot(sysRoot, devRoot string) {
+	n.sysRoot = newOsDeviceResolver(sysRoot, devRoot).sysRoot
+}
+
+// Name() is used to get the name of the backend.
+func (n *NvmeBackend) Name() string {
+	return backendNvme
+}
+
+// HasDevice() is used to check if there is any NVMe controller on the server.
+func (n *NvmeBackend) HasDevice() bool {
+	controllers, err := n.listControllers()
+	return err == nil && len(controllers) > 0
+}
+
+// Get() is used to get all the AdapterStats of the backend.
+func (n *NvmeBackend) Get() ([]AdapterStat, error) {
+	controllers, err := n.listControllers()
+	if err != nil {
+		return nil, err
+	}
+
+	ad := AdapterStat{
+		AdapterId:          0,
+		Backend:            backendNvme,
+		VirtualDriveStats:  make([]VirtualDriveStat, 0),
+		PhysicalDriveStats: make([]PhysicalDriveStat, 0),
+	}
+	slots := n.pciSlots()
+	for _, name := range controllers {
+		pd := n.parseController(name, slots)
+		ad.PhysicalDriveStats = append(ad.PhysicalDriveStats, pd)
+	}
+	return []AdapterStat{ad}, nil
+}
+
+// GetVirtualDrive() is used to get the AdapterStats with VirtualDriveStats only.
+// NVMe drives have no virtual drive, so the AdapterStat is empty.
+func (n *NvmeBackend) GetVirtualDrive() ([]AdapterStat, error) {
+	return []AdapterStat{{
+		AdapterId:         0,
+		Backend:           backendNvme,
+		VirtualDriveStats: make([]VirtualDriveStat, 0),
+	}}, nil
+}
+
+// GetPhysicalDrive() is used to get the AdapterStats with PhysicalDriveStats only.
+func (n *NvmeBackend) GetPhysicalDrive() ([]AdapterStat, error) {
+	ads, err := n.Get()
+	if err != nil {
+		return nil, err
+	}
+	for i := range ads {
+		ads[i].VirtualDriveStats = nil
+	}
+	return ads, nil
+}
+
+func (n *NvmeBackend) listControllers() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(n.sysRoot, "class", "nvme"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	controllers := make([]string, 0)
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "nvme") {
+			controllers = append(controllers, entry.Name())
+		}
+	}
+	sort.Slice(controllers, func(i, j int) bool {
+		return nvmeNumber(controllers[i]) < nvmeNumber(controllers[j])
+	})
+	return controllers, nil
+}
+
+func nvmeNumber(name string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(name, "nvme"))
+	return n
+}
+
+// 读取 /sys/bus/pci/slots/*/address，得到PCI地址到物理槽位的映射
+func (n *NvmeBackend) pciSlots() map[string]int {
+	slots := make(map[string]int)
+	slotDir := filepath.Join(n.sysRoot, "bus", "pci", "slots")
+	entries, err := os.ReadDir(slotDir)
+	if err != nil {
+		return slots
+	}
+	for _, entry := range entries {
+		slot, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		// address不带function，例如 0000:5e:00
+		if address := readSysfs(filepath.Join(slotDir, entry.Name()), "address"); address != "" {
+			slots[address] = slot
+		}
+	}
+	return slots
+}
+
+func (n *NvmeBackend) parseController(name string, slots map[string]int) PhysicalDriveStat {
+	ctrlDir := filepath.Join(n.sysRoot, "class", "nvme", name)
+	pd := PhysicalDriveStat{
+		EnclosureDeviceId: 999,
+		DeviceId:          nvmeNumber(name),
+		SlotNumber:        nvmeNumber(name),
+		PdType:            "NVMe",
+		PdMediaType:       "Solid State Device",
+		Model:             readSysfs(ctrlDir, "model"),
+		SerialNumber:      readSysfs(ctrlDir, "serial"),
+		Location:          readSysfs(ctrlDir, "address"),
+		OsPath:            "Unknown",
+	}
+	if i := strings.LastIndex(pd.Location, "."); i > 0 {
+		if slot, ok := slots[pd.Location[:i]]; ok {
+			pd.SlotNumber = slot
+		}
+	}
+
+	// 第一个namespace作为系统盘符，容量为所有namespace之和
+	var size uint64
+	if entries, err := os.ReadDir(ctrlDir); err == nil {
+		for _, entry := range entries {
+			if !nvmeNamespaceRegex.MatchString(entry.Name()) {
+				continue
+			}
+			size += uint64(readSysfsInt(filepath.Join(ctrlDir, entry.Name()), "size")) * 512
+			if pd.OsPath != "Unknown" {
+				continue
+			}
+			if block, ok := n.namespaceBlock(entry.Name()); ok {
+				pd.OsPath = "/dev/" + block
+			}
+		}
+	}
+	pd.RawSize = formatSize(size)
+
+	state := readSysfs(ctrlDir, "state")
+	pd.FirmwareState = "Online, Spun Up"
+	if state != "" && state != "live" {
+		pd.FirmwareState = state
+	}
+
+	if n.nvmePath != "" {
+		// 单盘获取失败不影响其他盘
+		if health, err := n.getSmartLog("/dev/" + name); err == nil {
+			pd.NvmeHealth = health
+			pd.MediaErrorCount = health.MediaErrors
+			pd.DriveTemperature = strconv.Itoa(health.Temperature) + "C"
+			if health.CriticalWarning != 0 {
+				pd.FirmwareState = "Critical Warning: " + strings.Join(health.CriticalWarnings, ", ")
+			}
+		}
+	}
+	return pd
+}
+
+// namespaceBlock() namespace本身是块设备时直接使用；多路径的路径设备是隐藏的，
+// 块设备是在 multipath 目录下列出它的那个head
+func (n *NvmeBackend) namespaceBlock(namespace string) (string, bool) {
+	blockDir := filepath.Join(n.sysRoot, "class", "block")
+	if fileExist(filepath.Join(blockDir, namespace)) {
+		return namespace, true
+	}
+	heads, err := os.ReadDir(blockDir)
+	if err != nil {
+		return "", false
+	}
+	for _, head := range heads {
+		if _, err := os.Lstat(filepath.Join(blockDir, head.Name(), "multipath", namespace)); err == nil {
+			return head.Name(), true
+		}
+	}
+	return "", false
+}
+
+func (n *NvmeBackend) getSmartLog(device string) (*NvmeHealthStat, error) {
+	output, err := execCmd(n.nvmePath, "smart-log "+device+" -o json")
+	if err != nil {
+		return nil, err
+	}
+	return parseNvmeSmartLog(output)
+}
+
+func parseNvmeSmartLog(output string) (*NvmeHealthStat, error) {
+	data := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(output), &data); err != nil {
+		return nil, err
+	}
+
+	health := &NvmeHealthStat{
+		CriticalWarning:         jsonInt(data, "critical_warning"),
+		CriticalWarnings:        make([]string, 0),
+		PercentageUsed:          jsonInt(data, "percent_used") + jsonInt(data, "percentage_used"),
+		MediaErrors:             jsonInt(data, "media_errors"),
+		AvailableSpare:          jsonInt(data, "avail_spare"),
+		AvailableSpareThreshold: jsonInt(data, "spare_thresh"),
+		Temperature:             jsonInt(data, "temperature"),
+		PowerOnHours:            jsonInt(data, "power_on_hours"),
+		UnsafeShutdowns:         jsonInt(data, "unsafe_shutdowns"),
+	}
+	// 旧版本nvme-cli输出的是开尔文温度
+	if health.Temperature > 200 {
+		health.Temperature -= 273
+	}
+	for bit, warning := range nvmeCriticalWarnings {
+		if health.CriticalWarning&(1<<uint(bit)) != 0 {
+			health.CriticalWarnings = append(health.CriticalWarnings, warning)
+		}
+	}
+	return health, nil
+}
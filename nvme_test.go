@@ -0,0 +1,109 @@
+package diskutil
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseNvmeSmartLog(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    NvmeHealthStat
+	}{
+		{"smart_log_ok.json", NvmeHealthStat{
+			CriticalWarnings: []string{}, PercentageUsed: 3, AvailableSpare: 100, AvailableSpareThreshold: 10,
+			Temperature: 35, PowerOnHours: 26128, UnsafeShutdowns: 17,
+		}},
+		{"smart_log_warning.json", NvmeHealthStat{
+			CriticalWarning: 5, CriticalWarnings: []string{"available spare below threshold", "reliability degraded"},
+			PercentageUsed: 97, MediaErrors: 12, AvailableSpare: 8, AvailableSpareThreshold: 10,
+			Temperature: 72, PowerOnHours: 43311, UnsafeShutdowns: 64,
+		}},
+		// 新版本nvme-cli: percentage_used，摄氏温度
+		{"smart_log_celsius.json", NvmeHealthStat{
+			CriticalWarnings: []string{}, PercentageUsed: 1, AvailableSpare: 100, AvailableSpareThreshold: 10,
+			Temperature: 36, PowerOnHours: 1200, UnsafeShutdowns: 2,
+		}},
+	}
+	for _, tt := range tests {
+		data, err := os.ReadFile(filepath.Join("testdata", "nvme", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		health, err := parseNvmeSmartLog(string(data))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.fixture, err)
+		}
+		if !reflect.DeepEqual(*health, tt.want) {
+			t.Errorf("%s =\n%+v\nwant\n%+v", tt.fixture, *health, tt.want)
+		}
+	}
+
+	if _, err := parseNvmeSmartLog("Error: No such device"); err == nil {
+		t.Error("illegal output accepted")
+	}
+}
+
+func TestNvmeBackendGet(t *testing.T) {
+	fixtures, err := filepath.Abs(filepath.Join("testdata", "nvme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(t.TempDir(), "nvme")
+	content := "#!/bin/sh\ncase \"$2\" in\n" +
+		"/dev/nvme0) cat '" + fixtures + "/smart_log_ok.json' ;;\n" +
+		"/dev/nvme1) cat '" + fixtures + "/smart_log_warning.json' ;;\n" +
+		"*) cat '" + fixtures + "/smart_log_celsius.json' ;;\n" +
+		"esac\n"
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	n, err := NewNvmeBackend(script, filepath.Join(fixtures, "sys"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !n.HasDevice() {
+		t.Fatal("HasDevice() = false")
+	}
+	ads, err := n.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ads) != 1 || ads[0].Backend != backendNvme {
+		t.Fatalf("adapters = %+v", ads)
+	}
+
+	tests := []struct {
+		deviceId, slot int
+		model          string
+		location       string
+		osPath         string
+		rawSize        string
+		state          string
+		temperature    string
+		mediaErrors    int
+	}{
+		{0, 12, "INTEL SSDPE2KX040T8", "0000:5e:00.0", "/dev/nvme0n1", "3.493 TB", "Online, Spun Up", "35C", 0},
+		// 多路径：控制器nvme1的路径设备nvme4c1n1属于子系统4，块设备是nvme4n1
+		{1, 1, "SAMSUNG MZQLB1T9HAJR-00007", "0000:d8:00.0", "/dev/nvme4n1", "3.493 TB",
+			"Critical Warning: available spare below threshold, reliability degraded", "72C", 12},
+		{2, 13, "INTEL SSDPE2KX040T8", "0000:5f:00.0", "Unknown", "0.000 B", "resetting", "36C", 0},
+	}
+	pds := ads[0].PhysicalDriveStats
+	if len(pds) != len(tests) {
+		t.Fatalf("got %d drives, want %d", len(pds), len(tests))
+	}
+	for i, tt := range tests {
+		pd := pds[i]
+		got := []interface{}{pd.DeviceId, pd.SlotNumber, pd.Model, pd.Location, pd.OsPath, pd.RawSize, pd.FirmwareState, pd.DriveTemperature, pd.MediaErrorCount}
+		want := []interface{}{tt.deviceId, tt.slot, tt.model, tt.location, tt.osPath, tt.rawSize, tt.state, tt.temperature, tt.mediaErrors}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("drive %d = %v, want %v", i, got, want)
+		}
+		if pd.NvmeHealth == nil {
+			t.Errorf("drive %d has no health", i)
+		}
+	}
+}
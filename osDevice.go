@@ -0,0 +1,149 @@
+package diskutil
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// OSDevice is a struct to get the OS side identity of a VD or JBOD, so that
+// a stable name can be used instead of the sdX letters which reorder on reboot.
+type OSDevice struct {
+	Name        string   `json:"name"`
+	MajorMinor  string   `json:"major_minor"`
+	ById        []string `json:"by_id"`
+	ByPath      []string `json:"by_path"`
+	ByUuid      []string `json:"by_uuid"`
+	Wwn         string   `json:"wwn"`
+	Size        uint64   `json:"size"`
+	Rotational  bool     `json:"rotational"`
+	Scheduler   string   `json:"scheduler"`
+	ScsiAddress string   `json:"scsi_address"`
+	// Usage is what is on the device: partitions, holders, filesystems and mount points.
+	Usage *BlockDevice `json:"usage,omitempty"`
+}
+
+// String() is used to get the print string.
+func (o *OSDevice) String() string {
+	data, err := json.Marshal(o)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+// StableName() is used to get the most stable name of the device:
+// the first by-id alias, then the first by-path alias, then the /dev path.
+func (o *OSDevice) StableName() string {
+	if len(o.ById) > 0 {
+		return o.ById[0]
+	}
+	if len(o.ByPath) > 0 {
+		return o.ByPath[0]
+	}
+	return "/dev/" + o.Name
+}
+
+// /dev/disk/by-* 的软链接，key为目标块设备名
+type osDeviceAliases struct {
+	byId   map[string][]string
+	byPath map[string][]string
+	byUuid map[string][]string
+}
+
+func (r *osDeviceResolver) aliases() *osDeviceAliases {
+	return &osDeviceAliases{
+		byId:   r.aliasDir("by-id"),
+		byPath: r.aliasDir("by-path"),
+		byUuid: r.aliasDir("by-uuid"),
+	}
+}
+
+func (r *osDeviceResolver) aliasDir(kind string) map[string][]string {
+	result := make(map[string][]string)
+	dir := filepath.Join(r.devRoot, "disk", kind)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return result
+	}
+	for _, entry := range entries {
+		link := filepath.Join(dir, entry.Name())
+		target, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			continue
+		}
+		name := filepath.Base(target)
+		result[name] = append(result[name], link)
+	}
+	return result
+}
+
+// osDevice() 从 /sys/class/block/<name> 读取块设备信息
+func (r *osDeviceResolver) osDevice(name string, aliases *osDeviceAliases, mounts []mountEntry) *OSDevice {
+	blockDir := filepath.Join(r.sysRoot, "class", "block", name)
+	if !fileExist(blockDir) {
+		return nil
+	}
+
+	device := &OSDevice{
+		Name:       name,
+		MajorMinor: readSysfs(blockDir, "dev"),
+		ById:       append([]string{}, aliases.byId[name]...),
+		ByPath:     append([]string{}, aliases.byPath[name]...),
+		ByUuid:     append([]string{}, aliases.byUuid[name]...),
+		Rotational: readSysfs(blockDir, "queue/rotational") == "1",
+	}
+	if size, err := strconv.ParseUint(readSysfs(blockDir, "size"), 10, 64); err == nil {
+		device.Size = size * 512
+	}
+	// nvme的wwid在块设备目录下，SCSI盘在device目录下
+	device.Wwn = readSysfs(blockDir, "device/wwid")
+	if device.Wwn == "" {
+		device.Wwn = readSysfs(blockDir, "wwid")
+	}
+	// "mq-deadline kyber [none]" 方括号中为当前调度器
+	scheduler := readSysfs(blockDir, "queue/scheduler")
+	if start, end := strings.Index(scheduler, "["), strings.Index(scheduler, "]"); start >= 0 && end > start {
+		scheduler = scheduler[start+1 : end]
+	}
+	device.Scheduler = scheduler
+	if realDir, err := filepath.EvalSymlinks(filepath.Join(blockDir, "device")); err == nil {
+		if scsiAddressRegex.MatchString(filepath.Base(realDir)) {
+			device.ScsiAddress = filepath.Base(realDir)
+		}
+	}
+	usage := r.blockDevice(name, mounts, 0)
+	device.Usage = &usage
+	return device
+}
+
+// fillOsDevices() 为所有已映射到系统的VD和PD填充OSDevice
+func (r *osDeviceResolver) fillOsDevices(ads []AdapterStat) {
+	var (
+		aliases *osDeviceAliases
+		mounts  []mountEntry
+	)
+	lookup := func(osPath string) *OSDevice {
+		if !strings.HasPrefix(osPath, "/") {
+			return nil
+		}
+		if aliases == nil {
+			aliases = r.aliases()
+			mounts = r.mountEntries()
+		}
+		return r.osDevice(r.kernelName(osPath), aliases, mounts)
+	}
+
+	for i := range ads {
+		for j := range ads[i].VirtualDriveStats {
+			vd := &ads[i].VirtualDriveStats[j]
+			vd.OsDevice = lookup(vd.OsPath)
+		}
+		for j := range ads[i].PhysicalDriveStats {
+			pd := &ads[i].PhysicalDriveStats[j]
+			pd.OsDevice = lookup(pd.OsPath)
+		}
+	}
+}
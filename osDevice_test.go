@@ -0,0 +1,110 @@
+package diskutil
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOsDevice(t *testing.T) {
+	r := newFixtureResolver(t)
+	devRoot := filepath.Join(fixtureRoot(t), "dev")
+	aliases := r.aliases()
+	tests := []struct {
+		name       string
+		want       OSDevice
+		stableName string
+	}{
+		{
+			name: "sdc",
+			want: OSDevice{
+				Name:        "sdc",
+				MajorMinor:  "8:32",
+				ById:        []string{filepath.Join(devRoot, "disk", "by-id", "scsi-35000c500a1b2c3e0"), filepath.Join(devRoot, "disk", "by-id", "wwn-0x5000c500a1b2c3e0")},
+				ByPath:      []string{filepath.Join(devRoot, "disk", "by-path", "pci-0000:3b:00.0-scsi-0:0:20:0")},
+				ByUuid:      []string{},
+				Wwn:         "naa.5000c500a1b2c3e0",
+				Size:        7814037168 * 512,
+				Rotational:  true,
+				Scheduler:   "mq-deadline",
+				ScsiAddress: "0:0:20:0",
+			},
+			stableName: filepath.Join(devRoot, "disk", "by-id", "scsi-35000c500a1b2c3e0"),
+		},
+		{
+			// SSD VD没有by-id，文件系统在分区上所以也没有by-uuid
+			name: "sda",
+			want: OSDevice{
+				Name:        "sda",
+				MajorMinor:  "8:0",
+				ById:        []string{},
+				ByPath:      []string{filepath.Join(devRoot, "disk", "by-path", "pci-0000:3b:00.0-scsi-0:2:0:0")},
+				ByUuid:      []string{},
+				Size:        936640512 * 512,
+				Scheduler:   "none",
+				ScsiAddress: "0:2:0:0",
+			},
+			stableName: filepath.Join(devRoot, "disk", "by-path", "pci-0000:3b:00.0-scsi-0:2:0:0"),
+		},
+		{
+			name: "sdb",
+			want: OSDevice{
+				Name:        "sdb",
+				MajorMinor:  "8:16",
+				ById:        []string{},
+				ByPath:      []string{},
+				ByUuid:      []string{},
+				Size:        23437770752 * 512,
+				Rotational:  true,
+				Scheduler:   "mq-deadline",
+				ScsiAddress: "0:3:1:0",
+			},
+			stableName: "/dev/sdb",
+		},
+	}
+	for _, tt := range tests {
+		device := r.osDevice(tt.name, aliases, nil)
+		if device == nil {
+			t.Errorf("osDevice(%q) = nil", tt.name)
+			continue
+		}
+		if device.Usage == nil || device.Usage.Name != tt.name {
+			t.Errorf("osDevice(%q) usage = %+v", tt.name, device.Usage)
+		}
+		got := *device
+		got.Usage = nil
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("osDevice(%q) =\n%+v\nwant\n%+v", tt.name, got, tt.want)
+		}
+		if name := device.StableName(); name != tt.stableName {
+			t.Errorf("%s: StableName() = %q, want %q", tt.name, name, tt.stableName)
+		}
+	}
+
+	if device := r.osDevice("sdz", aliases, nil); device != nil {
+		t.Errorf("osDevice(sdz) = %+v, want nil", device)
+	}
+}
+
+func TestFillOsDevices(t *testing.T) {
+	d := newFixtureDiskStatus(t, fixtureMegaCliAdapter(t))
+	if err := d.Get(); err != nil {
+		t.Fatal(err)
+	}
+	ad := d.AdapterStats[0]
+	for i, name := range []string{"sda", "sdb"} {
+		if device := ad.VirtualDriveStats[i].OsDevice; device == nil || device.Name != name {
+			t.Errorf("VD %d OsDevice = %+v, want %s", ad.VirtualDriveStats[i].VirtualDrive, device, name)
+		}
+	}
+	for _, pd := range ad.PhysicalDriveStats {
+		device := pd.OsDevice
+		if pd.SlotNumber == 20 {
+			if device == nil || device.Wwn != "naa.5000c500a1b2c3e0" {
+				t.Errorf("JBOD OsDevice = %+v, want sdc", device)
+			}
+		} else if device != nil {
+			t.Errorf("[32:%d] is not in the OS but has OsDevice %+v", pd.SlotNumber, device)
+		}
+	}
+}
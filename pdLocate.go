@@ -0,0 +1,121 @@
+package diskutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// "E:S"、"[E:S]" 或带adapter的 "a0[E:S]"
+var enclosureSlotRegex = regexp.MustCompile(`^(?:a(\d+))?\[?(\d*):(\d+)\]?$`)
+
+// MegaCli的 -physdrv[E:S]，没有背板(999)时E为空
+func physDrv(enclosure, slot int) string {
+	if enclosure == 999 {
+		return fmt.Sprintf("-physdrv[:%d]", slot)
+	}
+	return fmt.Sprintf("-physdrv[%d:%d]", enclosure, slot)
+}
+
+// StartLocate() is used to start blinking the locate LED of the drive
+// in the enclosure and slot of a MegaRaid adapter.
+func (d *DiskStatus) StartLocate(adapter, enclosure, slot int) error {
+	_, err := d.execMegaCli(fmt.Sprintf("-PdLocate -start %s -a%d -NoLog", physDrv(enclosure, slot), adapter))
+	return err
+}
+
+// StopLocate() is used to stop blinking the locate LED of the drive
+// in the enclosure and slot of a MegaRaid adapter.
+func (d *DiskStatus) StopLocate(adapter, enclosure, slot int) error {
+	_, err := d.execMegaCli(fmt.Sprintf("-PdLocate -stop %s -a%d -NoLog", physDrv(enclosure, slot), adapter))
+	return err
+}
+
+// StartLocateFor() is used to blink the locate LED for the duration and stop it
+// automatically. It blocks until the duration is over or ctx is done, the LED is
+// stopped in both cases.
+func (d *DiskStatus) StartLocateFor(ctx context.Context, adapter, enclosure, slot int, duration time.Duration) error {
+	if err := d.StartLocate(adapter, enclosure, slot); err != nil {
+		return err
+	}
+	if d.dryRun == nil {
+		timer := time.NewTimer(duration)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+	return d.StopLocate(adapter, enclosure, slot)
+}
+
+// FindPhysicalDrive() is used to find one PD by "E:S" (or "a<adapter>[E:S]"), serial number or /dev path.
+// The returned LocatedDrive has exactly one PhysicalDrive, an ambiguous ident is an error.
+func (d *DiskStatus) FindPhysicalDrive(ident string) (*LocatedDrive, error) {
+	var candidates []LocatedDrive
+	if strings.HasPrefix(ident, "/dev/") {
+		result, err := d.Locate(ident)
+		if err != nil {
+			return nil, err
+		}
+		for _, drive := range result.Drives {
+			for _, pd := range drive.PhysicalDrives {
+				candidates = append(candidates, LocatedDrive{
+					AdapterId:      drive.AdapterId,
+					Backend:        drive.Backend,
+					VirtualDrive:   drive.VirtualDrive,
+					PhysicalDrives: []PhysicalDriveStat{pd},
+				})
+			}
+		}
+	} else {
+		if err := d.GetPhysicalDrive(); err != nil {
+			return nil, err
+		}
+		match := func(adapterId int, pd *PhysicalDriveStat) bool {
+			return pd.SerialNumber != "" && strings.Contains(pd.SerialNumber, ident)
+		}
+		if matches := enclosureSlotRegex.FindStringSubmatch(ident); matches != nil {
+			adapter := -1
+			if matches[1] != "" {
+				adapter, _ = strconv.Atoi(matches[1])
+			}
+			enclosure := 999
+			if matches[2] != "" {
+				enclosure, _ = strconv.Atoi(matches[2])
+			}
+			slot, _ := strconv.Atoi(matches[3])
+			match = func(adapterId int, pd *PhysicalDriveStat) bool {
+				return (adapter < 0 || adapterId == adapter) && pd.EnclosureDeviceId == enclosure && pd.SlotNumber == slot
+			}
+		}
+		for _, ad := range d.AdapterStats {
+			for _, pd := range ad.PhysicalDriveStats {
+				if match(ad.AdapterId, &pd) {
+					candidates = append(candidates, LocatedDrive{
+						AdapterId:      ad.AdapterId,
+						Backend:        ad.Backend,
+						PhysicalDrives: []PhysicalDriveStat{pd},
+					})
+				}
+			}
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil, errors.New("no physical drive found for " + ident)
+	case 1:
+		return &candidates[0], nil
+	}
+	slots := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		pd := c.PhysicalDrives[0]
+		slots = append(slots, fmt.Sprintf("a%d[%d:%d]", c.AdapterId, pd.EnclosureDeviceId, pd.SlotNumber))
+	}
+	return nil, fmt.Errorf("%s matches %d physical drives: %s", ident, len(candidates), strings.Join(slots, " "))
+}
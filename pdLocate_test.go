@@ -0,0 +1,121 @@
+package diskutil
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeMegaCli() 写一个只记录参数的MegaCli64，返回它的路径和记录文件
+func fakeMegaCli(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "MegaCli64")
+	log := filepath.Join(dir, "args.log")
+	content := "#!/bin/sh\necho \"$*\" >> '" + log + "'\necho 'Exit Code: 0x00'\n"
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return script, log
+}
+
+func readArgsLog(t *testing.T, log string) string {
+	data, err := os.ReadFile(log)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestStartLocateForStops(t *testing.T) {
+	const want = "-PdLocate -start -physdrv[32:4] -a1 -NoLog\n-PdLocate -stop -physdrv[32:4] -a1 -NoLog\n"
+
+	script, log := fakeMegaCli(t)
+	d := &DiskStatus{megacliPath: script}
+	if err := d.StartLocateFor(context.Background(), 1, 32, 4, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if args := readArgsLog(t, log); args != want {
+		t.Errorf("after the duration MegaCli ran\n%s\nwant\n%s", args, want)
+	}
+
+	// ctx取消后LED也要停止
+	script, log = fakeMegaCli(t)
+	d = &DiskStatus{megacliPath: script}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	if err := d.StartLocateFor(ctx, 1, 32, 4, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > time.Minute {
+		t.Error("StartLocateFor() did not return when ctx was cancelled")
+	}
+	if args := readArgsLog(t, log); args != want {
+		t.Errorf("after ctx was cancelled MegaCli ran\n%s\nwant\n%s", args, want)
+	}
+
+	// dry-run只打印两条命令，不等待也不执行
+	script, log = fakeMegaCli(t)
+	d = &DiskStatus{megacliPath: script}
+	out := new(bytes.Buffer)
+	d.EnableDryRun(out)
+	if err := d.StartLocateFor(context.Background(), 1, 32, 4, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	printed := script + " -PdLocate -start -physdrv[32:4] -a1 -NoLog\n" + script + " -PdLocate -stop -physdrv[32:4] -a1 -NoLog\n"
+	if out.String() != printed {
+		t.Errorf("dry-run printed\n%s\nwant\n%s", out.String(), printed)
+	}
+	if args := readArgsLog(t, log); args != "" {
+		t.Errorf("dry-run ran MegaCli: %q", args)
+	}
+}
+
+func TestFindPhysicalDrive(t *testing.T) {
+	ad := fixtureMegaCliAdapter(t)
+	for i := range ad.PhysicalDriveStats {
+		ad.PhysicalDriveStats[i].SerialNumber = "ZC1" + strings.Repeat("0", i) + "X"
+	}
+	other := fixtureMegaCliAdapter(t)
+	other.AdapterId = 1
+	other.VirtualDriveStats = nil
+	other.PhysicalDriveStats = other.PhysicalDriveStats[:1]
+	other.PhysicalDriveStats[0].SerialNumber = "WD-OTHER"
+	d := newFixtureDiskStatus(t, ad, other)
+
+	tests := []struct {
+		ident   string
+		adapter int
+		slot    int
+		err     string
+	}{
+		{"32:3", 0, 3, ""},
+		{"[32:20]", 0, 20, ""},
+		{"a1[32:0]", 1, 0, ""},
+		{"32:0", 0, 0, "matches 2 physical drives: a0[32:0] a1[32:0]"},
+		{"ZC1000X", 0, 3, ""},
+		{"WD-OTHER", 1, 0, ""},
+		{"/dev/sdc", 0, 20, ""},
+		{"32:7", 0, 0, "no physical drive found for 32:7"},
+	}
+	for _, tt := range tests {
+		drive, err := d.FindPhysicalDrive(tt.ident)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("FindPhysicalDrive(%q) = %+v, %v, want %q", tt.ident, drive, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FindPhysicalDrive(%q): %v", tt.ident, err)
+			continue
+		}
+		if pd := drive.PhysicalDrives[0]; drive.AdapterId != tt.adapter || pd.SlotNumber != tt.slot {
+			t.Errorf("FindPhysicalDrive(%q) = a%d[%d:%d], want a%d[32:%d]", tt.ident, drive.AdapterId, pd.EnclosureDeviceId, pd.SlotNumber, tt.adapter, tt.slot)
+		}
+	}
+}
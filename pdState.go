@@ -0,0 +1,176 @@
+package diskutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pdTransition 描述一次PD状态变更：MegaCli参数、允许的当前状态，以及是否会让盘退出阵列
+type pdTransition struct {
+	name   string
+	option string
+	from   []string
+	// leaves 为true时检查VD剩余的冗余
+	leaves bool
+	// unmounted 为true时拒绝操作挂载中的JBOD
+	unmounted bool
+}
+
+var (
+	pdTransitionOnline = pdTransition{
+		name: "bring online", option: "-PDOnline", from: []string{"Offline", "Failed"},
+	}
+	pdTransitionOffline = pdTransition{
+		name: "take offline", option: "-PDOffline", from: []string{"Online"}, leaves: true,
+	}
+	pdTransitionMissing = pdTransition{
+		name: "mark missing", option: "-PDMarkMissing", from: []string{"Offline", "Failed"},
+	}
+	pdTransitionGood = pdTransition{
+		name: "make good", option: "-PDMakeGood", from: []string{"Unconfigured(bad)", "JBOD"}, unmounted: true,
+	}
+	pdTransitionJbod = pdTransition{
+		name: "make JBOD", option: "-PDMakeJBOD", from: []string{"Unconfigured(good)"},
+	}
+	pdTransitionPrepareRemoval = pdTransition{
+		name: "prepare for removal", option: "-PDPrpRmv",
+		from: []string{"Unconfigured(good)", "Unconfigured(bad)", "Offline", "Failed", "JBOD"}, unmounted: true,
+	}
+)
+
+// spanTolerance() 一个span最多允许几块盘不在线
+func spanTolerance(raidLevel string, spanMembers int) int {
+	switch normalizeRaidLevel(raidLevel) {
+	case "RAID1", "RAID10":
+		return spanMembers - 1
+	case "RAID5", "RAID50":
+		return 1
+	case "RAID6", "RAID60":
+		return 2
+	}
+	return 0
+}
+
+// 处于这些状态的盘一定是某个VD的成员
+var pdArrayStates = []string{"Online", "Rebuild"}
+
+// checkVdRedundancy() 假设pd离线后，检查它所在VD的同一span是否还在容忍范围内。
+// 阵列中的盘找不到所在的VD时无法判断，拒绝操作
+func checkVdRedundancy(ad *AdapterStat, pd *PhysicalDriveStat, action string) error {
+	matched := false
+	for i := range ad.VirtualDriveStats {
+		vd := &ad.VirtualDriveStats[i]
+		if !isVdMember(vd, pd) {
+			continue
+		}
+		matched = true
+		members := make([]PhysicalDriveStat, 0)
+		for j := range ad.PhysicalDriveStats {
+			if isVdMember(vd, &ad.PhysicalDriveStats[j]) && ad.PhysicalDriveStats[j].PdSpan == pd.PdSpan {
+				members = append(members, ad.PhysicalDriveStats[j])
+			}
+		}
+		down := 0
+		for _, member := range members {
+			same := member.EnclosureDeviceId == pd.EnclosureDeviceId && member.SlotNumber == pd.SlotNumber
+			if same || !strings.HasPrefix(member.FirmwareState, "Online") {
+				down++
+			}
+		}
+		if tolerance := spanTolerance(vd.RaidLevel, len(members)); down > tolerance {
+			return fmt.Errorf("can not %s [%d:%d]: VD %d (%s) would fail, span %s would have %d of %d drives down and tolerates %d",
+				action, pd.EnclosureDeviceId, pd.SlotNumber, vd.VirtualDrive, vd.RaidLevel, pd.PdSpan, down, len(members), tolerance)
+		}
+	}
+	if !matched {
+		for _, state := range pdArrayStates {
+			if strings.HasPrefix(pd.FirmwareState, state) {
+				return fmt.Errorf("can not %s [%d:%d]: it is %q but no VD was found for its disk group %q",
+					action, pd.EnclosureDeviceId, pd.SlotNumber, pd.FirmwareState, pd.PdDiskGroup)
+			}
+		}
+	}
+	return nil
+}
+
+// pdStateChange() 重新采集后检查当前状态和VD冗余，通过后才执行MegaCli命令
+func (d *DiskStatus) pdStateChange(adapter, enclosure, slot int, transition pdTransition) error {
+	if err := d.Get(); err != nil {
+		return err
+	}
+	var (
+		ad *AdapterStat
+		pd *PhysicalDriveStat
+	)
+	for i := range d.AdapterStats {
+		if d.AdapterStats[i].Backend != backendMegaCli || d.AdapterStats[i].AdapterId != adapter {
+			continue
+		}
+		ad = &d.AdapterStats[i]
+		for j := range ad.PhysicalDriveStats {
+			if ad.PhysicalDriveStats[j].EnclosureDeviceId == enclosure && ad.PhysicalDriveStats[j].SlotNumber == slot {
+				pd = &ad.PhysicalDriveStats[j]
+			}
+		}
+	}
+	if pd == nil {
+		return fmt.Errorf("no drive found in adapter %d [%d:%d]", adapter, enclosure, slot)
+	}
+
+	allowed := false
+	for _, state := range transition.from {
+		allowed = allowed || strings.HasPrefix(pd.FirmwareState, state)
+	}
+	if !allowed {
+		return fmt.Errorf("can not %s [%d:%d]: it is %q, one of %v required", transition.name, enclosure, slot, pd.FirmwareState, transition.from)
+	}
+	if transition.leaves {
+		if err := checkVdRedundancy(ad, pd, transition.name); err != nil {
+			return err
+		}
+	}
+	if transition.unmounted && pd.OsDevice != nil && pd.OsDevice.Usage != nil {
+		if mountPoints := pd.OsDevice.Usage.AllMountPoints(); len(mountPoints) > 0 {
+			return fmt.Errorf("can not %s [%d:%d]: it is mounted on %v", transition.name, enclosure, slot, mountPoints)
+		}
+	}
+	_, err := d.execMegaCli(fmt.Sprintf("%s %s -a%d -NoLog", transition.option, physDrv(enclosure, slot), adapter))
+	return err
+}
+
+// PDOnline() is used to bring an Offline or Failed PD of a MegaRaid adapter online
+// by "-PDOnline". The data on a failed drive may be stale, prefer a rebuild.
+func (d *DiskStatus) PDOnline(adapter, enclosure, slot int) error {
+	return d.pdStateChange(adapter, enclosure, slot, pdTransitionOnline)
+}
+
+// PDOffline() is used to take an Online PD of a MegaRaid adapter offline by
+// "-PDOffline". It is refused when the VD of the drive would fail, e.g. for the
+// last online drive of a RAID1.
+func (d *DiskStatus) PDOffline(adapter, enclosure, slot int) error {
+	return d.pdStateChange(adapter, enclosure, slot, pdTransitionOffline)
+}
+
+// PDMarkMissing() is used to mark an Offline or Failed PD of a MegaRaid adapter
+// missing by "-PDMarkMissing", before it is replaced.
+func (d *DiskStatus) PDMarkMissing(adapter, enclosure, slot int) error {
+	return d.pdStateChange(adapter, enclosure, slot, pdTransitionMissing)
+}
+
+// PDMakeGood() is used to make an Unconfigured(bad) or an unmounted JBOD PD of a
+// MegaRaid adapter Unconfigured(good) by "-PDMakeGood".
+func (d *DiskStatus) PDMakeGood(adapter, enclosure, slot int) error {
+	return d.pdStateChange(adapter, enclosure, slot, pdTransitionGood)
+}
+
+// PDMakeJBOD() is used to expose an Unconfigured(good) PD of a MegaRaid adapter
+// to the OS as JBOD by "-PDMakeJBOD".
+func (d *DiskStatus) PDMakeJBOD(adapter, enclosure, slot int) error {
+	return d.pdStateChange(adapter, enclosure, slot, pdTransitionJbod)
+}
+
+// PDPrepareRemoval() is used to spin down a PD of a MegaRaid adapter which is not
+// in use by a VD, or is already offline, by "-PDPrpRmv".
+func (d *DiskStatus) PDPrepareRemoval(adapter, enclosure, slot int) error {
+	return d.pdStateChange(adapter, enclosure, slot, pdTransitionPrepareRemoval)
+}
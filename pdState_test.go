@@ -0,0 +1,124 @@
+package diskutil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// newDryRunDiskStatus() 在fixture上执行，MegaCli命令只写到返回的buffer中
+func newDryRunDiskStatus(t *testing.T, ads ...AdapterStat) (*DiskStatus, *bytes.Buffer) {
+	d := newFixtureDiskStatus(t, ads...)
+	d.megacliPath = "MegaCli64"
+	out := new(bytes.Buffer)
+	d.EnableDryRun(out)
+	return d, out
+}
+
+func TestSpanTolerance(t *testing.T) {
+	tests := []struct {
+		raidLevel string
+		members   int
+		want      int
+	}{
+		{"RAID0", 4, 0},
+		{"RAID1", 2, 1},
+		{"RAID10", 2, 1},
+		{"RAID5", 4, 1},
+		{"RAID6", 6, 2},
+		{"RAID60", 6, 2},
+	}
+	for _, tt := range tests {
+		if tolerance := spanTolerance(tt.raidLevel, tt.members); tolerance != tt.want {
+			t.Errorf("spanTolerance(%q, %d) = %d, want %d", tt.raidLevel, tt.members, tolerance, tt.want)
+		}
+	}
+}
+
+func TestPDStateChange(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(ad *AdapterStat)
+		change  func(d *DiskStatus) error
+		command string
+		err     string
+	}{
+		{
+			name:    "offline a mirrored drive",
+			change:  func(d *DiskStatus) error { return d.PDOffline(0, 32, 2) },
+			command: "MegaCli64 -PDOffline -physdrv[32:2] -a0 -NoLog\n",
+		},
+		{
+			name: "offline the last online drive of a RAID1",
+			modify: func(ad *AdapterStat) {
+				ad.PhysicalDriveStats[3].FirmwareState = "Failed"
+			},
+			change: func(d *DiskStatus) error { return d.PDOffline(0, 32, 2) },
+			err:    "VD 129 (RAID1) would fail",
+		},
+		{
+			// VD 129的disk group未知时不能按VD序号猜测成员盘
+			name: "offline a drive without a known VD",
+			modify: func(ad *AdapterStat) {
+				ad.VirtualDriveStats[1].diskGroup = ""
+			},
+			change: func(d *DiskStatus) error { return d.PDOffline(0, 32, 2) },
+			err:    `no VD was found for its disk group "1"`,
+		},
+		{
+			name: "offline a rebuilding drive without a known VD",
+			modify: func(ad *AdapterStat) {
+				ad.PhysicalDriveStats[2].FirmwareState = "Rebuild"
+				ad.VirtualDriveStats[1].diskGroup = "7"
+			},
+			change: func(d *DiskStatus) error {
+				ad := &d.AdapterStats[0]
+				return checkVdRedundancy(ad, &ad.PhysicalDriveStats[2], "take offline")
+			},
+			err: "no VD was found",
+		},
+		{
+			name:   "offline a JBOD",
+			change: func(d *DiskStatus) error { return d.PDOffline(0, 32, 20) },
+			err:    `it is "JBOD", one of [Online] required`,
+		},
+		{
+			name:   "make a mounted JBOD good",
+			change: func(d *DiskStatus) error { return d.PDMakeGood(0, 32, 20) },
+			err:    "it is mounted on [/data/disk 1]",
+		},
+		{
+			name: "bring a failed drive online",
+			modify: func(ad *AdapterStat) {
+				ad.PhysicalDriveStats[3].FirmwareState = "Failed"
+			},
+			change:  func(d *DiskStatus) error { return d.PDOnline(0, 32, 3) },
+			command: "MegaCli64 -PDOnline -physdrv[32:3] -a0 -NoLog\n",
+		},
+		{
+			name:   "unknown drive",
+			change: func(d *DiskStatus) error { return d.PDMarkMissing(0, 32, 9) },
+			err:    "no drive found in adapter 0 [32:9]",
+		},
+	}
+	for _, tt := range tests {
+		ad := fixtureMegaCliAdapter(t)
+		if tt.modify != nil {
+			tt.modify(&ad)
+		}
+		d, out := newDryRunDiskStatus(t, ad)
+		if err := d.Get(); err != nil {
+			t.Fatal(err)
+		}
+		err := tt.change(d)
+		if tt.err == "" && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.err)
+		}
+		if out.String() != tt.command {
+			t.Errorf("%s: command = %q, want %q", tt.name, out.String(), tt.command)
+		}
+	}
+}
@@ -9,23 +9,35 @@ import (
 
 // PhysicalDriveStat is a struct to get the Physical Drive Stat of a RAID card.
 type PhysicalDriveStat struct {
-	EnclosureDeviceId      int    `json:"enclosure_device_id"`
-	DeviceId               int    `json:"device_id"`
-	SlotNumber             int    `json:"slot_number"`
-	MediaErrorCount        int    `json:"media_error_count"`
-	OtherErrorCount        int    `json:"other_error_count"`
-	PredictiveFailureCount int    `json:"predictive_failure_count"`
-	PdMediaType            string `json:"pd_media_type"`
-	PdType                 string `json:"pd_type"`
-	PdDiskGroup            string `json:"pd_disk_group"`
-	PdArm                  string `json:"pd_arm"`
-	RawSize                string `json:"raw_size"`
-	FirmwareState          string `json:"firmware_state"`
-	Brand                  string `json:"brand"`
-	Model                  string `json:"model"`
-	SerialNumber           string `json:"serial_number"`
-	DriveTemperature       string `json:"drive_emperature"`
-	OsPath                 string `json:"os_path"`
+	EnclosureDeviceId      int              `json:"enclosure_device_id"`
+	DeviceId               int              `json:"device_id"`
+	SlotNumber             int              `json:"slot_number"`
+	MediaErrorCount        int              `json:"media_error_count"`
+	OtherErrorCount        int              `json:"other_error_count"`
+	PredictiveFailureCount int              `json:"predictive_failure_count"`
+	PdMediaType            string           `json:"pd_media_type"`
+	PdType                 string           `json:"pd_type"`
+	PdDiskGroup            string           `json:"pd_disk_group"`
+	PdSpan                 string           `json:"pd_span,omitempty"`
+	PdArm                  string           `json:"pd_arm"`
+	RawSize                string           `json:"raw_size"`
+	FirmwareState          string           `json:"firmware_state"`
+	Brand                  string           `json:"brand"`
+	Model                  string           `json:"model"`
+	SerialNumber           string           `json:"serial_number"`
+	DriveTemperature       string           `json:"drive_emperature"`
+	OsPath                 string           `json:"os_path"`
+	OsDevice               *OSDevice        `json:"os_device,omitempty"`
+	Multipath              *MultipathDevice `json:"multipath,omitempty"`
+	Location               string           `json:"location,omitempty"`
+	SasAddress             string           `json:"sas_address,omitempty"`
+	Wwn                    string           `json:"wwn,omitempty"`
+	NvmeHealth             *NvmeHealthStat  `json:"nvme_health,omitempty"`
+	Smart                  *SmartStat       `json:"smart,omitempty"`
+	Progress               *ProgressStat    `json:"progress,omitempty"`
+	HotSpare               *HotSpareStat    `json:"hot_spare,omitempty"`
+	// ForeignState is "Foreign" when the drive carries a foreign configuration.
+	ForeignState string `json:"foreign_state,omitempty"`
 }
 
 // String() is used to get the print string.
@@ -142,16 +154,49 @@ func (p *PhysicalDriveStat) parseLine(line string) error {
 
 		parts := strings.Split(diskGroupStr.(string), ",")
 		diskGroup := strings.Split(parts[0], ":")[1]
-		//span := strings.Split(parts[1], ":")[1]
+		span := strings.Split(parts[1], ":")[1]
 		arm := strings.Split(parts[2], ":")[1]
 		p.PdDiskGroup = strings.TrimSpace(diskGroup)
+		p.PdSpan = strings.TrimSpace(span)
 		p.PdArm = strings.TrimSpace(arm)
+	} else if strings.HasPrefix(line, keyPdSasAddress) {
+		sasAddress, err := parseFiled(line, keyPdSasAddress, typeString)
+		if err != nil {
+			return err
+		}
+		p.SasAddress = normalizeSasAddress(sasAddress.(string))
+	} else if strings.HasPrefix(line, keyPdWwn) {
+		wwn, err := parseFiled(line, keyPdWwn, typeString)
+		if err != nil {
+			return err
+		}
+		p.Wwn = wwn.(string)
 	} else if strings.HasPrefix(line, keyPdDriveTemperature) {
 		driveTemperature, err := parseFiled(line, keyPdDriveTemperature, typeString)
 		if err != nil {
 			return err
 		}
 		p.DriveTemperature = driveTemperature.(string)
+	} else if strings.HasPrefix(line, keyPdHotSpareType+":") {
+		hotSpareType, err := parseFiled(line, keyPdHotSpareType, typeString)
+		if err != nil {
+			return err
+		}
+		p.HotSpare = parseMegaCliHotSpareType(hotSpareType.(string))
+	} else if strings.HasPrefix(line, keyPdHotSpareArray) && p.HotSpare != nil {
+		arrays, err := parseFiled(line, keyPdHotSpareArray, typeString)
+		if err != nil {
+			return err
+		}
+		p.HotSpare.Arrays = parseIntList(arrays.(string))
+	} else if strings.HasPrefix(line, keyPdForeignState) {
+		foreignState, err := parseFiled(line, keyPdForeignState, typeString)
+		if err != nil {
+			return err
+		}
+		if foreignState.(string) != "None" {
+			p.ForeignState = foreignState.(string)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,148 @@
+package diskutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	progressRebuild                  string = "Rebuild"
+	progressCopyback                 string = "Copyback"
+	progressInitialization           string = "Initialization"
+	progressBackgroundInitialization string = "Background Initialization"
+	progressConsistencyCheck         string = "Consistency Check"
+
+	defaultRebuildPollInterval time.Duration = 30 * time.Second
+)
+
+var (
+	// "Rebuild Progress on Device at Enclosure 32, Slot 4 Completed 41% in 23 Minutes."
+	progressCompletedRegex = regexp.MustCompile(`Completed (\d+)% in (\d+) (Seconds?|Minutes?|Hours?)`)
+	// "Background Initialization on VD #0 (target id #0) Completed 7% in 2 Minutes."
+	// "Check Consistency on VD #0 (target id #0) Completed 5% in 3 Minutes."
+	progressVdRegex = regexp.MustCompile(`on VD #(\d+)`)
+)
+
+// ProgressStat is a struct to get the progress of a long running operation
+// of a PD (rebuild, copyback) or a VD (initialization, background initialization,
+// consistency check). CorrectedErrors is only set for a consistency check.
+type ProgressStat struct {
+	Operation        string `json:"operation"`
+	PercentComplete  int    `json:"percent_complete"`
+	ElapsedSeconds   int    `json:"elapsed_seconds"`
+	RemainingSeconds int    `json:"remaining_seconds"`
+	CorrectedErrors  int    `json:"corrected_errors,omitempty"`
+}
+
+// parseProgress() 解析一行进度，剩余时间按已用时间线性估算，0%时为-1
+func parseProgress(operation, line string) (*ProgressStat, bool) {
+	matches := progressCompletedRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, false
+	}
+	progress := &ProgressStat{Operation: operation, RemainingSeconds: -1}
+	progress.PercentComplete, _ = strconv.Atoi(matches[1])
+	elapsed, _ := strconv.Atoi(matches[2])
+	switch {
+	case strings.HasPrefix(matches[3], "Minute"):
+		elapsed *= 60
+	case strings.HasPrefix(matches[3], "Hour"):
+		elapsed *= 3600
+	}
+	progress.ElapsedSeconds = elapsed
+	if progress.PercentComplete > 0 {
+		progress.RemainingSeconds = elapsed * (100 - progress.PercentComplete) / progress.PercentComplete
+	}
+	return progress, true
+}
+
+// getMegaRaidProgress() 获取重建/回拷中的PD和初始化/一致性检查中的VD的进度
+func (a *AdapterStat) getMegaRaidProgress(command string) {
+	for i := range a.PhysicalDriveStats {
+		pd := &a.PhysicalDriveStats[i]
+		var operation, args string
+		switch {
+		case strings.HasPrefix(pd.FirmwareState, progressRebuild):
+			operation, args = progressRebuild, "-PDRbld -ShowProg"
+		case strings.HasPrefix(pd.FirmwareState, progressCopyback):
+			operation, args = progressCopyback, "-PDCpyBk -ShowProg"
+		default:
+			continue
+		}
+		output := queryMegaCliIgnoreExit(command, fmt.Sprintf("%s %s -a%d -NoLog", args, physDrv(pd.EnclosureDeviceId, pd.SlotNumber), a.AdapterId))
+		if progress, ok := parseProgress(operation, output); ok {
+			pd.Progress = progress
+		}
+	}
+
+	if len(a.VirtualDriveStats) == 0 {
+		return
+	}
+	for _, op := range []struct{ operation, args string }{
+		{progressInitialization, "-LDInit -ShowProg"},
+		{progressBackgroundInitialization, "-LDBI -ShowProg"},
+		{progressConsistencyCheck, "-LDCC -ShowProg"},
+	} {
+		output := queryMegaCliIgnoreExit(command, fmt.Sprintf("%s -LALL -a%d -NoLog", op.args, a.AdapterId))
+		for _, line := range strings.Split(output, "\n") {
+			matches := progressVdRegex.FindStringSubmatch(line)
+			if matches == nil {
+				continue
+			}
+			progress, ok := parseProgress(op.operation, line)
+			if !ok {
+				continue
+			}
+			vdId, _ := strconv.Atoi(matches[1])
+			for i := range a.VirtualDriveStats {
+				if a.VirtualDriveStats[i].VirtualDrive == vdId {
+					a.VirtualDriveStats[i].Progress = progress
+				}
+			}
+		}
+	}
+}
+
+// WaitForRebuild() is used to poll the PDs every interval until no drive is in
+// rebuild or copyback, a zero interval polls every 30 seconds. It returns an
+// error when a drive fails during the rebuild or ctx is done.
+func (d *DiskStatus) WaitForRebuild(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		interval = defaultRebuildPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	rebuilding := make(map[string]bool)
+	for {
+		if err := d.GetPhysicalDrive(); err != nil {
+			return err
+		}
+		busy := false
+		for _, ad := range d.AdapterStats {
+			for _, pd := range ad.PhysicalDriveStats {
+				key := fmt.Sprintf("%s-%d[%d:%d]", ad.Backend, ad.AdapterId, pd.EnclosureDeviceId, pd.SlotNumber)
+				state := pd.FirmwareState
+				switch {
+				case strings.HasPrefix(state, progressRebuild), strings.HasPrefix(state, progressCopyback):
+					busy = true
+					rebuilding[key] = true
+				case rebuilding[key] && (strings.HasPrefix(state, "Failed") || strings.HasPrefix(state, "Unconfigured(bad)")):
+					return errors.New("rebuild failed on " + key + ": " + state)
+				}
+			}
+		}
+		if !busy {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package diskutil
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseProgress(t *testing.T) {
+	tests := []struct {
+		operation string
+		line      string
+		want      *ProgressStat
+	}{
+		{progressRebuild, "Rebuild Progress on Device at Enclosure 32, Slot 4 Completed 41% in 23 Minutes.",
+			&ProgressStat{Operation: progressRebuild, PercentComplete: 41, ElapsedSeconds: 1380, RemainingSeconds: 1985}},
+		{progressCopyback, "Copyback Progress on Device at Enclosure 32, Slot 6 Completed 80% in 40 Seconds.",
+			&ProgressStat{Operation: progressCopyback, PercentComplete: 80, ElapsedSeconds: 40, RemainingSeconds: 10}},
+		{progressInitialization, "Initialization on VD #1 (target id #1) Completed 0% in 0 Seconds.",
+			&ProgressStat{Operation: progressInitialization, RemainingSeconds: -1}},
+		{progressBackgroundInitialization, "Background Initialization on VD #0 (target id #0) Completed 50% in 1 Hour.",
+			&ProgressStat{Operation: progressBackgroundInitialization, PercentComplete: 50, ElapsedSeconds: 3600, RemainingSeconds: 3600}},
+		{progressRebuild, "Device(Encl-32 Slot-4) is not in rebuild process", nil},
+	}
+	for _, tt := range tests {
+		progress, ok := parseProgress(tt.operation, tt.line)
+		if ok != (tt.want != nil) || (ok && *progress != *tt.want) {
+			t.Errorf("parseProgress(%q) = %+v, %v, want %+v", tt.line, progress, ok, tt.want)
+		}
+	}
+}
+
+// rebuildBackend 每次采集返回下一个状态，最后一个状态一直保持
+type rebuildBackend struct {
+	fixedBackend
+	states []string
+	polls  int
+}
+
+func (r *rebuildBackend) GetPhysicalDrive() ([]AdapterStat, error) {
+	state := r.states[len(r.states)-1]
+	if r.polls < len(r.states) {
+		state = r.states[r.polls]
+	}
+	r.polls++
+	r.ads[0].PhysicalDriveStats[3].FirmwareState = state
+	return r.Get()
+}
+
+func TestWaitForRebuild(t *testing.T) {
+	tests := []struct {
+		states []string
+		polls  int
+		err    string
+	}{
+		{[]string{"Rebuild", "Rebuild", "Online, Spun Up"}, 3, ""},
+		{[]string{"Online, Spun Up"}, 1, ""},
+		{[]string{"Rebuild", "Failed"}, 2, "rebuild failed on megacli-0[32:3]: Failed"},
+	}
+	for _, tt := range tests {
+		backend := &rebuildBackend{fixedBackend: fixedBackend{ads: []AdapterStat{fixtureMegaCliAdapter(t)}}, states: tt.states}
+		d, err := NewDiskStatusWithBackends(backend)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = d.WaitForRebuild(context.Background(), time.Millisecond)
+		if (tt.err == "" && err != nil) || (tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err))) {
+			t.Errorf("%v: WaitForRebuild() = %v, want %q", tt.states, err, tt.err)
+		}
+		if backend.polls != tt.polls {
+			t.Errorf("%v: polled %d times, want %d", tt.states, backend.polls, tt.polls)
+		}
+	}
+
+	// 一直在重建时ctx结束后返回
+	backend := &rebuildBackend{fixedBackend: fixedBackend{ads: []AdapterStat{fixtureMegaCliAdapter(t)}}, states: []string{"Rebuild"}}
+	d, err := NewDiskStatusWithBackends(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := d.WaitForRebuild(ctx, time.Millisecond); err != context.DeadlineExceeded {
+		t.Errorf("WaitForRebuild() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,335 @@
+package diskutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultReplacePollInterval time.Duration = 10 * time.Second
+
+// RawSize 去掉了扇区数，只剩 "558.911 GB" 这样的容量
+var rawSizeRegex = regexp.MustCompile(`^([0-9.]+)\s*([KMGTP]?B)`)
+
+var rawSizeUnits = map[string]float64{
+	"B":  1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+	"TB": 1 << 40,
+	"PB": 1 << 50,
+}
+
+// ReplaceStep is one step of a ReplacePlan. Command is the MegaCli command line
+// which the step runs, it is empty for the checks and the waits. A Cleanup step,
+// e.g. stopping the locate LED, also runs when an earlier step failed.
+type ReplaceStep struct {
+	Description string `json:"description"`
+	Command     string `json:"command,omitempty"`
+	Cleanup     bool   `json:"cleanup,omitempty"`
+	run         func(ctx context.Context) error
+}
+
+// ReplacePlan is a struct to get the steps to replace a failed PD of a MegaRaid adapter.
+// Print the Steps for a dry-run, or pass the plan to Replace() to run it.
+type ReplacePlan struct {
+	AdapterId         int               `json:"adapter_id"`
+	EnclosureDeviceId int               `json:"enclosure_device_id"`
+	SlotNumber        int               `json:"slot_number"`
+	Drive             PhysicalDriveStat `json:"drive"`
+	Steps             []ReplaceStep     `json:"steps"`
+	// PollInterval is how often the new drive and the rebuild are checked.
+	PollInterval time.Duration `json:"poll_interval"`
+}
+
+// 可以更换的盘：故障、离线、坏盘，以及有预测性故障的在线盘
+func replaceable(pd *PhysicalDriveStat) error {
+	state := pd.FirmwareState
+	switch {
+	case strings.HasPrefix(state, "Failed"), strings.HasPrefix(state, "Offline"), strings.HasPrefix(state, "Unconfigured(bad)"):
+		return nil
+	case strings.HasPrefix(state, "Online") && pd.PredictiveFailureCount > 0:
+		return nil
+	}
+	return fmt.Errorf("drive [%d:%d] is %q, only Failed, Offline, Unconfigured(bad) or predictive failure drives can be replaced",
+		pd.EnclosureDeviceId, pd.SlotNumber, state)
+}
+
+func rawSizeBytes(rawSize string) (float64, bool) {
+	matches := rawSizeRegex.FindStringSubmatch(strings.TrimSpace(rawSize))
+	if matches == nil {
+		return 0, false
+	}
+	size, err := strconv.ParseFloat(matches[1], 64)
+	return size * rawSizeUnits[matches[2]], err == nil
+}
+
+// 新盘的容量不能小于旧盘，接口和介质类型要一致
+func replaceCompatible(oldPd, newPd *PhysicalDriveStat) error {
+	oldSize, ok1 := rawSizeBytes(oldPd.RawSize)
+	newSize, ok2 := rawSizeBytes(newPd.RawSize)
+	if ok1 && ok2 && newSize < oldSize {
+		return fmt.Errorf("new drive is smaller than the old one: %s < %s", newPd.RawSize, oldPd.RawSize)
+	}
+	if oldPd.PdType != "" && newPd.PdType != oldPd.PdType {
+		return fmt.Errorf("new drive is %s, the old one is %s", newPd.PdType, oldPd.PdType)
+	}
+	if oldPd.PdMediaType != "" && newPd.PdMediaType != oldPd.PdMediaType {
+		return fmt.Errorf("new drive is %s, the old one is %s", newPd.PdMediaType, oldPd.PdMediaType)
+	}
+	return nil
+}
+
+// physicalDriveAt() 重新采集PD，返回adapter上 E:S 位置的盘
+func (d *DiskStatus) physicalDriveAt(adapter, enclosure, slot int) (*PhysicalDriveStat, error) {
+	if err := d.GetPhysicalDrive(); err != nil {
+		return nil, err
+	}
+	for _, ad := range d.AdapterStats {
+		if ad.Backend != backendMegaCli || ad.AdapterId != adapter {
+			continue
+		}
+		for _, pd := range ad.PhysicalDriveStats {
+			if pd.EnclosureDeviceId == enclosure && pd.SlotNumber == slot {
+				return &pd, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// megaCliDriveAt() 重新采集VD和PD，返回MegaRaid adapter和它上面 E:S 位置的盘，
+// 检查VD冗余需要完整的VD信息，所以不能只采集PD
+func (d *DiskStatus) megaCliDriveAt(adapter, enclosure, slot int) (*AdapterStat, *PhysicalDriveStat, error) {
+	if err := d.Get(); err != nil {
+		return nil, nil, err
+	}
+	for i := range d.AdapterStats {
+		ad := &d.AdapterStats[i]
+		if ad.Backend != backendMegaCli || ad.AdapterId != adapter {
+			continue
+		}
+		for j := range ad.PhysicalDriveStats {
+			if ad.PhysicalDriveStats[j].EnclosureDeviceId == enclosure && ad.PhysicalDriveStats[j].SlotNumber == slot {
+				return ad, &ad.PhysicalDriveStats[j], nil
+			}
+		}
+	}
+	return nil, nil, nil
+}
+
+// checkReplaceable() 检查盘可以更换，在线的盘要先离线，同时检查VD的冗余
+func checkReplaceable(ad *AdapterStat, pd *PhysicalDriveStat) error {
+	if err := replaceable(pd); err != nil {
+		return err
+	}
+	if strings.HasPrefix(pd.FirmwareState, "Online") {
+		return checkVdRedundancy(ad, pd, "replace")
+	}
+	return nil
+}
+
+// waitPhysicalDrive() 每隔interval检查一次 E:S 位置的盘，直到done返回true
+func (d *DiskStatus) waitPhysicalDrive(ctx context.Context, interval time.Duration, adapter, enclosure, slot int,
+	done func(pd *PhysicalDriveStat) (bool, error)) (*PhysicalDriveStat, error) {
+	// dry-run时不会有盘的变化，直接返回当前的盘
+	if d.dryRun != nil {
+		return d.physicalDriveAt(adapter, enclosure, slot)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		pd, err := d.physicalDriveAt(adapter, enclosure, slot)
+		if err != nil {
+			return nil, err
+		}
+		if ok, err := done(pd); err != nil || ok {
+			return pd, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// PlanReplace() is used to verify the state of the PD in the enclosure and slot of
+// a MegaRaid adapter and build the steps to replace it: mark it offline and missing,
+// prepare it for removal, blink its LED, wait for the new drive in the same slot,
+// check the size and media type, and start and monitor the rebuild. An Online drive
+// with predictive failures is refused when its VD would fail without it, and this
+// is checked again when the plan runs.
+func (d *DiskStatus) PlanReplace(adapter, enclosure, slot int) (*ReplacePlan, error) {
+	if d.megacliPath == "" {
+		return nil, errors.New("megaCli backend required")
+	}
+	ad, oldPd, err := d.megaCliDriveAt(adapter, enclosure, slot)
+	if err != nil {
+		return nil, err
+	}
+	if oldPd == nil {
+		return nil, fmt.Errorf("no drive found in adapter %d [%d:%d]", adapter, enclosure, slot)
+	}
+	if err := checkReplaceable(ad, oldPd); err != nil {
+		return nil, err
+	}
+
+	plan := &ReplacePlan{
+		AdapterId:         adapter,
+		EnclosureDeviceId: enclosure,
+		SlotNumber:        slot,
+		Drive:             *oldPd,
+		PollInterval:      defaultReplacePollInterval,
+	}
+	drive := physDrv(enclosure, slot)
+	command := func(description, args string) ReplaceStep {
+		return ReplaceStep{
+			Description: description,
+			Command:     d.megacliPath + " " + args,
+			run: func(ctx context.Context) error {
+				_, err := d.execMegaCli(args)
+				return err
+			},
+		}
+	}
+	var (
+		newPd *PhysicalDriveStat
+		// 只有LED已经开始闪烁，出错后才需要停止
+		locating bool
+	)
+	startLocate := command("blink the locate LED", fmt.Sprintf("-PdLocate -start %s -a%d -NoLog", drive, adapter))
+	startRun := startLocate.run
+	startLocate.run = func(ctx context.Context) error {
+		err := startRun(ctx)
+		locating = err == nil
+		return err
+	}
+	stopLocate := command("stop the locate LED", fmt.Sprintf("-PdLocate -stop %s -a%d -NoLog", drive, adapter))
+	stopLocate.Cleanup = true
+	stopRun := stopLocate.run
+	stopLocate.run = func(ctx context.Context) error {
+		if !locating {
+			return nil
+		}
+		locating = false
+		return stopRun(ctx)
+	}
+
+	plan.Steps = append(plan.Steps, ReplaceStep{
+		Description: fmt.Sprintf("check the drive is still %s in state %q and its VD can lose it", oldPd.SerialNumber, oldPd.FirmwareState),
+		run: func(ctx context.Context) error {
+			ad, pd, err := d.megaCliDriveAt(adapter, enclosure, slot)
+			if err != nil {
+				return err
+			}
+			if pd == nil || pd.SerialNumber != oldPd.SerialNumber || pd.FirmwareState != oldPd.FirmwareState {
+				return errors.New("the drive has changed since the plan was made")
+			}
+			// 做计划之后VD中的其他盘可能已经故障
+			return checkReplaceable(ad, pd)
+		},
+	})
+	inArray := oldPd.PdDiskGroup != "" && oldPd.PdArm != ""
+	if strings.HasPrefix(oldPd.FirmwareState, "Online") {
+		plan.Steps = append(plan.Steps, command("mark the drive offline",
+			fmt.Sprintf("-PDOffline %s -a%d -NoLog", drive, adapter)))
+	}
+	if inArray {
+		plan.Steps = append(plan.Steps, command("mark the drive missing",
+			fmt.Sprintf("-PDMarkMissing %s -a%d -NoLog", drive, adapter)))
+	}
+	plan.Steps = append(plan.Steps,
+		command("prepare the drive for removal", fmt.Sprintf("-PDPrpRmv %s -a%d -NoLog", drive, adapter)),
+		startLocate,
+		ReplaceStep{
+			Description: fmt.Sprintf("wait for a new drive in [%d:%d] and check its size and media type", enclosure, slot),
+			run: func(ctx context.Context) error {
+				pd, err := d.waitPhysicalDrive(ctx, plan.PollInterval, adapter, enclosure, slot, func(pd *PhysicalDriveStat) (bool, error) {
+					return pd != nil && pd.SerialNumber != oldPd.SerialNumber, nil
+				})
+				if err != nil {
+					return err
+				}
+				newPd = pd
+				return replaceCompatible(oldPd, newPd)
+			},
+		},
+		stopLocate,
+	)
+	if !inArray {
+		return plan, nil
+	}
+
+	// 开启了自动重建的卡，新盘插入后直接进入Rebuild状态
+	autoRebuild := func() bool {
+		return newPd != nil && strings.HasPrefix(newPd.FirmwareState, "Rebuild")
+	}
+	replaceMissing := command("put the new drive into the array, unless the controller rebuilds it by itself",
+		fmt.Sprintf("-PdReplaceMissing %s -Array%s -row%s -a%d -NoLog", drive, oldPd.PdDiskGroup, oldPd.PdArm, adapter))
+	startRebuild := command("start the rebuild, unless the controller rebuilds it by itself",
+		fmt.Sprintf("-PDRbld -Start %s -a%d -NoLog", drive, adapter))
+	for _, step := range []ReplaceStep{replaceMissing, startRebuild} {
+		run := step.run
+		step.run = func(ctx context.Context) error {
+			if autoRebuild() {
+				return nil
+			}
+			return run(ctx)
+		}
+		plan.Steps = append(plan.Steps, step)
+	}
+	plan.Steps = append(plan.Steps, ReplaceStep{
+		Description: "wait for the rebuild to finish",
+		run: func(ctx context.Context) error {
+			_, err := d.waitPhysicalDrive(ctx, plan.PollInterval, adapter, enclosure, slot, func(pd *PhysicalDriveStat) (bool, error) {
+				if pd == nil {
+					return false, errors.New("the new drive disappeared")
+				}
+				if strings.HasPrefix(pd.FirmwareState, "Failed") || strings.HasPrefix(pd.FirmwareState, "Unconfigured(bad)") {
+					return false, errors.New("the rebuild failed: " + pd.FirmwareState)
+				}
+				return strings.HasPrefix(pd.FirmwareState, "Online"), nil
+			})
+			return err
+		},
+	})
+	return plan, nil
+}
+
+// Replace() is used to run the steps of a ReplacePlan in order. progress is called
+// before every step and can be nil. It stops at the first failed step, and then
+// still runs the Cleanup steps after it, so the locate LED does not keep blinking
+// after a timeout, a cancelled ctx or an incompatible new drive.
+func (d *DiskStatus) Replace(ctx context.Context, plan *ReplacePlan, progress func(step ReplaceStep)) error {
+	for i, step := range plan.Steps {
+		if progress != nil {
+			progress(step)
+		}
+		if err := step.run(ctx); err != nil {
+			err = fmt.Errorf("step %d (%s) failed: %w", i+1, step.Description, err)
+			return d.replaceCleanup(plan.Steps[i+1:], progress, err)
+		}
+	}
+	return nil
+}
+
+// replaceCleanup() 失败后执行剩下的Cleanup步骤，ctx可能已经取消，所以不再使用
+func (d *DiskStatus) replaceCleanup(steps []ReplaceStep, progress func(step ReplaceStep), err error) error {
+	for _, step := range steps {
+		if !step.Cleanup {
+			continue
+		}
+		if progress != nil {
+			progress(step)
+		}
+		if cleanupErr := step.run(context.Background()); cleanupErr != nil {
+			err = fmt.Errorf("%w, and cleanup (%s) failed: %v", err, step.Description, cleanupErr)
+		}
+	}
+	return err
+}
@@ -0,0 +1,113 @@
+package diskutil
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPlanReplaceFailedDrive(t *testing.T) {
+	ad := fixtureMegaCliAdapter(t)
+	ad.PhysicalDriveStats[3].FirmwareState = "Failed"
+	d, out := newDryRunDiskStatus(t, ad)
+
+	plan, err := d.PlanReplace(0, 32, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"",
+		"MegaCli64 -PDMarkMissing -physdrv[32:3] -a0 -NoLog",
+		"MegaCli64 -PDPrpRmv -physdrv[32:3] -a0 -NoLog",
+		"MegaCli64 -PdLocate -start -physdrv[32:3] -a0 -NoLog",
+		"",
+		"MegaCli64 -PdLocate -stop -physdrv[32:3] -a0 -NoLog",
+		// disk group 1 是VD 129所在的Array
+		"MegaCli64 -PdReplaceMissing -physdrv[32:3] -Array1 -row1 -a0 -NoLog",
+		"MegaCli64 -PDRbld -Start -physdrv[32:3] -a0 -NoLog",
+		"",
+	}
+	if len(plan.Steps) != len(want) {
+		t.Fatalf("steps = %+v", plan.Steps)
+	}
+	commands := make([]string, 0)
+	for i, step := range plan.Steps {
+		if step.Command != want[i] {
+			t.Errorf("step %d = %q, want %q", i+1, step.Command, want[i])
+		}
+		if step.Command != "" {
+			commands = append(commands, step.Command)
+		}
+	}
+
+	if err := d.Replace(context.Background(), plan, nil); err != nil {
+		t.Fatal(err)
+	}
+	if printed := strings.Join(commands, "\n") + "\n"; out.String() != printed {
+		t.Errorf("dry-run printed\n%s\nwant\n%s", out.String(), printed)
+	}
+}
+
+func TestPlanReplaceOnlineDrive(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(ad *AdapterStat)
+		err    string
+	}{
+		{
+			name: "mirror is failed",
+			modify: func(ad *AdapterStat) {
+				ad.PhysicalDriveStats[3].FirmwareState = "Failed"
+			},
+			err: "can not replace [32:2]: VD 129 (RAID1) would fail",
+		},
+		{
+			name: "VD is unknown",
+			modify: func(ad *AdapterStat) {
+				ad.VirtualDriveStats[1].diskGroup = ""
+			},
+			err: `can not replace [32:2]: it is "Online, Spun Up" but no VD was found`,
+		},
+		{
+			name: "no predictive failure",
+			modify: func(ad *AdapterStat) {
+				ad.PhysicalDriveStats[2].PredictiveFailureCount = 0
+			},
+			err: "only Failed, Offline, Unconfigured(bad) or predictive failure drives can be replaced",
+		},
+	}
+	for _, tt := range tests {
+		ad := fixtureMegaCliAdapter(t)
+		ad.PhysicalDriveStats[2].PredictiveFailureCount = 3
+		tt.modify(&ad)
+		d, _ := newDryRunDiskStatus(t, ad)
+		if plan, err := d.PlanReplace(0, 32, 2); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: PlanReplace() = %+v, %v, want %q", tt.name, plan, err, tt.err)
+		}
+	}
+}
+
+func TestReplaceRechecksRedundancy(t *testing.T) {
+	ad := fixtureMegaCliAdapter(t)
+	ad.PhysicalDriveStats[2].PredictiveFailureCount = 3
+	d, out := newDryRunDiskStatus(t, ad)
+
+	plan, err := d.PlanReplace(0, 32, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Steps[1].Command != "MegaCli64 -PDOffline -physdrv[32:2] -a0 -NoLog" {
+		t.Errorf("step 2 = %q, want -PDOffline", plan.Steps[1].Command)
+	}
+
+	// 做计划之后另一块镜像盘故障了
+	backend := d.backends[0].(*fixedBackend)
+	backend.ads[0].PhysicalDriveStats[3].FirmwareState = "Failed"
+	err = d.Replace(context.Background(), plan, nil)
+	if err == nil || !strings.Contains(err.Error(), "step 1") || !strings.Contains(err.Error(), "VD 129 (RAID1) would fail") {
+		t.Errorf("Replace() = %v, want the first step to refuse", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("commands were run: %q", out.String())
+	}
+}
@@ -0,0 +1,298 @@
+package diskutil
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	keyIrcuVolume       string = "IR volume "
+	keyIrcuDevicePrefix string = "Device is a "
+	keyIrcuHardDisk     string = "Device is a Hard disk"
+	keyIrcuSectionIR    string = "ir volume information"
+	keyIrcuSectionPd    string = "physical device information"
+)
+
+var (
+	// "   0     SAS3008     1000h    97h   00h:03h:00h:00h      1028h   1f45h"
+	ircuAdapterRegex = regexp.MustCompile(`^\s*(\d+)\s+(\S+)\s+\S+h\s+\S+h\s+(\S+)`)
+	// "PHY[0] Enclosure#/Slot#                 : 2:0"
+	ircuPhyRegex = regexp.MustCompile(`^PHY\[(\d+)\] Enclosure#/Slot#\s*:\s*(\d+):(\d+)`)
+	ircuPdStates = map[string]string{
+		"OPT":  "Online, Spun Up",
+		"RDY":  "JBOD",
+		"HSP":  "Hotspare, Spun Up",
+		"SBY":  "Unconfigured(good), Spun Up",
+		"RBLD": "Rebuild",
+		"FLD":  "Failed",
+		"MIS":  "Missing",
+		"DGD":  "Degraded",
+		"OSY":  "Out of Sync",
+	}
+	ircuVdStates = map[string]string{
+		"OKY":  "Optimal",
+		"DGD":  "Degraded",
+		"FLD":  "Failed",
+		"MIS":  "Missing",
+		"INIT": "Initializing",
+		"ONL":  "Online",
+	}
+)
+
+// SasIrcuBackend is a Backend which uses sas2ircu or sas3ircu to get the stat of
+// LSI HBA cards (IT or IR mode). In IT mode every drive is exposed to the OS,
+// so the OS device of each drive is resolved by its SAS address in sysfs.
+type SasIrcuBackend struct {
+	ircuPath string
+	resolver *osDeviceResolver
+}
+
+// NewSasIrcuBackend() use the ircuPath and sysRoot to build a SasIrcuBackend.
+// ircuPath can be a sas2ircu or sas3ircu binary, an empty sysRoot means /sys.
+func NewSasIrcuBackend(ircuPath string, sysRoot string) (*SasIrcuBackend, error) {
+	ircuPath = path.Clean(ircuPath)
+	if !fileExist(ircuPath) {
+		return nil, errors.New("sasircu not exist")
+	}
+	return &SasIrcuBackend{
+		ircuPath: ircuPath,
+		resolver: newOsDeviceResolver(sysRoot, ""),
+	}, nil
+}
+
+// SetSysfsRoot() is used to change the sysfs and /dev roots used to map
+// the drives to the OS block devices, which is useful for tests.
+func (s *SasIrcuBackend) SetSysfsRoot(sysRoot, devRoot string) {
+	s.resolver = newOsDeviceResolver(sysRoot, devRoot)
+}
+
+// Name() is used to get the name of the backend.
+func (s *SasIrcuBackend) Name() string {
+	return backendSasIrcu
+}
+
+// Get() is used to get all the AdapterStats of the backend.
+func (s *SasIrcuBackend) Get() ([]AdapterStat, error) {
+	output, err := execCmd(s.ircuPath, "LIST")
+	if err != nil {
+		return nil, err
+	}
+	adapters := parseSasIrcuList(output)
+
+	ads := make([]AdapterStat, 0, len(adapters))
+	for _, adapterId := range adapters {
+		output, err := execCmd(s.ircuPath, fmt.Sprintf("%d DISPLAY", adapterId))
+		if err != nil {
+			return nil, err
+		}
+		ad, err := parseSasIrcuDisplay(output, adapterId)
+		if err != nil {
+			return nil, err
+		}
+		for i := range ad.PhysicalDriveStats {
+			pd := &ad.PhysicalDriveStats[i]
+			if pd.SasAddress != "" {
+				if osPath, ok := s.resolver.blockBySasAddress(pd.SasAddress); ok {
+					pd.OsPath = osPath
+				}
+			}
+		}
+		ads = append(ads, ad)
+	}
+	return ads, nil
+}
+
+// GetVirtualDrive() is used to get the AdapterStats with VirtualDriveStats only.
+func (s *SasIrcuBackend) GetVirtualDrive() ([]AdapterStat, error) {
+	ads, err := s.Get()
+	if err != nil {
+		return nil, err
+	}
+	for i := range ads {
+		ads[i].PhysicalDriveStats = nil
+	}
+	return ads, nil
+}
+
+// GetPhysicalDrive() is used to get the AdapterStats with PhysicalDriveStats only.
+func (s *SasIrcuBackend) GetPhysicalDrive() ([]AdapterStat, error) {
+	ads, err := s.Get()
+	if err != nil {
+		return nil, err
+	}
+	for i := range ads {
+		ads[i].VirtualDriveStats = nil
+	}
+	return ads, nil
+}
+
+func parseSasIrcuList(output string) []int {
+	adapters := make([]int, 0)
+	for _, line := range strings.Split(output, "\n") {
+		matches := ircuAdapterRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		if adapterId, err := strconv.Atoi(matches[1]); err == nil {
+			adapters = append(adapters, adapterId)
+		}
+	}
+	return adapters
+}
+
+// "Ready (RDY)" 取括号中的缩写
+func ircuState(states map[string]string, value string) string {
+	start := strings.LastIndex(value, "(")
+	end := strings.LastIndex(value, ")")
+	if start >= 0 && end > start {
+		if state, ok := states[value[start+1:end]]; ok {
+			return state
+		}
+		return strings.TrimSpace(value[:start])
+	}
+	return value
+}
+
+func parseSasIrcuDisplay(output string, adapterId int) (AdapterStat, error) {
+	ad := AdapterStat{
+		AdapterId:          adapterId,
+		Backend:            backendSasIrcu,
+		VirtualDriveStats:  make([]VirtualDriveStat, 0),
+		PhysicalDriveStats: make([]PhysicalDriveStat, 0),
+	}
+	if strings.TrimSpace(output) == "" {
+		return ad, errors.New("sasircu display info nil")
+	}
+
+	var (
+		section string
+		vd      *VirtualDriveStat
+		pd      *PhysicalDriveStat
+		// 成员盘所在的volume和PHY序号，key为 enclosure:slot
+		members = make(map[string][2]string)
+	)
+	flush := func() {
+		if vd != nil {
+			ad.VirtualDriveStats = append(ad.VirtualDriveStats, *vd)
+		}
+		if pd != nil {
+			pd.DeviceId = len(ad.PhysicalDriveStats)
+			ad.PhysicalDriveStats = append(ad.PhysicalDriveStats, *pd)
+		}
+		vd = nil
+		pd = nil
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "---") {
+			continue
+		}
+		if line == trimmed && strings.HasSuffix(strings.ToLower(trimmed), " information") {
+			flush()
+			section = strings.ToLower(trimmed)
+			continue
+		}
+
+		switch section {
+		case keyIrcuSectionIR:
+			if strings.HasPrefix(trimmed, keyIrcuVolume) {
+				flush()
+				vdId, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(trimmed, keyIrcuVolume)))
+				if err != nil {
+					return ad, errors.New("format illegal: " + trimmed)
+				}
+				vd = &VirtualDriveStat{VirtualDrive: vdId, Encryptiontype: "None", OsPath: "Unknown", diskGroup: strconv.Itoa(vdId)}
+				continue
+			}
+			if vd == nil {
+				continue
+			}
+			if matches := ircuPhyRegex.FindStringSubmatch(trimmed); matches != nil {
+				members[matches[2]+":"+matches[3]] = [2]string{vd.diskGroup, matches[1]}
+				vd.NumberOfDrives++
+				continue
+			}
+			key, value, ok := splitSpacedField(trimmed)
+			if !ok {
+				continue
+			}
+			switch key {
+			case "Status of volume":
+				vd.State = ircuState(ircuVdStates, value)
+			case "Size (in MB)":
+				vd.Size = value + " MB"
+			case "Volume Name":
+				vd.Name = value
+			case "RAID level":
+				vd.RaidLevel = normalizeRaidLevel(value)
+			}
+		case keyIrcuSectionPd:
+			if strings.HasPrefix(trimmed, keyIrcuDevicePrefix) {
+				flush()
+				// 只保留硬盘，跳过Enclosure services device等
+				if strings.HasPrefix(trimmed, keyIrcuHardDisk) {
+					pd = &PhysicalDriveStat{EnclosureDeviceId: 999, OsPath: "Unknown"}
+				}
+				continue
+			}
+			if pd == nil {
+				continue
+			}
+			key, value, ok := splitSpacedField(trimmed)
+			if !ok {
+				continue
+			}
+			pd.parseSasIrcuField(key, value)
+		}
+	}
+	flush()
+
+	for i := range ad.PhysicalDriveStats {
+		pd := &ad.PhysicalDriveStats[i]
+		if member, ok := members[fmt.Sprintf("%d:%d", pd.EnclosureDeviceId, pd.SlotNumber)]; ok {
+			pd.PdDiskGroup = member[0]
+			pd.PdArm = member[1]
+		}
+	}
+	return ad, nil
+}
+
+func (p *PhysicalDriveStat) parseSasIrcuField(key, value string) {
+	switch key {
+	case "Enclosure #":
+		p.EnclosureDeviceId, _ = strconv.Atoi(value)
+	case "Slot #":
+		p.SlotNumber, _ = strconv.Atoi(value)
+	case "SAS Address":
+		p.SasAddress = normalizeSasAddress(value)
+	case "State":
+		p.FirmwareState = ircuState(ircuPdStates, value)
+	case "Size (in MB)/(in sectors)":
+		p.RawSize = strings.SplitN(value, "/", 2)[0] + " MB"
+	case "Manufacturer":
+		p.Brand = value
+	case "Model Number":
+		p.Model = value
+	case "Serial No":
+		p.SerialNumber = value
+	case "Protocol":
+		p.PdType = value
+	case "Drive Type":
+		p.PdMediaType = "Hard Disk Device"
+		if strings.HasSuffix(value, "SSD") {
+			p.PdMediaType = "Solid State Device"
+		}
+	}
+}
+
+// 5000c50-0-5b2c-1f21 / 0x5000c5005b2c1f21 统一为 5000c5005b2c1f21
+func normalizeSasAddress(address string) string {
+	address = strings.ToLower(strings.TrimSpace(address))
+	address = strings.TrimPrefix(address, "0x")
+	return strings.ReplaceAll(address, "-", "")
+}
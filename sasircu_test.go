@@ -0,0 +1,143 @@
+package diskutil
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func readSasIrcuFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "sasircu", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestParseSasIrcuList(t *testing.T) {
+	if got := parseSasIrcuList(readSasIrcuFixture(t, "list.txt")); !reflect.DeepEqual(got, []int{0, 1}) {
+		t.Errorf("adapters = %v, want [0 1]", got)
+	}
+}
+
+type sasIrcuWantPd struct {
+	deviceId        int
+	enclosure, slot int
+	sasAddress      string
+	state           string
+	diskGroup, arm  string
+	serial, model   string
+	pdType          string
+	mediaType       string
+	rawSize         string
+}
+
+func TestParseSasIrcuDisplay(t *testing.T) {
+	tests := []struct {
+		fixture string
+		vds     []VirtualDriveStat
+		pds     []sasIrcuWantPd
+	}{
+		{
+			fixture: "display_it.txt",
+			vds:     []VirtualDriveStat{},
+			pds: []sasIrcuWantPd{
+				{0, 2, 0, "5000c500a1b2c3d5", "JBOD", "", "", "ZC1ABCDE0000C9281234", "ST4000NM0025", "SAS", "Hard Disk Device", "3815447 MB"},
+				{1, 2, 1, "4433221101000000", "JBOD", "", "", "PHYF9214001A480BGN", "INTEL SSDSC2KB48", "SATA", "Solid State Device", "457862 MB"},
+			},
+		},
+		{
+			fixture: "display_ir.txt",
+			vds: []VirtualDriveStat{
+				{VirtualDrive: 1, Name: "boot", Size: "456809 MB", State: "Degraded", RaidLevel: "RAID1", NumberOfDrives: 2, Encryptiontype: "None", OsPath: "Unknown", diskGroup: "1"},
+			},
+			pds: []sasIrcuWantPd{
+				{0, 1, 0, "4433221100000000", "Online, Spun Up", "1", "0", "PHYF9214001B480BGN", "INTEL SSDSC2KB48", "SATA", "Solid State Device", "457862 MB"},
+				{1, 1, 1, "4433221101000000", "Rebuild", "1", "1", "PHYF9214001C480BGN", "INTEL SSDSC2KB48", "SATA", "Solid State Device", "457862 MB"},
+				{2, 1, 4, "4433221104000000", "Hotspare, Spun Up", "", "", "PHYF9214001D480BGN", "INTEL SSDSC2KB48", "SATA", "Solid State Device", "457862 MB"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			ad, err := parseSasIrcuDisplay(readSasIrcuFixture(t, tt.fixture), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ad.AdapterId != 0 || ad.Backend != backendSasIrcu {
+				t.Errorf("adapter = %d %s", ad.AdapterId, ad.Backend)
+			}
+			if !reflect.DeepEqual(ad.VirtualDriveStats, tt.vds) {
+				t.Errorf("volumes =\n%+v\nwant\n%+v", ad.VirtualDriveStats, tt.vds)
+			}
+			if len(ad.PhysicalDriveStats) != len(tt.pds) {
+				t.Fatalf("got %d drives, want %d", len(ad.PhysicalDriveStats), len(tt.pds))
+			}
+			for i, want := range tt.pds {
+				pd := ad.PhysicalDriveStats[i]
+				got := sasIrcuWantPd{pd.DeviceId, pd.EnclosureDeviceId, pd.SlotNumber, pd.SasAddress, pd.FirmwareState,
+					pd.PdDiskGroup, pd.PdArm, pd.SerialNumber, pd.Model, pd.PdType, pd.PdMediaType, pd.RawSize}
+				if got != want {
+					t.Errorf("drive %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSasIrcuBlockBySasAddress(t *testing.T) {
+	resolver := newOsDeviceResolver(filepath.Join("testdata", "sasircu", "sys"), "/dev")
+	tests := []struct {
+		sasAddress string
+		osPath     string
+		ok         bool
+	}{
+		{normalizeSasAddress("5000c50-0-a1b2-c3d5"), "/dev/sdb", true},
+		{normalizeSasAddress("4433221-1-0100-0000"), "/dev/sdc", true},
+		{normalizeSasAddress("5003048-0-01a2-b3fd"), "", false},
+	}
+	for _, tt := range tests {
+		osPath, ok := resolver.blockBySasAddress(tt.sasAddress)
+		if osPath != tt.osPath || ok != tt.ok {
+			t.Errorf("blockBySasAddress(%s) = %q, %v, want %q, %v", tt.sasAddress, osPath, ok, tt.osPath, tt.ok)
+		}
+	}
+}
+
+func TestSasIrcuListBrokenPhysicalDrive(t *testing.T) {
+	fixtures, err := filepath.Abs(filepath.Join("testdata", "sasircu"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(t.TempDir(), "sas3ircu")
+	content := "#!/bin/sh\ncase \"$1 $2\" in\n" +
+		"\"LIST \") cat '" + fixtures + "/list.txt' ;;\n" +
+		"\"0 DISPLAY\") cat '" + fixtures + "/display_it.txt' ;;\n" +
+		"\"1 DISPLAY\") cat '" + fixtures + "/display_ir.txt' ;;\n" +
+		"esac\n"
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	backend, err := NewSasIrcuBackend(script, filepath.Join(fixtures, "sys"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds, err := NewDiskStatusWithBackends(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken, err := ds.ListBrokenPhysicalDrive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// IT模式下RDY的直通盘是正常的，只有IR卷的重建盘和热备盘
+	got := make([]string, 0, len(broken))
+	for _, pd := range broken {
+		got = append(got, pd.FirmwareState)
+	}
+	if want := []string{"Rebuild", "Hotspare, Spun Up"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("broken drives = %v, want %v", got, want)
+	}
+}
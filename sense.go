@@ -0,0 +1,273 @@
+package diskutil
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	senseFormatFixed      string = "fixed"
+	senseFormatDescriptor string = "descriptor"
+
+	senseKeyMediumError   int = 0x3
+	senseKeyHardwareError int = 0x4
+)
+
+var hexTokenRegex = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+
+// SenseData is a struct to get decoded SCSI sense data.
+type SenseData struct {
+	Format      string `json:"format,omitempty"`
+	Deferred    bool   `json:"deferred,omitempty"`
+	Key         int    `json:"key"`
+	KeyName     string `json:"key_name"`
+	Asc         int    `json:"asc"`
+	Ascq        int    `json:"ascq"`
+	Description string `json:"description"`
+	// Information is the LBA of a medium error, when the device reports it.
+	Information *uint64 `json:"information,omitempty"`
+	Raw         string  `json:"raw,omitempty"`
+}
+
+var senseKeyNames = map[int]string{
+	0x0: "NO SENSE",
+	0x1: "RECOVERED ERROR",
+	0x2: "NOT READY",
+	0x3: "MEDIUM ERROR",
+	0x4: "HARDWARE ERROR",
+	0x5: "ILLEGAL REQUEST",
+	0x6: "UNIT ATTENTION",
+	0x7: "DATA PROTECT",
+	0x8: "BLANK CHECK",
+	0x9: "VENDOR SPECIFIC",
+	0xa: "COPY ABORTED",
+	0xb: "ABORTED COMMAND",
+	0xc: "EQUAL",
+	0xd: "VOLUME OVERFLOW",
+	0xe: "MISCOMPARE",
+	0xf: "COMPLETED",
+}
+
+// 常见的 ASC/ASCQ，key为 asc<<8|ascq
+var senseAscAscqNames = map[int]string{
+	0x0000: "No additional sense information",
+	0x0401: "Logical unit is in process of becoming ready",
+	0x0402: "Logical unit not ready, initializing command required",
+	0x0403: "Logical unit not ready, manual intervention required",
+	0x0404: "Logical unit not ready, format in progress",
+	0x0500: "Logical unit does not respond to selection",
+	0x0800: "Logical unit communication failure",
+	0x0801: "Logical unit communication time-out",
+	0x0802: "Logical unit communication parity error",
+	0x0b01: "Warning - specified temperature exceeded",
+	0x0c00: "Write error",
+	0x0c02: "Write error - auto reallocation failed",
+	0x0c03: "Write error - recommend reassignment",
+	0x1000: "ID CRC or ECC error",
+	0x1100: "Unrecovered read error",
+	0x1101: "Read retries exhausted",
+	0x1102: "Error too long to correct",
+	0x1104: "Unrecovered read error - auto reallocate failed",
+	0x110b: "Unrecovered read error - recommend reassignment",
+	0x110c: "Unrecovered read error - recommend rewrite the data",
+	0x1400: "Recorded entity not found",
+	0x1401: "Record not found",
+	0x1500: "Random positioning error",
+	0x1501: "Mechanical positioning error",
+	0x1600: "Data synchronization mark error",
+	0x1700: "Recovered data with no error correction applied",
+	0x1701: "Recovered data with retries",
+	0x1800: "Recovered data with error correction applied",
+	0x1802: "Recovered data - data auto-reallocated",
+	0x1900: "Defect list error",
+	0x1a00: "Parameter list length error",
+	0x2000: "Invalid command operation code",
+	0x2100: "Logical block address out of range",
+	0x2400: "Invalid field in CDB",
+	0x2500: "Logical unit not supported",
+	0x2600: "Invalid field in parameter list",
+	0x2700: "Write protected",
+	0x2800: "Not ready to ready change, medium may have changed",
+	0x2900: "Power on, reset, or bus device reset occurred",
+	0x2901: "Power on occurred",
+	0x2902: "SCSI bus reset occurred",
+	0x2903: "Bus device reset function occurred",
+	0x2904: "Device internal reset",
+	0x2a01: "Mode parameters changed",
+	0x2f00: "Commands cleared by another initiator",
+	0x3100: "Medium format corrupted",
+	0x3101: "Format command failed",
+	0x3200: "No defect spare location available",
+	0x3e01: "Logical unit failure",
+	0x3e02: "Timeout on logical unit",
+	0x3e03: "Logical unit failed self-test",
+	0x3f01: "Microcode has been changed",
+	0x3f0e: "Reported LUNs data has changed",
+	0x4400: "Internal target failure",
+	0x4700: "SCSI parity error",
+	0x4800: "Initiator detected error message received",
+	0x4b00: "Data phase error",
+	0x4e00: "Overlapped commands attempted",
+	0x5d00: "Failure prediction threshold exceeded",
+	0x5d10: "Hardware impending failure general hard drive failure",
+	0x5dff: "Failure prediction threshold exceeded (false)",
+	0x5e00: "Low power condition on",
+}
+
+// 表中没有的ASCQ按ASC归类
+var senseAscNames = map[int]string{
+	0x04: "Logical unit not ready",
+	0x08: "Logical unit communication failure",
+	0x0c: "Write error",
+	0x11: "Unrecovered read error",
+	0x15: "Positioning error",
+	0x17: "Recovered data without ECC",
+	0x18: "Recovered data with ECC",
+	0x29: "Power on or reset occurred",
+	0x3e: "Logical unit failure",
+	0x3f: "Target operating conditions have changed",
+	0x44: "Internal target failure",
+	0x5d: "Failure prediction threshold exceeded",
+}
+
+func senseDescription(asc, ascq int) string {
+	if name, ok := senseAscAscqNames[asc<<8|ascq]; ok {
+		return name
+	}
+	if name, ok := senseAscNames[asc]; ok {
+		return fmt.Sprintf("%s (ascq 0x%02x)", name, ascq)
+	}
+	return fmt.Sprintf("Unknown asc/ascq 0x%02x/0x%02x", asc, ascq)
+}
+
+func newSenseData(key, asc, ascq int) *SenseData {
+	return &SenseData{
+		Key:         key,
+		KeyName:     senseKeyNames[key],
+		Asc:         asc,
+		Ascq:        ascq,
+		Description: senseDescription(asc, ascq),
+	}
+}
+
+// String() is used to get the print string, e.g. "MEDIUM ERROR: Unrecovered read error (3/11/00)".
+func (s *SenseData) String() string {
+	text := fmt.Sprintf("%s: %s (%x/%02x/%02x)", s.KeyName, s.Description, s.Key, s.Asc, s.Ascq)
+	if s.Information != nil {
+		text += fmt.Sprintf(" at 0x%x", *s.Information)
+	}
+	return text
+}
+
+// DecodeSense() is used to decode raw SCSI sense data in fixed (0x70/0x71)
+// or descriptor (0x72/0x73) format.
+func DecodeSense(raw []byte) (*SenseData, error) {
+	if len(raw) == 0 {
+		return nil, errors.New("sense data empty")
+	}
+	var sense *SenseData
+	responseCode := raw[0] & 0x7f
+	switch responseCode {
+	case 0x70, 0x71:
+		// 固定格式：byte 2低4位为sense key，byte 12/13为ASC/ASCQ，byte 3-6为information
+		if len(raw) < 14 {
+			return nil, fmt.Errorf("fixed sense data too short: %d bytes", len(raw))
+		}
+		sense = newSenseData(int(raw[2]&0x0f), int(raw[12]), int(raw[13]))
+		sense.Format = senseFormatFixed
+		if raw[0]&0x80 != 0 {
+			information := uint64(binary.BigEndian.Uint32(raw[3:7]))
+			sense.Information = &information
+		}
+	case 0x72, 0x73:
+		// 描述符格式：byte 1/2/3为key/ASC/ASCQ，byte 8开始为描述符，类型0为information
+		if len(raw) < 8 {
+			return nil, fmt.Errorf("descriptor sense data too short: %d bytes", len(raw))
+		}
+		sense = newSenseData(int(raw[1]&0x0f), int(raw[2]), int(raw[3]))
+		sense.Format = senseFormatDescriptor
+		end := 8 + int(raw[7])
+		if end > len(raw) {
+			end = len(raw)
+		}
+		for i := 8; i+1 < end; i += 2 + int(raw[i+1]) {
+			if raw[i] == 0x00 && i+12 <= end {
+				information := binary.BigEndian.Uint64(raw[i+4 : i+12])
+				sense.Information = &information
+			}
+		}
+	default:
+		return nil, fmt.Errorf("sense response code 0x%02x not supported", responseCode)
+	}
+	sense.Deferred = responseCode == 0x71 || responseCode == 0x73
+	sense.Raw = fmt.Sprintf("% x", raw)
+	return sense, nil
+}
+
+// DecodeSenseHex() is used to decode sense data written as hex, either as
+// bytes ("70 00 03 ..."), as MegaCli words ("0070 0000 0003 ...") or as one blob.
+func DecodeSenseHex(text string) (*SenseData, error) {
+	fileds := strings.Fields(text)
+	if len(fileds) == 1 && len(fileds[0]) > 4 {
+		raw, err := hex.DecodeString(fileds[0])
+		if err != nil {
+			return nil, err
+		}
+		return DecodeSense(raw)
+	}
+	return DecodeSense(appendHexBytes(nil, text))
+}
+
+// "0070 0000 0003" 或 "70 00 03" 或 "700003"：一行的token都是 "00xx" 时是MegaCli的字，
+// 每个字取低字节；否则每个token按两位一个字节拆分，非十六进制和奇数位的token跳过
+func appendHexBytes(raw []byte, line string) []byte {
+	tokens := strings.Fields(line)
+	words := len(tokens) > 0
+	for _, token := range tokens {
+		if len(token) != 4 || !strings.HasPrefix(token, "00") {
+			words = false
+			break
+		}
+	}
+	for _, token := range tokens {
+		if !hexTokenRegex.MatchString(token) || len(token)%2 != 0 {
+			continue
+		}
+		if words {
+			token = token[2:]
+		}
+		for i := 0; i < len(token); i += 2 {
+			value, err := strconv.ParseUint(token[i:i+2], 16, 8)
+			if err != nil {
+				continue
+			}
+			raw = append(raw, byte(value))
+		}
+	}
+	return raw
+}
+
+// MediaErrorEvents() is used to pick the events about the PD which carry a
+// MEDIUM ERROR or HARDWARE ERROR sense, with the sense decoded. The events
+// should come from the adapter of the PD.
+func (p *PhysicalDriveStat) MediaErrorEvents(events []Event) []Event {
+	result := make([]Event, 0)
+	for _, event := range events {
+		pd := event.PhysicalDrive
+		if pd == nil || event.Sense == nil {
+			continue
+		}
+		if pd.DeviceId != p.DeviceId && (pd.EnclosureDeviceId != p.EnclosureDeviceId || pd.SlotNumber != p.SlotNumber) {
+			continue
+		}
+		if event.Sense.Key == senseKeyMediumError || event.Sense.Key == senseKeyHardwareError {
+			result = append(result, event)
+		}
+	}
+	return result
+}
@@ -0,0 +1,43 @@
+package diskutil
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAppendHexBytes(t *testing.T) {
+	tests := []struct {
+		line string
+		want []byte
+	}{
+		{"70 00 03 00", []byte{0x70, 0x00, 0x03, 0x00}},
+		{"0070 0000 0003 0000", []byte{0x70, 0x00, 0x03, 0x00}},
+		{"70000300", []byte{0x70, 0x00, 0x03, 0x00}},
+		// 高字节不为0的不是MegaCli的字，两个字节都保留
+		{"0070 1234", []byte{0x00, 0x70, 0x12, 0x34}},
+		{"70 0 03 zz 00", []byte{0x70, 0x03, 0x00}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := appendHexBytes(nil, tt.line); !bytes.Equal(got, tt.want) {
+			t.Errorf("appendHexBytes(%q) = % x, want % x", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeSenseHex(t *testing.T) {
+	for _, text := range []string{
+		"70 00 03 00 1a 2b 3c 0a 00 00 00 00 11 00",
+		"0070 0000 0003 0000 001a 002b 003c 000a 0000 0000 0000 0000 0011 0000",
+		"700003001a2b3c0a000000001100",
+	} {
+		sense, err := DecodeSenseHex(text)
+		if err != nil {
+			t.Errorf("%q: %v", text, err)
+			continue
+		}
+		if sense.Key != 0x3 || sense.Asc != 0x11 || sense.Ascq != 0x00 {
+			t.Errorf("%q = %d/%02x/%02x, want 3/11/00", text, sense.Key, sense.Asc, sense.Ascq)
+		}
+	}
+}
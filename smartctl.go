@@ -0,0 +1,222 @@
+package diskutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const defaultSmartConcurrency int = 4
+
+// SmartStat is a struct to get the SMART data of a PhysicalDriveStat from smartctl.
+type SmartStat struct {
+	Passed               bool   `json:"passed"`
+	PowerOnHours         int    `json:"power_on_hours"`
+	ReallocatedSectors   int    `json:"reallocated_sectors"`
+	PendingSectors       int    `json:"pending_sectors"`
+	UncorrectableSectors int    `json:"uncorrectable_sectors"`
+	CrcErrors            int    `json:"crc_errors"`
+	WearLevel            int    `json:"wear_level"`
+	GrownDefects         int    `json:"grown_defects"`
+	Error                string `json:"error,omitempty"`
+}
+
+// smartctl的json输出中需要的部分
+type smartCtlOutput struct {
+	Smartctl struct {
+		ExitStatus int `json:"exit_status"`
+		Messages   []struct {
+			String   string `json:"string"`
+			Severity string `json:"severity"`
+		} `json:"messages"`
+	} `json:"smartctl"`
+	SmartStatus *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	PowerOnTime struct {
+		Hours int `json:"hours"`
+	} `json:"power_on_time"`
+	AtaSmartAttributes struct {
+		Table []struct {
+			Id    int    `json:"id"`
+			Name  string `json:"name"`
+			Value int    `json:"value"`
+			Raw   struct {
+				Value int `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+	ScsiGrownDefectList int `json:"scsi_grown_defect_list"`
+	ScsiPercentageUsed  int `json:"scsi_percentage_used_endurance_indicator"`
+	ScsiErrorCounterLog map[string]struct {
+		TotalUncorrectedErrors int `json:"total_uncorrected_errors"`
+	} `json:"scsi_error_counter_log"`
+}
+
+// EnableSmart() is used to enrich every PhysicalDriveStat with the SMART data of smartctl
+// when the DiskStatus is collected. Drives behind MegaRaid cards are read by
+// "-d megaraid,<DeviceId>", drives exposed to the OS are read directly.
+// concurrency limits the running smartctl processes, a failed drive only sets its Smart.Error.
+func (d *DiskStatus) EnableSmart(smartctlPath string, concurrency int) error {
+	smartctlPath = path.Clean(smartctlPath)
+	if !fileExist(smartctlPath) {
+		return errors.New("smartctl not exist")
+	}
+	if concurrency <= 0 {
+		concurrency = defaultSmartConcurrency
+	}
+	d.smartctlPath = smartctlPath
+	d.smartConcurrency = concurrency
+	return nil
+}
+
+// smartctl的参数，MegaRaid盘需要借用同一块卡上任意一个系统盘符
+func smartArgs(backend string, pd *PhysicalDriveStat, adapterDevice string) (string, error) {
+	// dm设备不支持SMART，multipath盘改用一条可用的路径
+	if pd.Multipath != nil {
+		for _, p := range pd.Multipath.Paths {
+			if p.State == "running" {
+				return "--json -a /dev/" + p.Device, nil
+			}
+		}
+		return "", errors.New("no running path of the multipath device")
+	}
+	switch backend {
+	case backendMegaCli, backendStorCli:
+		if strings.HasPrefix(pd.FirmwareState, "JBOD") && strings.HasPrefix(pd.OsPath, "/dev/") {
+			return "--json -a " + pd.OsPath, nil
+		}
+		if adapterDevice == "" {
+			return "", errors.New("no os device found on the adapter")
+		}
+		return fmt.Sprintf("--json -a -d megaraid,%d %s", pd.DeviceId, adapterDevice), nil
+	case backendSasIrcu:
+		if strings.HasPrefix(pd.OsPath, "/dev/") {
+			return "--json -a " + pd.OsPath, nil
+		}
+		return "", errors.New("no os device found")
+	}
+	return "", nil
+}
+
+// adapterOsDevice() 先用已采集的VD/JBOD盘符；GetPhysicalDrive()没有采集VD，
+// 再从sysfs中卡的PCI路径下找任意一个块设备，MegaCli卡的PCI路径用到时才查询
+func (d *DiskStatus) adapterOsDevice(ad *AdapterStat) string {
+	for _, vd := range ad.VirtualDriveStats {
+		if strings.HasPrefix(vd.OsPath, "/dev/") {
+			return vd.OsPath
+		}
+	}
+	for _, pd := range ad.PhysicalDriveStats {
+		if strings.HasPrefix(pd.OsPath, "/dev/") {
+			return pd.OsPath
+		}
+	}
+	pciPath := ad.pciPath
+	if pciPath == "" && ad.Backend == backendMegaCli && d.megacliPath != "" {
+		if p, ok := getHBAPCIInfo(d.megacliPath, strconv.Itoa(ad.AdapterId)); ok {
+			pciPath = p
+		}
+	}
+	if devices := d.resolver.scsiDevices(pciPath); len(devices) > 0 {
+		return d.resolver.devPath(devices[0].block)
+	}
+	return ""
+}
+
+func (d *DiskStatus) enrichSmart(ads []AdapterStat) {
+	if d.smartctlPath == "" {
+		return
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, d.smartConcurrency)
+	for i := range ads {
+		ad := &ads[i]
+		adapterDevice := ""
+		if ad.Backend == backendMegaCli || ad.Backend == backendStorCli {
+			adapterDevice = d.adapterOsDevice(ad)
+		}
+		for j := range ad.PhysicalDriveStats {
+			pd := &ad.PhysicalDriveStats[j]
+			args, err := smartArgs(ad.Backend, pd, adapterDevice)
+			if err != nil {
+				pd.Smart = &SmartStat{Error: err.Error()}
+				continue
+			}
+			if args == "" {
+				continue
+			}
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(pd *PhysicalDriveStat, args string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				smart, err := runSmartCtl(d.smartctlPath, args)
+				if err != nil {
+					smart = &SmartStat{Error: err.Error()}
+				}
+				pd.Smart = smart
+			}(pd, args)
+		}
+	}
+	wg.Wait()
+}
+
+// smartctl的退出码是位掩码，只有bit0/bit1表示命令本身失败，其他位是盘的健康状态
+func runSmartCtl(smartctlPath, args string) (*SmartStat, error) {
+	cmd := exec.Command(smartctlPath, strings.Split(args, " ")...)
+	output, err := cmd.Output()
+	if err != nil && len(output) == 0 {
+		return nil, err
+	}
+	return parseSmartCtl(output)
+}
+
+func parseSmartCtl(output []byte) (*SmartStat, error) {
+	result := new(smartCtlOutput)
+	if err := json.Unmarshal(output, result); err != nil {
+		return nil, fmt.Errorf("smartctl output illegal: %v", err)
+	}
+	if result.Smartctl.ExitStatus&0x3 != 0 {
+		for _, msg := range result.Smartctl.Messages {
+			if msg.Severity == "error" {
+				return nil, errors.New("smartctl return error: " + msg.String)
+			}
+		}
+		return nil, fmt.Errorf("smartctl return error: exit status %d", result.Smartctl.ExitStatus)
+	}
+
+	smart := &SmartStat{
+		Passed:       result.SmartStatus == nil || result.SmartStatus.Passed,
+		PowerOnHours: result.PowerOnTime.Hours,
+		GrownDefects: result.ScsiGrownDefectList,
+		WearLevel:    result.ScsiPercentageUsed,
+	}
+	for _, counter := range result.ScsiErrorCounterLog {
+		smart.UncorrectableSectors += counter.TotalUncorrectedErrors
+	}
+	for _, attr := range result.AtaSmartAttributes.Table {
+		switch attr.Id {
+		case 5:
+			smart.ReallocatedSectors = attr.Raw.Value
+		case 197:
+			smart.PendingSectors = attr.Raw.Value
+		case 198:
+			smart.UncorrectableSectors = attr.Raw.Value
+		case 199:
+			smart.CrcErrors = attr.Raw.Value
+		case 177, 231, 233:
+			// SSD寿命类属性的归一化值从100递减，转换为已使用的百分比
+			if smart.WearLevel == 0 && attr.Value > 0 && attr.Value <= 100 {
+				smart.WearLevel = 100 - attr.Value
+			}
+		}
+	}
+	return smart, nil
+}
@@ -0,0 +1,90 @@
+package diskutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSmartCtl(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    SmartStat
+	}{
+		{"sata_ssd.json", SmartStat{Passed: true, PowerOnHours: 21873, ReallocatedSectors: 2, CrcErrors: 1, WearLevel: 4}},
+		// exit status 4 是盘的健康状态，不是命令失败
+		{"sas_hdd.json", SmartStat{Passed: false, PowerOnHours: 40122, UncorrectableSectors: 4, GrownDefects: 37}},
+	}
+	for _, tt := range tests {
+		data, err := os.ReadFile(filepath.Join("testdata", "smartctl", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		smart, err := parseSmartCtl(data)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.fixture, err)
+		}
+		if *smart != tt.want {
+			t.Errorf("%s = %+v, want %+v", tt.fixture, *smart, tt.want)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join("testdata", "smartctl", "open_failed.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseSmartCtl(data); err == nil || err.Error() != "smartctl return error: Smartctl open device: /dev/sda [megaraid_disk_30] failed: INQUIRY failed" {
+		t.Errorf("open failure = %v", err)
+	}
+}
+
+func TestSmartArgs(t *testing.T) {
+	tests := []struct {
+		name          string
+		backend       string
+		pd            PhysicalDriveStat
+		adapterDevice string
+		want          string
+		err           bool
+	}{
+		{"raid member", backendMegaCli, PhysicalDriveStat{DeviceId: 8, FirmwareState: "Online, Spun Up", OsPath: "Unknown"}, "/dev/sda",
+			"--json -a -d megaraid,8 /dev/sda", false},
+		{"raid member without adapter device", backendStorCli, PhysicalDriveStat{DeviceId: 8, FirmwareState: "Online, Spun Up"}, "", "", true},
+		{"spun up jbod", backendStorCli, PhysicalDriveStat{DeviceId: 20, FirmwareState: "JBOD, Spun Up", OsPath: "/dev/sdc"}, "/dev/sdb",
+			"--json -a /dev/sdc", false},
+		{"jbod", backendMegaCli, PhysicalDriveStat{DeviceId: 20, FirmwareState: "JBOD", OsPath: "/dev/sdc"}, "/dev/sdb", "--json -a /dev/sdc", false},
+		{"multipath", backendSasIrcu, PhysicalDriveStat{OsPath: "/dev/dm-3", Multipath: &MultipathDevice{Paths: []MultipathPath{
+			{Device: "sdd", State: "failed"}, {Device: "sdh", State: "running"},
+		}}}, "", "--json -a /dev/sdh", false},
+		{"multipath without running path", backendSasIrcu, PhysicalDriveStat{Multipath: &MultipathDevice{Paths: []MultipathPath{
+			{Device: "sdd", State: "failed"},
+		}}}, "", "", true},
+		{"hba drive", backendSasIrcu, PhysicalDriveStat{OsPath: "/dev/sde"}, "", "--json -a /dev/sde", false},
+		{"nvme", backendNvme, PhysicalDriveStat{OsPath: "/dev/nvme0n1"}, "", "", false},
+	}
+	for _, tt := range tests {
+		args, err := smartArgs(tt.backend, &tt.pd, tt.adapterDevice)
+		if args != tt.want || (err != nil) != tt.err {
+			t.Errorf("%s: smartArgs() = %q, %v, want %q, error %v", tt.name, args, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestAdapterOsDeviceFromSysfs(t *testing.T) {
+	// 只采集了PD，成员盘都没有盘符，从卡的PCI路径下找
+	d := &DiskStatus{resolver: newOsDeviceResolver(filepath.Join("testdata", "storcli", "sys"), "/dev")}
+	ad := &AdapterStat{
+		Backend: backendStorCli,
+		PhysicalDriveStats: []PhysicalDriveStat{
+			{DeviceId: 8, FirmwareState: "Online, Spun Up", OsPath: "Unknown"},
+		},
+		pciPath: "0000:3b:00.0",
+	}
+	if device := d.adapterOsDevice(ad); device != "/dev/sdc" {
+		t.Errorf("adapter device = %q, want /dev/sdc", device)
+	}
+	ad.pciPath = ""
+	if device := d.adapterOsDevice(ad); device != "" {
+		t.Errorf("adapter device without pci path = %q", device)
+	}
+}
@@ -0,0 +1,335 @@
+package diskutil
+
+import (
+	"errors"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	keySsaLogicalDrive  string = "Logical Drive:"
+	keySsaArray         string = "Array:"
+	keySsaPhysicalDrive string = "physicaldrive "
+	keySsaUnassigned    string = "Unassigned"
+	keySsaHBADrives     string = "HBA Drives"
+)
+
+var (
+	ssaCliControllerRegex = regexp.MustCompile(`^\S.* in Slot (\d+)`)
+	// physicaldrive 1I:2:1 (port 1I:box 2:bay 1, SAS HDD, 300 GB, OK)
+	ssaCliLocationRegex = regexp.MustCompile(`^physicaldrive (\w+):(\d+):(\d+)`)
+	// "1I"、"2E"、"CN0"
+	ssaCliPortRegex = regexp.MustCompile(`(\d+)([IE]?)$`)
+)
+
+// ssaCliEnclosure() 不同端口下可以有相同的box:bay，端口折算进enclosure：port*100+box，外部端口再加5000
+func ssaCliEnclosure(port string, box int) int {
+	matches := ssaCliPortRegex.FindStringSubmatch(port)
+	if matches == nil {
+		return box
+	}
+	number, _ := strconv.Atoi(matches[1])
+	enclosure := number*100 + box
+	if matches[2] == "E" {
+		enclosure += 5000
+	}
+	return enclosure
+}
+
+// SsaCliBackend is a Backend which uses ssacli or hpssacli to get the stat of
+// HPE Smart Array cards. Logical drives are mapped to VirtualDriveStats and
+// the port:box:bay of the physical drives are mapped to enclosure/slot: the
+// enclosure is port*100+box, plus 5000 for an external port, so "2I:1:5" is
+// 201:5 and "1E:1:5" is 5101:5.
+type SsaCliBackend struct {
+	ssacliPath string
+}
+
+// NewSsaCliBackend() use the ssaCliPath to build a SsaCliBackend.
+func NewSsaCliBackend(ssaCliPath string) (*SsaCliBackend, error) {
+	ssaCliPath = path.Clean(ssaCliPath)
+	if !fileExist(ssaCliPath) {
+		return nil, errors.New("ssacli not exist")
+	}
+	return &SsaCliBackend{
+		ssacliPath: ssaCliPath,
+	}, nil
+}
+
+// Name() is used to get the name of the backend.
+func (s *SsaCliBackend) Name() string {
+	return backendSsaCli
+}
+
+// Get() is used to get all the AdapterStats of the backend.
+func (s *SsaCliBackend) Get() ([]AdapterStat, error) {
+	output, err := execCmd(s.ssacliPath, "ctrl all show config detail")
+	if err != nil {
+		return nil, err
+	}
+	return parseSsaCliConfig(output)
+}
+
+// GetVirtualDrive() is used to get the AdapterStats with VirtualDriveStats only.
+func (s *SsaCliBackend) GetVirtualDrive() ([]AdapterStat, error) {
+	ads, err := s.Get()
+	if err != nil {
+		return nil, err
+	}
+	for i := range ads {
+		ads[i].PhysicalDriveStats = nil
+	}
+	return ads, nil
+}
+
+// GetPhysicalDrive() is used to get the AdapterStats with PhysicalDriveStats only.
+func (s *SsaCliBackend) GetPhysicalDrive() ([]AdapterStat, error) {
+	ads, err := s.Get()
+	if err != nil {
+		return nil, err
+	}
+	for i := range ads {
+		ads[i].VirtualDriveStats = nil
+	}
+	return ads, nil
+}
+
+// ssacli的输出按缩进分层：控制器 > Array/Unassigned/HBA Drives > Logical Drive/physicaldrive > 属性
+type ssaCliParser struct {
+	ads    []AdapterStat
+	ad     *AdapterStat
+	array  string
+	arm    int
+	vd     *VirtualDriveStat
+	pd     *PhysicalDriveStat
+	indent int
+	// PD的Status和Drive Type，flush时一起转换为Firmware state
+	pdStatus    string
+	pdDriveType string
+}
+
+func parseSsaCliConfig(output string) ([]AdapterStat, error) {
+	if strings.TrimSpace(output) == "" {
+		return nil, errors.New("ssacli config info nil")
+	}
+
+	p := &ssaCliParser{ads: make([]AdapterStat, 0)}
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+
+		if indent == 0 {
+			p.flush()
+			matches := ssaCliControllerRegex.FindStringSubmatch(trimmed)
+			if matches == nil {
+				p.ad = nil
+				continue
+			}
+			slot, _ := strconv.Atoi(matches[1])
+			p.ads = append(p.ads, AdapterStat{
+				AdapterId:          slot,
+				Backend:            backendSsaCli,
+				VirtualDriveStats:  make([]VirtualDriveStat, 0),
+				PhysicalDriveStats: make([]PhysicalDriveStat, 0),
+			})
+			p.ad = &p.ads[len(p.ads)-1]
+			p.array = ""
+			continue
+		}
+		if p.ad == nil {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(trimmed, keySsaArray):
+			p.flush()
+			p.array = strings.TrimSpace(strings.TrimPrefix(trimmed, keySsaArray))
+			p.arm = 0
+		case trimmed == keySsaUnassigned || trimmed == keySsaHBADrives:
+			p.flush()
+			p.array = ""
+		case strings.HasPrefix(trimmed, keySsaLogicalDrive):
+			p.flush()
+			vdId, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(trimmed, keySsaLogicalDrive)))
+			if err != nil {
+				return nil, errors.New("format illegal: " + trimmed)
+			}
+			p.vd = &VirtualDriveStat{VirtualDrive: vdId, OsPath: "Unknown", Encryptiontype: "None"}
+			p.indent = indent
+		case strings.HasPrefix(trimmed, keySsaPhysicalDrive) && strings.Contains(trimmed, "("):
+			// 逻辑盘下Mirror Group中的成员盘引用，只有RAID1/10才有，成员盘数由countVdMembers()统计
+		case strings.HasPrefix(trimmed, keySsaPhysicalDrive):
+			p.flush()
+			matches := ssaCliLocationRegex.FindStringSubmatch(trimmed)
+			if matches == nil {
+				return nil, errors.New("format illegal: " + trimmed)
+			}
+			box, _ := strconv.Atoi(matches[2])
+			bay, _ := strconv.Atoi(matches[3])
+			p.pd = &PhysicalDriveStat{
+				EnclosureDeviceId: ssaCliEnclosure(matches[1], box),
+				SlotNumber:        bay,
+				// ssacli没有Device Id，按出现顺序编号
+				DeviceId:    len(p.ad.PhysicalDriveStats),
+				PdDiskGroup: p.array,
+				Location:    matches[1] + ":" + matches[2] + ":" + matches[3],
+				OsPath:      "Unknown",
+			}
+			p.indent = indent
+		default:
+			if indent <= p.indent {
+				// 退出当前LD/PD块，例如Drive Cage、SEP、Expander等
+				p.flush()
+				continue
+			}
+			if p.vd != nil {
+				p.vd.parseSsaCliLine(trimmed)
+			} else if p.pd != nil {
+				p.parsePdLine(trimmed)
+			}
+		}
+	}
+	p.flush()
+	p.countVdMembers()
+	return p.ads, nil
+}
+
+// countVdMembers() LD的成员盘是所在Array中除热备外的PD
+func (p *ssaCliParser) countVdMembers() {
+	for i := range p.ads {
+		ad := &p.ads[i]
+		for j := range ad.VirtualDriveStats {
+			for _, pd := range ad.PhysicalDriveStats {
+				if isVdMember(&ad.VirtualDriveStats[j], &pd) && pd.PdArm != "" {
+					ad.VirtualDriveStats[j].NumberOfDrives++
+				}
+			}
+		}
+	}
+}
+
+func (p *ssaCliParser) flush() {
+	if p.ad != nil && p.vd != nil {
+		p.vd.diskGroup = p.array
+		p.ad.VirtualDriveStats = append(p.ad.VirtualDriveStats, *p.vd)
+	}
+	if p.ad != nil && p.pd != nil {
+		pd := p.pd
+		pd.FirmwareState = ssaCliPdState(p.pdStatus, p.pdDriveType, pd.PdDiskGroup)
+		if pd.PdDiskGroup != "" && !strings.Contains(p.pdDriveType, "Spare") {
+			pd.PdArm = strconv.Itoa(p.arm)
+			p.arm++
+		}
+		p.ad.PhysicalDriveStats = append(p.ad.PhysicalDriveStats, *pd)
+	}
+	p.vd = nil
+	p.pd = nil
+	p.pdStatus = ""
+	p.pdDriveType = ""
+	p.indent = 0
+}
+
+func (p *ssaCliParser) parsePdLine(line string) {
+	key, value, ok := ssaCliField(line)
+	if !ok {
+		return
+	}
+	switch key {
+	case "Status":
+		p.pdStatus = value
+	case "Drive Type":
+		p.pdDriveType = value
+	default:
+		p.pd.parseSsaCliField(key, value)
+	}
+}
+
+func ssaCliField(line string) (string, string, bool) {
+	fileds := strings.SplitN(line, ":", 2)
+	if len(fileds) != 2 {
+		return "", "", false
+	}
+	return strings.TrimSpace(fileds[0]), strings.TrimSpace(fileds[1]), true
+}
+
+func (v *VirtualDriveStat) parseSsaCliLine(line string) {
+	key, value, ok := ssaCliField(line)
+	if !ok {
+		return
+	}
+	switch key {
+	case "Size":
+		v.Size = value
+	case "Status":
+		// 只有OK对应Optimal，其余状态(Interim Recovery Mode等)原样保留
+		if value == "OK" {
+			value = "Optimal"
+		}
+		v.State = value
+	case "Logical Drive Label":
+		v.Name = value
+	case "Fault Tolerance":
+		v.RaidLevel = normalizeRaidLevel(value)
+	case "Disk Name":
+		if strings.HasPrefix(value, "/dev/") {
+			v.OsPath = value
+		}
+	case "Encrypted":
+		if value == "True" {
+			v.Encryptiontype = "Encrypted"
+		}
+	}
+}
+
+func (p *PhysicalDriveStat) parseSsaCliField(key, value string) {
+	switch key {
+	case "Interface Type":
+		p.PdMediaType = "Hard Disk Device"
+		if strings.HasPrefix(value, "Solid State") {
+			p.PdMediaType = "Solid State Device"
+			value = strings.TrimSpace(strings.TrimPrefix(value, "Solid State"))
+		}
+		p.PdType = value
+	case "Size":
+		p.RawSize = value
+	case "Serial Number":
+		p.SerialNumber = value
+	case "Model":
+		parts := strings.Fields(value)
+		if len(parts) > 1 {
+			p.Brand = parts[0]
+			p.Model = strings.Join(parts[1:], " ")
+		} else {
+			p.Model = value
+		}
+	case "Current Temperature (C)":
+		p.DriveTemperature = value + "C"
+	case "Disk Name":
+		if strings.HasPrefix(value, "/dev/") {
+			p.OsPath = value
+		}
+	}
+}
+
+// 把Status和Drive Type转换为MegaCli风格的Firmware state，异常状态原样保留
+func ssaCliPdState(status, driveType, array string) string {
+	if status != "OK" {
+		return status
+	}
+	switch {
+	case strings.Contains(driveType, "Spare"):
+		return "Hotspare, Spun Up"
+	case strings.Contains(driveType, "HBA"):
+		return "JBOD"
+	case strings.Contains(driveType, "Unassigned"):
+		return "Unconfigured(good), Spun Up"
+	case driveType == "" && array == "":
+		return "Unconfigured(good), Spun Up"
+	}
+	return "Online, Spun Up"
+}
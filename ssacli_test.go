@@ -0,0 +1,104 @@
+package diskutil
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseSsaCliConfig(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "ssacli", "config_detail.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ads, err := parseSsaCliConfig(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ads) != 1 || ads[0].AdapterId != 0 || ads[0].Backend != backendSsaCli {
+		t.Fatalf("adapters = %+v", ads)
+	}
+
+	wantVds := []VirtualDriveStat{
+		{VirtualDrive: 1, Name: "01A2B3C4PDNLH0BRH8V0A1", Size: "279.37 GB", State: "Optimal", RaidLevel: "RAID1", NumberOfDrives: 2, Encryptiontype: "None", OsPath: "/dev/sda", diskGroup: "A"},
+		{VirtualDrive: 2, Name: "0272A1B2PDNLH0BRH8V0A1", Size: "894.22 GB", State: "Interim Recovery Mode", RaidLevel: "RAID5", NumberOfDrives: 3, Encryptiontype: "None", OsPath: "/dev/sdb", diskGroup: "B"},
+	}
+	if !reflect.DeepEqual(ads[0].VirtualDriveStats, wantVds) {
+		t.Errorf("vds =\n%+v\nwant\n%+v", ads[0].VirtualDriveStats, wantVds)
+	}
+
+	tests := []struct {
+		location    string
+		state       string
+		diskGroup   string
+		arm         string
+		serial      string
+		mediaType   string
+		brand       string
+		temperature string
+	}{
+		{"1I:2:1", "Online, Spun Up", "A", "0", "6SE1ABCD0000B123", "Hard Disk Device", "HP", "30C"},
+		{"1I:2:2", "Online, Spun Up", "A", "1", "6SE1ABCD0000B124", "Hard Disk Device", "HP", "31C"},
+		{"2I:1:5", "Online, Spun Up", "B", "0", "BTYS8030ABCD480BGN", "Solid State Device", "ATA", "27C"},
+		{"2I:1:6", "Failed", "B", "1", "BTYS8030ABCE480BGN", "Solid State Device", "ATA", ""},
+		{"2I:1:7", "Online, Spun Up", "B", "2", "BTYS8030ABCF480BGN", "Solid State Device", "ATA", "28C"},
+		{"2I:1:8", "Hotspare, Spun Up", "B", "", "BTYS8030ABD0480BGN", "Solid State Device", "ATA", "26C"},
+		{"2I:1:4", "Unconfigured(good), Spun Up", "", "", "0XGA1BCD", "Hard Disk Device", "HP", "29C"},
+	}
+	pds := ads[0].PhysicalDriveStats
+	if len(pds) != len(tests) {
+		t.Fatalf("got %d drives, want %d", len(pds), len(tests))
+	}
+	for i, tt := range tests {
+		pd := pds[i]
+		got := []string{pd.Location, pd.FirmwareState, pd.PdDiskGroup, pd.PdArm, pd.SerialNumber, pd.PdMediaType, pd.Brand, pd.DriveTemperature}
+		want := []string{tt.location, tt.state, tt.diskGroup, tt.arm, tt.serial, tt.mediaType, tt.brand, tt.temperature}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("drive %d = %v, want %v", i, got, want)
+		}
+	}
+	if pds[2].EnclosureDeviceId != 201 || pds[2].SlotNumber != 5 {
+		t.Errorf("2I:1:5 E:S = %d:%d, want 201:5", pds[2].EnclosureDeviceId, pds[2].SlotNumber)
+	}
+}
+
+func TestParseSsaCliSameBayOnTwoPorts(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "ssacli", "two_ports.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ads, err := parseSsaCliConfig(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ads) != 1 || ads[0].AdapterId != 2 {
+		t.Fatalf("adapters = %+v", ads)
+	}
+	want := []struct {
+		location  string
+		enclosure int
+		serial    string
+	}{
+		{"1I:1:5", 101, "0XGA2AAA"},
+		{"2I:1:5", 201, "0XGA2BBB"},
+		{"1E:1:5", 5101, "WFK0CCCC"},
+	}
+	pds := ads[0].PhysicalDriveStats
+	if len(pds) != len(want) {
+		t.Fatalf("got %d drives, want %d", len(pds), len(want))
+	}
+	for i, w := range want {
+		pd := pds[i]
+		if pd.Location != w.location || pd.EnclosureDeviceId != w.enclosure || pd.SlotNumber != 5 || pd.SerialNumber != w.serial {
+			t.Errorf("drive %d = %s %d:%d %s, want %s %d:5 %s", i, pd.Location, pd.EnclosureDeviceId, pd.SlotNumber, pd.SerialNumber,
+				w.location, w.enclosure, w.serial)
+		}
+	}
+}
+
+func TestParseSsaCliConfigEmpty(t *testing.T) {
+	if _, err := parseSsaCliConfig("\n"); err == nil {
+		t.Error("empty output accepted")
+	}
+}
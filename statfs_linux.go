@@ -0,0 +1,16 @@
+package diskutil
+
+import "syscall"
+
+// statfsUsage() 获取挂载点的总容量、已用和可用空间
+func statfsUsage(mountPoint string) (uint64, uint64, uint64) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(mountPoint, &st); err != nil {
+		return 0, 0, 0
+	}
+	bsize := uint64(st.Bsize)
+	total := st.Blocks * bsize
+	free := st.Bavail * bsize
+	used := (st.Blocks - st.Bfree) * bsize
+	return total, used, free
+}
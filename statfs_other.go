@@ -0,0 +1,8 @@
+//go:build !linux
+
+package diskutil
+
+// statfsUsage() 非Linux系统不支持，容量全部返回0
+func statfsUsage(mountPoint string) (uint64, uint64, uint64) {
+	return 0, 0, 0
+}
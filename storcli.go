@@ -0,0 +1,449 @@
+package diskutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// storcli/perccli的缩写状态到MegaCli状态的映射，保证ListBrokenDrive的判断不变
+var (
+	storCliVdStates = map[string]string{
+		"Optl":   "Optimal",
+		"OfLn":   "Offline",
+		"Pdgd":   "Partially Degraded",
+		"Dgrd":   "Degraded",
+		"Rec":    "Recovery",
+		"Cac":    "CacheCade",
+		"Cbshld": "Cache Bypass Shield",
+		"HD":     "Hidden",
+		"TRANS":  "Transport Ready",
+	}
+	storCliPdStates = map[string]string{
+		"Onln":    "Online",
+		"Offln":   "Offline",
+		"UGood":   "Unconfigured(good)",
+		"UGUnsp":  "Unconfigured(good) Unsupported",
+		"UGShld":  "Unconfigured(good) Shielded",
+		"UBad":    "Unconfigured(bad)",
+		"UBUnsp":  "Unconfigured(bad) Unsupported",
+		"GHS":     "Hotspare",
+		"DHS":     "Hotspare",
+		"HSPShld": "Hotspare Shielded",
+		"Rbld":    "Rebuild",
+		"Cpybck":  "Copyback",
+		"Failed":  "Failed",
+		"Msng":    "Missing",
+		"JBOD":    "JBOD",
+		"CFShld":  "Configured Shielded",
+	}
+	storCliMediaTypes = map[string]string{
+		"HDD": "Hard Disk Device",
+		"SSD": "Solid State Device",
+	}
+	storCliPdKeyRegex = regexp.MustCompile(`^Drive /c(\d+)(?:/e(\d+))?/s(\d+)$`)
+	storCliVdKeyRegex = regexp.MustCompile(`^/c(\d+)/v(\d+)$`)
+	storCliRawSizeReg = regexp.MustCompile(` \[0x.*Sectors\]`)
+)
+
+type storCliController struct {
+	CommandStatus map[string]interface{} `json:"Command Status"`
+	ResponseData  map[string]interface{} `json:"Response Data"`
+}
+
+type storCliOutput struct {
+	Controllers []storCliController `json:"Controllers"`
+}
+
+// StorCliBackend is a Backend which uses storcli64 or perccli64 with JSON output
+// to get the stat of Broadcom/LSI and Dell PERC cards.
+type StorCliBackend struct {
+	storcliPath string
+	resolver    *osDeviceResolver
+}
+
+// NewStorCliBackend() use the storCliPath to build a StorCliBackend.
+// perccli64 shares the same command set, so its path can also be used.
+func NewStorCliBackend(storCliPath string) (*StorCliBackend, error) {
+	storCliPath = path.Clean(storCliPath)
+	if !fileExist(storCliPath) {
+		return nil, errors.New("storCli not exist")
+	}
+	return &StorCliBackend{
+		storcliPath: storCliPath,
+		resolver:    newOsDeviceResolver("", ""),
+	}, nil
+}
+
+// SetSysfsRoot() is used to change the sysfs and /dev roots used to map
+// the VDs and JBODs to the OS block devices, which is useful for tests.
+func (s *StorCliBackend) SetSysfsRoot(sysRoot, devRoot string) {
+	s.resolver = newOsDeviceResolver(sysRoot, devRoot)
+}
+
+// Name() is used to get the name of the backend.
+func (s *StorCliBackend) Name() string {
+	return backendStorCli
+}
+
+// Get() is used to get all the AdapterStats of the backend.
+func (s *StorCliBackend) Get() ([]AdapterStat, error) {
+	return s.get(true, true)
+}
+
+// GetVirtualDrive() is used to get the AdapterStats with VirtualDriveStats only.
+func (s *StorCliBackend) GetVirtualDrive() ([]AdapterStat, error) {
+	return s.get(true, false)
+}
+
+// GetPhysicalDrive() is used to get the AdapterStats with PhysicalDriveStats only.
+func (s *StorCliBackend) GetPhysicalDrive() ([]AdapterStat, error) {
+	return s.get(false, true)
+}
+
+func (s *StorCliBackend) get(withVd, withPd bool) ([]AdapterStat, error) {
+	output, err := s.execJson("/call show all J")
+	if err != nil {
+		return nil, err
+	}
+	ads, pciPaths, err := parseStorCliAdapterInfo(output)
+	if err != nil {
+		return nil, err
+	}
+
+	if withVd {
+		output, err := s.execJson("/call/vall show all J")
+		if err != nil {
+			return nil, err
+		}
+		vds, err := parseStorCliVdInfo(output, pciPaths, s.resolver)
+		if err != nil {
+			return nil, err
+		}
+		for i := range ads {
+			ads[i].VirtualDriveStats = vds[ads[i].AdapterId]
+			if ads[i].VirtualDriveStats == nil {
+				ads[i].VirtualDriveStats = make([]VirtualDriveStat, 0)
+			}
+		}
+	}
+	if withPd {
+		output, err := s.execJson("/call/eall/sall show all J")
+		if err != nil {
+			return nil, err
+		}
+		pds, err := parseStorCliPdInfo(output, pciPaths, s.resolver)
+		if err != nil {
+			return nil, err
+		}
+		for i := range ads {
+			ads[i].PhysicalDriveStats = pds[ads[i].AdapterId]
+			if ads[i].PhysicalDriveStats == nil {
+				ads[i].PhysicalDriveStats = make([]PhysicalDriveStat, 0)
+			}
+		}
+	}
+	return ads, nil
+}
+
+// storcli在没有VD或PD时会返回非0退出码，但输出依然是合法的JSON，
+// 所以不管退出码都保留stdout，由 storCliController.check() 判断
+func (s *StorCliBackend) execJson(args string) (*storCliOutput, error) {
+	output, err := exec.Command(s.storcliPath, strings.Split(args, " ")...).Output()
+	if len(output) == 0 {
+		if err != nil {
+			return nil, fmt.Errorf("storCli failed: %v (Arguments: %s)", err, args)
+		}
+		return nil, errors.New("storCli output nil: " + args)
+	}
+	result := new(storCliOutput)
+	if err := json.Unmarshal(output, result); err != nil {
+		return nil, fmt.Errorf("storCli output illegal: %v", err)
+	}
+	return result, nil
+}
+
+// 没有VD/PD时storcli返回的Failure描述，去掉结尾标点后不区分大小写比较
+var storCliEmptyResults = map[string]bool{
+	"no vds have been configured":  true,
+	"no vd's have been configured": true,
+	"no drive found":               true,
+}
+
+// 控制器返回Failure时，只有没有VD/PD属于正常情况，其他错误(如控制器不存在)直接返回
+func (c *storCliController) check() (bool, error) {
+	status := jsonString(c.CommandStatus, "Status")
+	if status == "Success" {
+		return true, nil
+	}
+	desc := jsonString(c.CommandStatus, "Description")
+	if storCliEmptyResults[strings.ToLower(strings.TrimRight(strings.TrimSpace(desc), ".!"))] {
+		return false, nil
+	}
+	return false, errors.New("storCli return error: " + desc)
+}
+
+func parseStorCliAdapterInfo(output *storCliOutput) ([]AdapterStat, map[int]string, error) {
+	if len(output.Controllers) == 0 {
+		return nil, nil, errors.New("storCli adapter info nil")
+	}
+
+	ads := make([]AdapterStat, 0)
+	pciPaths := make(map[int]string)
+	for _, c := range output.Controllers {
+		ok, err := c.check()
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			continue
+		}
+		adapterId := jsonInt(c.CommandStatus, "Controller")
+		basics := jsonObject(c.ResponseData, "Basics")
+		pciPaths[adapterId] = storCliPCIPath(jsonString(basics, "PCI Address"))
+		ads = append(ads, AdapterStat{
+			AdapterId: adapterId,
+			Backend:   backendStorCli,
+			pciPath:   pciPaths[adapterId],
+		})
+	}
+	return ads, pciPaths, nil
+}
+
+// "PCI Address" : "00:3b:00:00" 转换为 0000:3b:00.0
+func storCliPCIPath(address string) string {
+	parts := strings.Split(address, ":")
+	if len(parts) != 4 {
+		return ""
+	}
+	function := strings.TrimLeft(parts[3], "0")
+	if function == "" {
+		function = "0"
+	}
+	return fmt.Sprintf("0000:%02s:%02s.%s", parts[1], parts[2], function)
+}
+
+func parseStorCliVdInfo(output *storCliOutput, pciPaths map[int]string, resolver *osDeviceResolver) (map[int][]VirtualDriveStat, error) {
+	result := make(map[int][]VirtualDriveStat)
+	for _, c := range output.Controllers {
+		ok, err := c.check()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		adapterId := jsonInt(c.CommandStatus, "Controller")
+		vds := make([]VirtualDriveStat, 0)
+		for key := range c.ResponseData {
+			matches := storCliVdKeyRegex.FindStringSubmatch(key)
+			if matches == nil {
+				continue
+			}
+			rows := jsonArray(c.ResponseData, key)
+			if len(rows) == 0 {
+				continue
+			}
+			vdId, _ := strconv.Atoi(matches[2])
+			row := rows[0]
+			props := jsonObject(c.ResponseData, fmt.Sprintf("VD%d Properties", vdId))
+
+			vd := VirtualDriveStat{
+				VirtualDrive:   vdId,
+				Name:           strings.TrimSpace(jsonString(row, "Name")),
+				Size:           jsonString(row, "Size"),
+				State:          storCliState(storCliVdStates, jsonString(row, "State")),
+				RaidLevel:      normalizeRaidLevel(jsonString(row, "TYPE")),
+				Encryptiontype: jsonString(props, "Encryption"),
+			}
+			// "DG/VD" : "0/1"，删除重建过VD后两者可能不同
+			if dgVd := strings.SplitN(jsonString(row, "DG/VD"), "/", 2); dgVd[0] != "" {
+				vd.diskGroup = dgVd[0]
+			}
+			if members := jsonArray(c.ResponseData, fmt.Sprintf("PDs for VD %d", vdId)); len(members) > 0 {
+				vd.NumberOfDrives = len(members)
+			} else {
+				vd.NumberOfDrives = jsonInt(props, "Span Depth") * jsonInt(props, "Number of Drives Per Span")
+			}
+
+			vd.OsPath = "Unknown"
+			if osName := jsonString(props, "OS Drive Name"); strings.HasPrefix(osName, "/dev/") {
+				vd.OsPath = osName
+			} else if pciPath := pciPaths[adapterId]; pciPath != "" {
+				vd.OsPath = resolver.vdOsPath(pciPath, vdId)
+			}
+			vds = append(vds, vd)
+		}
+		sort.Slice(vds, func(i, j int) bool {
+			return vds[i].VirtualDrive < vds[j].VirtualDrive
+		})
+		result[adapterId] = vds
+	}
+	return result, nil
+}
+
+func parseStorCliPdInfo(output *storCliOutput, pciPaths map[int]string, resolver *osDeviceResolver) (map[int][]PhysicalDriveStat, error) {
+	result := make(map[int][]PhysicalDriveStat)
+	for _, c := range output.Controllers {
+		ok, err := c.check()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		adapterId := jsonInt(c.CommandStatus, "Controller")
+		pds := make([]PhysicalDriveStat, 0)
+		for key := range c.ResponseData {
+			matches := storCliPdKeyRegex.FindStringSubmatch(key)
+			if matches == nil {
+				continue
+			}
+			rows := jsonArray(c.ResponseData, key)
+			if len(rows) == 0 {
+				continue
+			}
+			pd := parseStorCliPd(key, rows[0], jsonObject(c.ResponseData, key+" - Detailed Information"))
+			pd.OsPath = "Unknown"
+			// 只有JBOD会直接映射到系统
+			if strings.HasPrefix(pd.FirmwareState, "JBOD") {
+				if pciPath := pciPaths[adapterId]; pciPath != "" {
+					pd.OsPath = resolver.pdOsPath(pciPath, pd.DeviceId, pd.SasAddress, pd.Wwn)
+				}
+			}
+			pds = append(pds, pd)
+		}
+		sort.Slice(pds, func(i, j int) bool {
+			if pds[i].EnclosureDeviceId != pds[j].EnclosureDeviceId {
+				return pds[i].EnclosureDeviceId < pds[j].EnclosureDeviceId
+			}
+			return pds[i].SlotNumber < pds[j].SlotNumber
+		})
+		result[adapterId] = pds
+	}
+	return result, nil
+}
+
+func parseStorCliPd(key string, row, detail map[string]interface{}) PhysicalDriveStat {
+	pd := PhysicalDriveStat{}
+
+	// "EID:Slt" : "32:0"，没有背板时EID为空，和MegaCli的N/A一样记为999
+	eidSlot := strings.SplitN(jsonString(row, "EID:Slt"), ":", 2)
+	pd.EnclosureDeviceId = 999
+	if eid, err := strconv.Atoi(strings.TrimSpace(eidSlot[0])); err == nil {
+		pd.EnclosureDeviceId = eid
+	}
+	if len(eidSlot) == 2 {
+		pd.SlotNumber, _ = strconv.Atoi(strings.TrimSpace(eidSlot[1]))
+	}
+	pd.DeviceId = jsonInt(row, "DID")
+	pd.PdType = strings.TrimSpace(jsonString(row, "Intf"))
+	pd.PdMediaType = storCliState(storCliMediaTypes, jsonString(row, "Med"))
+	if dg := jsonString(row, "DG"); dg != "-" {
+		pd.PdDiskGroup = dg
+	}
+	pd.FirmwareState = storCliState(storCliPdStates, jsonString(row, "State"))
+	// 专用热备的DG是它保护的阵列，不是成员盘
+	switch jsonString(row, "State") {
+	case "GHS":
+		pd.HotSpare = &HotSpareStat{Type: HotSpareGlobal, Arrays: []int{}}
+	case "DHS":
+		pd.HotSpare = &HotSpareStat{Type: HotSpareDedicated, Arrays: parseIntList(pd.PdDiskGroup)}
+		pd.PdDiskGroup = ""
+	}
+	switch jsonString(row, "Sp") {
+	case "U":
+		pd.FirmwareState += ", Spun Up"
+	case "D":
+		pd.FirmwareState += ", Spun down"
+	}
+	pd.Model = strings.TrimSpace(jsonString(row, "Model"))
+
+	state := jsonObject(detail, key+" State")
+	pd.MediaErrorCount = jsonInt(state, "Media Error Count")
+	pd.OtherErrorCount = jsonInt(state, "Other Error Count")
+	pd.PredictiveFailureCount = jsonInt(state, "Predictive Failure Count")
+	pd.DriveTemperature = strings.TrimSpace(jsonString(state, "Drive Temperature"))
+
+	attrs := jsonObject(detail, key+" Device attributes")
+	pd.SerialNumber = strings.TrimSpace(jsonString(attrs, "SN"))
+	pd.Brand = strings.TrimSpace(jsonString(attrs, "Manufacturer Id"))
+	if model := strings.TrimSpace(jsonString(attrs, "Model Number")); model != "" {
+		pd.Model = model
+	}
+	pd.RawSize = storCliRawSizeReg.ReplaceAllString(jsonString(attrs, "Raw size"), "")
+	pd.Wwn = strings.TrimSpace(jsonString(attrs, "WWN"))
+
+	// "Drive position" : "DriveGroup:0, Span:0, Row:1"
+	policies := jsonObject(detail, key+" Policies/Settings")
+	if ports := jsonArray(policies, "Port Information"); len(ports) > 0 {
+		pd.SasAddress = normalizeSasAddress(jsonString(ports[0], "SAS address"))
+	}
+	for _, part := range strings.Split(jsonString(policies, "Drive position"), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), ":", 2)
+		if len(kv) == 2 && kv[0] == "Row" {
+			pd.PdArm = strings.TrimSpace(kv[1])
+		}
+	}
+	return pd
+}
+
+func storCliState(states map[string]string, abbr string) string {
+	abbr = strings.TrimSpace(abbr)
+	if state, ok := states[abbr]; ok {
+		return state
+	}
+	return abbr
+}
+
+func jsonObject(m map[string]interface{}, key string) map[string]interface{} {
+	if v, ok := m[key].(map[string]interface{}); ok {
+		return v
+	}
+	return map[string]interface{}{}
+}
+
+func jsonArray(m map[string]interface{}, key string) []map[string]interface{} {
+	values, ok := m[key].([]interface{})
+	if !ok {
+		return nil
+	}
+	result := make([]map[string]interface{}, 0, len(values))
+	for _, v := range values {
+		if obj, ok := v.(map[string]interface{}); ok {
+			result = append(result, obj)
+		}
+	}
+	return result
+}
+
+func jsonString(m map[string]interface{}, key string) string {
+	switch v := m[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+func jsonInt(m map[string]interface{}, key string) int {
+	switch v := m[key].(type) {
+	case float64:
+		return int(v)
+	case string:
+		value, err := strconv.Atoi(strings.TrimSpace(v))
+		if err == nil {
+			return value
+		}
+	}
+	return 0
+}
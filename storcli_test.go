@@ -0,0 +1,149 @@
+package diskutil
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func loadStorCliFixture(t *testing.T, name string) *storCliOutput {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "storcli", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := new(storCliOutput)
+	if err := json.Unmarshal(data, output); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return output
+}
+
+func TestParseStorCliAdapterInfo(t *testing.T) {
+	ads, pciPaths, err := parseStorCliAdapterInfo(loadStorCliFixture(t, "adapter.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ads) != 1 || ads[0].AdapterId != 0 || ads[0].Backend != backendStorCli {
+		t.Fatalf("adapters = %+v", ads)
+	}
+	if pciPaths[0] != "0000:3b:00.0" {
+		t.Errorf("pci path = %q", pciPaths[0])
+	}
+}
+
+func TestParseStorCliVdInfo(t *testing.T) {
+	resolver := newOsDeviceResolver(filepath.Join("testdata", "storcli", "sys"), "/dev")
+	pciPaths := map[int]string{0: "0000:3b:00.0"}
+
+	tests := []struct {
+		fixture string
+		want    []VirtualDriveStat
+		wantErr bool
+	}{
+		{
+			fixture: "vall.json",
+			want: []VirtualDriveStat{
+				{VirtualDrive: 0, Name: "system", Size: "446.625 GB", State: "Optimal", RaidLevel: "RAID1", NumberOfDrives: 2, Encryptiontype: "None", OsPath: "/dev/sda", diskGroup: "0"},
+				{VirtualDrive: 1, Name: "data", Size: "10.914 TB", State: "Degraded", RaidLevel: "RAID5", NumberOfDrives: 4, Encryptiontype: "None", OsPath: "/dev/sdb", diskGroup: "1"},
+			},
+		},
+		{fixture: "vall_novd.json", want: nil},
+		{fixture: "vall_error.json", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			vds, err := parseStorCliVdInfo(loadStorCliFixture(t, tt.fixture), pciPaths, resolver)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(vds[0], tt.want) {
+				t.Errorf("vds =\n%+v\nwant\n%+v", vds[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStorCliPdInfo(t *testing.T) {
+	resolver := newOsDeviceResolver(filepath.Join("testdata", "storcli", "sys"), "/dev")
+	result, err := parseStorCliPdInfo(loadStorCliFixture(t, "sall.json"), map[int]string{0: "0000:3b:00.0"}, resolver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pds := result[0]
+	if len(pds) != 3 {
+		t.Fatalf("got %d drives, want 3", len(pds))
+	}
+
+	tests := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"E:S", [][2]int{{pds[0].EnclosureDeviceId, pds[0].SlotNumber}, {pds[1].EnclosureDeviceId, pds[1].SlotNumber}, {pds[2].EnclosureDeviceId, pds[2].SlotNumber}},
+			[][2]int{{32, 0}, {32, 5}, {999, 7}}},
+		{"online state", pds[0].FirmwareState, "Online, Spun Up"},
+		{"online disk group", pds[0].PdDiskGroup, "0"},
+		{"online arm", pds[0].PdArm, "0"},
+		{"serial", pds[0].SerialNumber, "PHYF9214001A480BGN"},
+		{"media type", pds[0].PdMediaType, "Solid State Device"},
+		{"raw size", pds[0].RawSize, "447.130 GB"},
+		{"sas address", pds[0].SasAddress, "500605b00aa1b2c4"},
+		{"temperature", pds[0].DriveTemperature, "27C (80.60 F)"},
+		{"raid member os path", pds[0].OsPath, "Unknown"},
+		{"spare state", pds[1].FirmwareState, "Hotspare, Spun down"},
+		{"spare disk group", pds[1].PdDiskGroup, ""},
+		{"spare", *pds[1].HotSpare, HotSpareStat{Type: HotSpareDedicated, Arrays: []int{1}}},
+		{"errors", [3]int{pds[1].MediaErrorCount, pds[1].OtherErrorCount, pds[1].PredictiveFailureCount}, [3]int{3, 1, 2}},
+		{"jbod os path", pds[2].OsPath, "/dev/sdc"},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestStorCliExecJsonNonZeroExit(t *testing.T) {
+	fixture, err := filepath.Abs(filepath.Join("testdata", "storcli", "vall_novd.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(t.TempDir(), "storcli64")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ncat '"+fixture+"'\nexit 46\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStorCliBackend(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := s.execJson("/call/vall show all J")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := output.Controllers[0].check(); ok || err != nil {
+		t.Errorf("check() = %v, %v, want false, nil", ok, err)
+	}
+}
+
+func TestStorCliCheck(t *testing.T) {
+	tests := []struct {
+		status, description string
+		ok, err             bool
+	}{
+		{"Success", "Show Drive Information Succeeded.", true, false},
+		{"Failure", "No VDs have been configured.", false, false},
+		{"Failure", "No drive found!", false, false},
+		{"Failure", "Controller 3 not found", false, true},
+		{"Failure", "Invalid controller /c9", false, true},
+		{"Failure", "Controller 0 is busy", false, true},
+	}
+	for _, tt := range tests {
+		c := storCliController{CommandStatus: map[string]interface{}{"Status": tt.status, "Description": tt.description}}
+		ok, err := c.check()
+		if ok != tt.ok || (err != nil) != tt.err {
+			t.Errorf("check(%q) = %v, %v, want %v, error %v", tt.description, ok, err, tt.ok, tt.err)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package diskutil
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultDevRoot  string = "/dev"
+	defaultUdevRoot string = "/run/udev"
+
+	// megaraid_sas把JBOD放在channel 0/1，VD放在channel 2/3，每个channel 128个target
+	megaRaidMaxPdChannels   int = 2
+	megaRaidMaxDevPerChanel int = 128
+)
+
+var scsiAddressRegex = regexp.MustCompile(`^(\d+):(\d+):(\d+):(\d+)$`)
+
+// scsiDevice是sysfs中RAID卡下的一个SCSI设备
+type scsiDevice struct {
+	host    int
+	channel int
+	target  int
+	lun     int
+	sysPath string
+	block   string
+}
+
+// osDeviceResolver 通过sysfs把RAID卡上的VD/PD映射到系统块设备，根目录可配置以便测试
+type osDeviceResolver struct {
+	sysRoot string
+	devRoot string
+	// mountinfo和udev数据库用于获取文件系统和挂载点
+	procRoot string
+	udevRoot string
+}
+
+func newOsDeviceResolver(sysRoot, devRoot string) *osDeviceResolver {
+	if sysRoot == "" {
+		sysRoot = defaultSysRoot
+	}
+	if devRoot == "" {
+		devRoot = defaultDevRoot
+	}
+	return &osDeviceResolver{
+		sysRoot:  filepath.Clean(sysRoot),
+		devRoot:  filepath.Clean(devRoot),
+		procRoot: defaultProcRoot,
+		udevRoot: defaultUdevRoot,
+	}
+}
+
+// scsiDevices() 遍历 /sys/bus/pci/devices/<pciPath>/host*/ 下所有带块设备的 H:C:T:L 目录，
+// 不同驱动的层级不同(mpt3sas还有port-*/end_device-*)，所以递归查找
+func (r *osDeviceResolver) scsiDevices(pciPath string) []scsiDevice {
+	devices := make([]scsiDevice, 0)
+	if pciPath == "" {
+		return devices
+	}
+	root, err := filepath.EvalSymlinks(filepath.Join(r.sysRoot, "bus", "pci", "devices", pciPath))
+	if err != nil {
+		return devices
+	}
+
+	filepath.WalkDir(root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		name := entry.Name()
+		if p != root && !strings.HasPrefix(name, "host") && !strings.HasPrefix(name, "target") &&
+			!strings.HasPrefix(name, "port-") && !strings.HasPrefix(name, "end_device-") &&
+			!scsiAddressRegex.MatchString(name) {
+			return filepath.SkipDir
+		}
+		matches := scsiAddressRegex.FindStringSubmatch(name)
+		if matches == nil {
+			return nil
+		}
+		blocks, err := os.ReadDir(filepath.Join(p, "block"))
+		if err != nil || len(blocks) == 0 {
+			return filepath.SkipDir
+		}
+		device := scsiDevice{sysPath: p, block: blocks[0].Name()}
+		device.host, _ = strconv.Atoi(matches[1])
+		device.channel, _ = strconv.Atoi(matches[2])
+		device.target, _ = strconv.Atoi(matches[3])
+		device.lun, _ = strconv.Atoi(matches[4])
+		devices = append(devices, device)
+		return filepath.SkipDir
+	})
+	return devices
+}
+
+func (r *osDeviceResolver) devPath(block string) string {
+	return filepath.Join(r.devRoot, block)
+}
+
+// 按 channel/target/lun 查找，host号不固定所以不参与匹配
+func (r *osDeviceResolver) findByAddress(devices []scsiDevice, channel, target, lun int) (scsiDevice, bool) {
+	for _, device := range devices {
+		if device.channel == channel && device.target == target && device.lun == lun {
+			return device, true
+		}
+	}
+	return scsiDevice{}, false
+}
+
+// vdOsPath() 获取vd对应的系统盘符
+func (r *osDeviceResolver) vdOsPath(pciPath string, virtualDriveId int) string {
+	devices := r.scsiDevices(pciPath)
+	channel := megaRaidMaxPdChannels + virtualDriveId/megaRaidMaxDevPerChanel
+	target := virtualDriveId % megaRaidMaxDevPerChanel
+	if device, ok := r.findByAddress(devices, channel, target, 0); ok {
+		return r.devPath(device.block)
+	}
+	return "Unknown"
+}
+
+// pdOsPath() 获取JBOD对应的系统盘符，优先用WWN/SAS地址匹配，其次用channel/target
+func (r *osDeviceResolver) pdOsPath(pciPath string, deviceId int, sasAddress, wwn string) string {
+	devices := r.scsiDevices(pciPath)
+	wwn = strings.ToLower(wwn)
+	for _, device := range devices {
+		if wwn != "" && strings.Contains(strings.ToLower(readSysfs(device.sysPath, "wwid")), wwn) {
+			return r.devPath(device.block)
+		}
+		if sasAddress != "" && normalizeSasAddress(readSysfs(device.sysPath, "sas_address")) == sasAddress {
+			return r.devPath(device.block)
+		}
+	}
+	channel := deviceId / megaRaidMaxDevPerChanel
+	target := deviceId % megaRaidMaxDevPerChanel
+	if device, ok := r.findByAddress(devices, channel, target, 0); ok {
+		return r.devPath(device.block)
+	}
+	return "Unknown"
+}
+
+// blockBySasAddress() 遍历 /sys/block/*/device/sas_address 找到对应的块设备
+func (r *osDeviceResolver) blockBySasAddress(sasAddress string) (string, bool) {
+	blockDir := filepath.Join(r.sysRoot, "block")
+	entries, err := os.ReadDir(blockDir)
+	if err != nil {
+		return "", false
+	}
+	for _, entry := range entries {
+		address := readSysfs(filepath.Join(blockDir, entry.Name()), "device/sas_address")
+		if address != "" && normalizeSasAddress(address) == sasAddress {
+			return r.devPath(entry.Name()), true
+		}
+	}
+	return "", false
+}
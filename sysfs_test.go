@@ -0,0 +1,112 @@
+package diskutil
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+const fixturePciPath = "0000:3b:00.0"
+
+// newFixtureResolver() 和newFixtureDiskStatus()一样使用 testdata/sysfs
+func newFixtureResolver(t *testing.T) *osDeviceResolver {
+	root := fixtureRoot(t)
+	r := newOsDeviceResolver(filepath.Join(root, "sys"), filepath.Join(root, "dev"))
+	r.procRoot = filepath.Join(root, "proc")
+	r.udevRoot = filepath.Join(root, "run", "udev")
+	return r
+}
+
+func TestScsiDevices(t *testing.T) {
+	r := newFixtureResolver(t)
+	want := []struct {
+		address string
+		block   string
+	}{
+		{"0:0:20:0", "sdc"},
+		{"0:0:33:0", "sdd"},
+		{"0:0:40:0", "sde"},
+		{"0:0:41:0", "sdf"},
+		{"0:2:0:0", "sda"},
+		{"0:3:1:0", "sdb"},
+	}
+	devices := r.scsiDevices(fixturePciPath)
+	if len(devices) != len(want) {
+		t.Fatalf("scsiDevices() = %+v", devices)
+	}
+	for i, device := range devices {
+		address := filepath.Base(device.sysPath)
+		if address != want[i].address || device.block != want[i].block {
+			t.Errorf("device %d = %s %s, want %s %s", i, address, device.block, want[i].address, want[i].block)
+		}
+	}
+
+	if devices := r.scsiDevices("0000:00:00.0"); len(devices) != 0 {
+		t.Errorf("scsiDevices() of a missing adapter = %+v", devices)
+	}
+	if devices := r.scsiDevices(""); len(devices) != 0 {
+		t.Errorf("scsiDevices() without a pci path = %+v", devices)
+	}
+}
+
+func TestVdOsPath(t *testing.T) {
+	r := newFixtureResolver(t)
+	devRoot := filepath.Join(fixtureRoot(t), "dev")
+	tests := []struct {
+		pciPath string
+		vd      int
+		want    string
+	}{
+		{fixturePciPath, 0, filepath.Join(devRoot, "sda")},
+		// VD 128以后在channel 3
+		{fixturePciPath, 129, filepath.Join(devRoot, "sdb")},
+		{fixturePciPath, 1, "Unknown"},
+		{"", 0, "Unknown"},
+	}
+	for _, tt := range tests {
+		if path := r.vdOsPath(tt.pciPath, tt.vd); path != tt.want {
+			t.Errorf("vdOsPath(%q, %d) = %q, want %q", tt.pciPath, tt.vd, path, tt.want)
+		}
+	}
+}
+
+func TestPdOsPath(t *testing.T) {
+	r := newFixtureResolver(t)
+	devRoot := filepath.Join(fixtureRoot(t), "dev")
+	tests := []struct {
+		name       string
+		deviceId   int
+		sasAddress string
+		wwn        string
+		want       string
+	}{
+		// WWN优先，device id指向的是另一块盘
+		{"wwn", 20, "", "5000C500DEADBEE0", filepath.Join(devRoot, "sdd")},
+		{"sas address", 99, "5000c500a1b2c3e1", "", filepath.Join(devRoot, "sdc")},
+		{"address", 20, "", "", filepath.Join(devRoot, "sdc")},
+		{"address on channel 1", 128 + 20, "", "", "Unknown"},
+		{"no match", 21, "5000c50000000001", "5000c50000000000", "Unknown"},
+	}
+	for _, tt := range tests {
+		if path := r.pdOsPath(fixturePciPath, tt.deviceId, tt.sasAddress, tt.wwn); path != tt.want {
+			t.Errorf("%s: pdOsPath() = %q, want %q", tt.name, path, tt.want)
+		}
+	}
+}
+
+func TestBlockBySasAddress(t *testing.T) {
+	r := newFixtureResolver(t)
+	tests := []struct {
+		sasAddress string
+		want       string
+		ok         bool
+	}{
+		{"5000c500deadbee1", filepath.Join(fixtureRoot(t), "dev", "sdd"), true},
+		{"5000c500cafe0002", filepath.Join(fixtureRoot(t), "dev", "sdf"), true},
+		{"5000c50000000001", "", false},
+	}
+	for _, tt := range tests {
+		if path, ok := r.blockBySasAddress(tt.sasAddress); path != tt.want || ok != tt.ok {
+			t.Errorf("blockBySasAddress(%q) = %q, %v, want %q, %v", tt.sasAddress, path, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -3,18 +3,40 @@ package diskutil
 import (
 	"encoding/json"
 	"errors"
+	"regexp"
 	"strings"
 )
 
+// "Primary-1, Secondary-3, RAID Level Qualifier-0"
+var megaCliRaidLevelRegex = regexp.MustCompile(`Primary-(\d+), Secondary-(\d+)`)
+
 // VirtualDriveStat is a struct to get the Virtual Drive Stat of a RAID card.
 type VirtualDriveStat struct {
-	VirtualDrive   int    `json:"virtual_drive"`
-	Name           string `json:"name"`
-	Size           string `json:"size"`
-	State          string `json:"state"`
-	NumberOfDrives int    `json:"number_of_drives"`
-	Encryptiontype string `json:"encryption_type"`
-	OsPath         string `json:"os_path"`
+	VirtualDrive       int              `json:"virtual_drive"`
+	Name               string           `json:"name"`
+	Size               string           `json:"size"`
+	State              string           `json:"state"`
+	RaidLevel          string           `json:"raid_level,omitempty"`
+	NumberOfDrives     int              `json:"number_of_drives"`
+	Encryptiontype     string           `json:"encryption_type"`
+	CachePolicy        *CachePolicyStat `json:"cache_policy,omitempty"`
+	DefaultCachePolicy *CachePolicyStat `json:"default_cache_policy,omitempty"`
+	DiskCachePolicy    string           `json:"disk_cache_policy,omitempty"`
+	OsPath             string           `json:"os_path"`
+	OsDevice           *OSDevice        `json:"os_device,omitempty"`
+	MdStat             *MdArrayStat     `json:"md_stat,omitempty"`
+	Progress           *ProgressStat    `json:"progress,omitempty"`
+	// VD所在的disk group，和PD的PdDiskGroup相同的是它的成员盘
+	diskGroup string
+}
+
+// CachePolicyStat is a struct to get the cache policy of a VD, in the short names
+// MegaCli takes: WB/WT, RA/NORA/ADRA and Direct/Cached.
+type CachePolicyStat struct {
+	WritePolicy  string `json:"write_policy"`
+	ReadPolicy   string `json:"read_policy"`
+	IOPolicy     string `json:"io_policy"`
+	CachedBadBBU bool   `json:"cached_bad_bbu"`
 }
 
 // String() is used to get the print string.
@@ -70,12 +92,83 @@ func (v *VirtualDriveStat) parseLine(line string) error {
 			return err
 		}
 		v.NumberOfDrives = numberOfDrives.(int)
+	} else if strings.HasPrefix(line, keyVdRaidLevel) {
+		raidLevel, err := parseFiled(line, keyVdRaidLevel, typeString)
+		if err != nil {
+			return err
+		}
+		v.RaidLevel = megaCliRaidLevel(raidLevel.(string))
 	} else if strings.HasPrefix(line, keyVdEncryptiontype) {
 		encryptiontype, err := parseFiled(line, keyVdEncryptiontype, typeString)
 		if err != nil {
 			return err
 		}
 		v.Encryptiontype = encryptiontype.(string)
+	} else if strings.HasPrefix(line, keyVdCurrentCachePolicy) {
+		policy, err := parseFiled(line, keyVdCurrentCachePolicy, typeString)
+		if err != nil {
+			return err
+		}
+		v.CachePolicy = parseMegaCliCachePolicy(policy.(string))
+	} else if strings.HasPrefix(line, keyVdDefaultCachePolicy) {
+		policy, err := parseFiled(line, keyVdDefaultCachePolicy, typeString)
+		if err != nil {
+			return err
+		}
+		v.DefaultCachePolicy = parseMegaCliCachePolicy(policy.(string))
+	} else if strings.HasPrefix(line, keyVdDiskCachePolicy) {
+		policy, err := parseFiled(line, keyVdDiskCachePolicy, typeString)
+		if err != nil {
+			return err
+		}
+		v.DiskCachePolicy = policy.(string)
 	}
 	return nil
 }
+
+// "WriteBack, ReadAdaptive, Direct, No Write Cache if Bad BBU"
+func parseMegaCliCachePolicy(value string) *CachePolicyStat {
+	policy := new(CachePolicyStat)
+	for _, part := range strings.Split(value, ",") {
+		switch strings.TrimSpace(part) {
+		case "WriteBack":
+			policy.WritePolicy = "WB"
+		case "WriteThrough":
+			policy.WritePolicy = "WT"
+		case "ReadAhead", "ReadAheadAlways":
+			policy.ReadPolicy = "RA"
+		case "ReadAheadNone":
+			policy.ReadPolicy = "NORA"
+		case "ReadAdaptive":
+			policy.ReadPolicy = "ADRA"
+		case "Direct":
+			policy.IOPolicy = "Direct"
+		case "Cached":
+			policy.IOPolicy = "Cached"
+		case "Write Cache OK if Bad BBU":
+			policy.CachedBadBBU = true
+		}
+	}
+	return policy
+}
+
+// Secondary-3 表示跨span，RAID1/5/6跨span即为RAID10/50/60
+func megaCliRaidLevel(value string) string {
+	matches := megaCliRaidLevelRegex.FindStringSubmatch(value)
+	if matches == nil {
+		return value
+	}
+	if matches[2] == "3" && (matches[1] == "1" || matches[1] == "5" || matches[1] == "6") {
+		return "RAID" + matches[1] + "0"
+	}
+	return "RAID" + matches[1]
+}
+
+// normalizeRaidLevel() 把各工具的 "1+0"、"raid10"、"RAID1" 统一为 "RAID10" 这样的格式
+func normalizeRaidLevel(value string) string {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" {
+		return ""
+	}
+	return "RAID" + strings.ReplaceAll(strings.TrimPrefix(value, "RAID"), "+", "")
+}